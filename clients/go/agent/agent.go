@@ -0,0 +1,224 @@
+// Package agent implements a local daemon forwarding events to Samsara.
+// Processes without a Samsara client, e.g. short-lived scripts, send events
+// to the agent over a Unix socket, UDP or HTTP, and the agent buffers them
+// and publishes them upstream in batches.
+package agent
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/syslog"
+)
+
+// Error of an event which couldn't be written to the spool.
+var errSpool = errors.New("events couldn't be spooled")
+
+// Stats contains counters of the agent activity.
+type Stats struct {
+	// Number of events accepted from inputs.
+	Received uint64
+
+	// Number of malformed or invalid events, including malformed syslog messages.
+	Rejected uint64
+
+	// Number of events which couldn't be written to the spool.
+	Dropped uint64
+
+	// Number of events forwarded upstream.
+	Forwarded uint64
+
+	// Number of spooled segments waiting to be forwarded.
+	Spooled int
+
+	// Error of the last forwarding of spooled events, if it failed.
+	LastError string
+
+	// Counters of the client. Published events are spooled ones
+	// if the agent spools events.
+	Client client.Stats
+}
+
+// Agent receives events from local inputs and forwards them upstream.
+type Agent struct {
+	received uint64 // first for 64-bit alignment of atomic counters
+	rejected uint64
+	dropped  uint64
+
+	config Config
+	client *client.Client
+	spool  *spool
+
+	socket   net.Listener
+	packets  net.PacketConn
+	syslog   *syslog.Server
+	syslogs  []net.Addr
+	server   *http.Server
+	listener net.Listener
+	conns    map[net.Conn]bool
+	closed   bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopErr  error
+	running  sync.WaitGroup
+	sync.Mutex
+}
+
+// New creates an agent. Events are forwarded by a client, or spooled
+// and forwarded periodically if config.SpoolDir is set.
+func New(config Config) (*Agent, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	a := &Agent{config: config, conns: make(map[net.Conn]bool), stop: make(chan struct{})}
+	var err error
+	if config.SpoolDir == "" {
+		a.client, err = client.NewClient(config.Client)
+	} else {
+		a.spool = newSpool(config, client.NewPublisher(config.Client))
+		spooling := config.Client
+		spooling.StartPublishingThread = false
+		a.client, err = client.NewClientWithPublisher(spooling, a.spool)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Start starts listening on configured inputs. Events are received
+// in background until the agent is stopped.
+func (a *Agent) Start() error {
+	if err := a.listen(); err != nil {
+		a.closeInputs()
+		return err
+	}
+	if a.spool != nil {
+		a.running.Add(1)
+		go a.forwarding()
+	}
+	return nil
+}
+
+// Stop stops receiving events and forwards buffered events.
+// The agent can't be started again. Subsequent calls only return
+// the error of the first one.
+func (a *Agent) Stop() error {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+		a.closeInputs()
+		a.running.Wait()
+
+		a.stopErr = a.Flush()
+		if a.spool != nil {
+			if err := a.spool.close(); a.stopErr == nil {
+				a.stopErr = err
+			}
+		}
+	})
+	return a.stopErr
+}
+
+// Flush forwards buffered or spooled events immediately.
+func (a *Agent) Flush() error {
+	if a.spool != nil {
+		return a.spool.forward()
+	}
+	if !a.client.Flush() {
+		return errors.New("buffered events couldn't be forwarded")
+	}
+	return nil
+}
+
+// Stats returns current counters of the agent activity.
+func (a *Agent) Stats() Stats {
+	stats := Stats{
+		Received: atomic.LoadUint64(&a.received),
+		Rejected: atomic.LoadUint64(&a.rejected),
+		Dropped:  atomic.LoadUint64(&a.dropped),
+		Client:   a.client.Stats(),
+	}
+	if a.syslog != nil {
+		stats.Rejected += a.syslog.Malformed()
+	}
+	if a.spool == nil {
+		stats.Forwarded = stats.Client.Published
+		return stats
+	}
+
+	forwarded, rejected, err := a.spool.stats()
+	stats.Forwarded = forwarded
+	stats.Rejected += rejected
+	if err != nil {
+		stats.LastError = err.Error()
+	}
+	segments, _ := a.spool.files.Segments()
+	stats.Spooled = len(segments)
+	return stats
+}
+
+// Periodically forwards spooled events.
+func (a *Agent) forwarding() {
+	defer a.running.Done()
+	ticker := time.NewTicker(time.Duration(a.config.Client.PublishInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.spool.forward()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Reads events from r and records them. Malformed and invalid events are rejected.
+// Returns numbers of accepted and rejected events, and an error if r
+// couldn't be read to the end or events couldn't be spooled.
+func (a *Agent) consume(r io.Reader) (int, int, error) {
+	accepted, rejected := 0, 0
+	dec := client.NewEventDecoder(r)
+	for {
+		event, err := dec.Decode()
+		if err == io.EOF {
+			return accepted, rejected, nil
+		}
+		if _, ok := err.(client.EventDecodeError); err != nil && !ok {
+			return accepted, rejected, err
+		}
+		if err == nil {
+			err = a.record(event)
+		}
+
+		switch err {
+		case nil:
+			accepted++
+			atomic.AddUint64(&a.received, 1)
+		case errSpool:
+			atomic.AddUint64(&a.dropped, 1)
+			return accepted, rejected, err
+		default:
+			rejected++
+			atomic.AddUint64(&a.rejected, 1)
+		}
+	}
+}
+
+// Records the event into the buffer, or into the spool right away.
+func (a *Agent) record(event client.Event) error {
+	if a.spool == nil {
+		return a.client.RecordEvent(event)
+	}
+	ok, err := a.client.PublishEvents([]client.Event{event})
+	if err == nil && !ok {
+		return errSpool
+	}
+	return err
+}
@@ -0,0 +1,288 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/syslog"
+)
+
+// Fake Ingestion API collecting names of published events.
+type upstream struct {
+	server *httptest.Server
+	names  []string
+	down   bool
+	sync.Mutex
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{}
+	u.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.Lock()
+		defer u.Unlock()
+		if u.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := gzip.NewReader(r.Body)
+		client.DecodeEvents(body, func(event client.Event) error {
+			u.names = append(u.names, event["eventName"].(string))
+			return nil
+		})
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(u.server.Close)
+	return u
+}
+
+// Returns sorted names of published events.
+func (u *upstream) published() []string {
+	u.Lock()
+	defer u.Unlock()
+	names := append([]string(nil), u.names...)
+	sort.Strings(names)
+	return names
+}
+
+func (u *upstream) setDown(down bool) {
+	u.Lock()
+	u.down = down
+	u.Unlock()
+}
+
+func newTestAgent(t *testing.T, u *upstream, spoolDir string) *Agent {
+	config := NewConfig()
+	config.SocketPath = filepath.Join(t.TempDir(), "agent.sock")
+	config.UdpAddr = "127.0.0.1:0"
+	config.HttpAddr = "127.0.0.1:0"
+	config.SpoolDir = spoolDir
+	config.Client.Url = u.server.URL
+	config.Client.PublishInterval = 3600000
+	a, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.spool != nil {
+		a.spool.options.Retries = 0
+	}
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// Waits until the agent receives n events.
+func waitForReceived(a *Agent, n uint64) {
+	for i := 0; i < 100 && a.Stats().Received < n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func postEvents(a *Agent, encoding, body string) int {
+	req, _ := http.NewRequest("POST", "http://"+a.listener.Addr().String()+client.API_PATH, strings.NewReader(body))
+	req.Header.Set("Content-Encoding", encoding)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAgent_ForwardsEventsFromAllInputs(t *testing.T) {
+	u := newUpstream(t)
+	a := newTestAgent(t, u, "")
+
+	conn, err := net.Dial("unix", a.config.SocketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("{\"eventName\":\"socket.a\",\"sourceId\":\"s\"}\n{\"eventName\":\"socket.b\",\"sourceId\":\"s\"}\n"))
+	conn.Close()
+
+	udp, err := net.Dial("udp", a.packets.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp.Write([]byte(`[{"eventName":"udp.a","sourceId":"s"},{"eventName":"udp.b","sourceId":"s"}]`))
+	udp.Write([]byte(`{"eventName":"udp.c","sourceId":"s"}`))
+	udp.Close()
+
+	if status := postEvents(a, "", `{"eventName":"http.a","sourceId":"s"}`); status != http.StatusAccepted {
+		t.Errorf("Event should be accepted. Got %d", status)
+	}
+
+	waitForReceived(a, 6)
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"http.a", "socket.a", "socket.b", "udp.a", "udp.b", "udp.c"}
+	if got := u.published(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Received events should be forwarded on stop.\nWant: %v\nGot: %v", want, got)
+	}
+	if stats := a.Stats(); stats.Received != 6 || stats.Forwarded != 6 || stats.Client.Buffered != 0 {
+		t.Errorf("Incorrect stats. Got %+v", stats)
+	}
+}
+
+func TestAgent_Stop_CanBeCalledTwice(t *testing.T) {
+	u := newUpstream(t)
+	a := newTestAgent(t, u, "")
+
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Stop(); err != nil {
+		t.Errorf("Stopping a stopped agent should do nothing. Got %v", err)
+	}
+}
+
+func TestAgent_Syslog(t *testing.T) {
+	u := newUpstream(t)
+	config := NewConfig()
+	config.HttpAddr = ""
+	config.SyslogUdpAddr = "127.0.0.1:0"
+	config.SyslogTcpAddr = "127.0.0.1:0"
+	config.SyslogRules = []syslog.Rule{{AppName: "CRON", Drop: true}, {AppName: "sshd", EventName: "auth.{app}"}}
+	config.Client.Url = u.server.URL
+	config.Client.PublishInterval = 3600000
+	a, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	udp, err := net.Dial("udp", a.syslogs[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp.Write([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine su - ID47 - 'su root' failed"))
+	udp.Write([]byte("<78>Feb  5 17:32:18 10.0.0.99 CRON[12]: (root) CMD (true)"))
+	udp.Write([]byte("<999>malformed"))
+	udp.Close()
+
+	tcp, err := net.Dial("tcp", a.syslogs[1].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp.Write([]byte("<38>Feb  5 17:32:18 10.0.0.99 sshd[4123]: Accepted publickey\n"))
+	tcp.Close()
+
+	waitForReceived(a, 2)
+	for i := 0; i < 100 && a.Stats().Rejected == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := u.published(); strings.Join(got, ",") != "auth.sshd,su.ID47" {
+		t.Errorf("Syslog messages should be forwarded as events. Got %v", got)
+	}
+	if stats := a.Stats(); stats.Received != 2 || stats.Rejected != 1 {
+		t.Errorf("Incorrect stats. Got %+v", stats)
+	}
+}
+
+func TestAgent_HTTP(t *testing.T) {
+	u := newUpstream(t)
+	a := newTestAgent(t, u, "")
+	defer a.Stop()
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`[{"eventName":"a","sourceId":"s"}]`))
+	gz.Close()
+
+	var tests = []struct {
+		encoding string
+		body     string
+		status   int
+	}{
+		{"", `[{"eventName":"a","sourceId":"s"},{"eventName":"b","sourceId":"s"}]`, http.StatusAccepted},
+		{"gzip", compressed.String(), http.StatusAccepted},
+		{"", `[{"eventName":"a","sourceId":"s"},{"eventName":"b"}]`, http.StatusBadRequest},
+		{"", `[{"eventName":"a","sourceId":"s"}`, http.StatusBadRequest},
+		{"br", `{"eventName":"a","sourceId":"s"}`, http.StatusUnsupportedMediaType},
+	}
+
+	for i, test := range tests {
+		if status := postEvents(a, test.encoding, test.body); status != test.status {
+			t.Errorf("Set #%d. Want status %d, Got %d", i, test.status, status)
+		}
+	}
+
+	resp, err := http.Get("http://" + a.listener.Addr().String() + STATS_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Received != 5 || stats.Rejected != 1 {
+		t.Errorf("Incorrect stats. Got %+v", stats)
+	}
+}
+
+// Packet connection failing reads with the given errors, then permanently.
+type failingPackets struct {
+	net.PacketConn
+	errs  []error
+	reads int
+}
+
+func (p *failingPackets) ReadFrom(buf []byte) (int, net.Addr, error) {
+	p.reads++
+	if p.reads <= len(p.errs) {
+		return 0, nil, p.errs[p.reads-1]
+	}
+	return 0, nil, net.ErrClosed
+}
+
+// Temporary network error.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func TestAgent_ServePackets_StopsOnPermanentErrors(t *testing.T) {
+	packets := &failingPackets{errs: []error{temporaryError{}, temporaryError{}}}
+	a := &Agent{packets: packets, stop: make(chan struct{})}
+	a.running.Add(1)
+
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		a.servePackets()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Reading should stop on a permanent error")
+	}
+
+	if packets.reads != 3 {
+		t.Errorf("Temporary errors should be retried. Got %d reads", packets.reads)
+	}
+	if elapsed := time.Since(start); elapsed < 3*minReadBackoff {
+		t.Errorf("Temporary errors should be retried with a backoff. Got %v", elapsed)
+	}
+}
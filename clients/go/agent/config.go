@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/syslog"
+)
+
+// Config is the agent configuration. It is loaded from a JSON file
+// with the same field names, e.g.
+//
+//	{"SocketPath": "/run/samsara-agent.sock", "Client": {"Url": "http://samsara:9000"}}
+type Config struct {
+	// Path of a Unix socket accepting streams of events
+	// as NDJSON or JSON arrays.
+	// default = "" (disabled)
+	SocketPath string
+
+	// UDP address accepting datagrams with an event
+	// or a JSON array of events.
+	// default = "" (disabled)
+	UdpAddr string
+
+	// UDP address accepting syslog messages (RFC 5424 or RFC 3164),
+	// one per datagram.
+	// default = "" (disabled)
+	SyslogUdpAddr string
+
+	// TCP address accepting syslog messages framed by octet counting
+	// or delimited by newlines.
+	// default = "" (disabled)
+	SyslogTcpAddr string
+
+	// Rules of converting syslog messages into events.
+	// See syslog.Mapper for the default conversion.
+	// default = [] (default conversion)
+	SyslogRules []syslog.Rule
+
+	// HTTP address accepting events POSTed to /v1/events
+	// like Ingestion API, and serving stats at /stats.
+	// default = "127.0.0.1:9090"
+	HttpAddr string
+
+	// Max size of a HTTP request body in bytes.
+	// default = 10MB
+	MaxRequestSize int64
+
+	// Directory events are spooled to before they are forwarded,
+	// so that they survive restarts and upstream outages.
+	// Spooled segments are forwarded every Client.PublishInterval.
+	// default = "" (events are buffered in memory)
+	SpoolDir string
+
+	// Configuration of the client forwarding events upstream.
+	// With SpoolDir, the File* options apply to the spool.
+	Client client.Config
+}
+
+// NewConfig creates a new Config with all default values.
+func NewConfig() Config {
+	config := Config{}
+	config.HttpAddr = "127.0.0.1:9090"
+	config.MaxRequestSize = 10 * 1024 * 1024
+	config.Client = client.NewConfig()
+	return config
+}
+
+// LoadConfig loads configuration from the JSON file.
+// Options missing in the file have default values.
+func LoadConfig(path string) (Config, error) {
+	config := NewConfig()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Validate validates given configuration values.
+func (c *Config) Validate() error {
+	switch {
+	case c.SocketPath == "" && c.UdpAddr == "" && c.HttpAddr == "" && c.SyslogUdpAddr == "" && c.SyslogTcpAddr == "":
+		return client.ConfigValidationError{Message: "At least one of socketPath, udpAddr, httpAddr, syslogUdpAddr and syslogTcpAddr should be specified."}
+	case c.MaxRequestSize <= 0:
+		return client.ConfigValidationError{Message: "maxRequestSize should be positive."}
+	case c.SpoolDir != "" && c.Client.Transport != "http":
+		return client.ConfigValidationError{Message: "Spooled events can be forwarded only by http transport."}
+	}
+	return c.Client.Validate()
+}
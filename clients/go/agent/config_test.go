@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.json")
+	ioutil.WriteFile(path, []byte(`{
+		"SocketPath": "/tmp/agent.sock",
+		"SpoolDir": "/var/spool/samsara",
+		"SyslogRules": [{"AppName": "CRON", "Drop": true}],
+		"Client": {"Url": "http://samsara:9000", "PublishInterval": 5000}
+	}`), 0644)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.SocketPath != "/tmp/agent.sock" || config.SpoolDir != "/var/spool/samsara" {
+		t.Errorf("Options should be loaded. Got %+v", config)
+	}
+	if len(config.SyslogRules) != 1 || config.SyslogRules[0].AppName != "CRON" || !config.SyslogRules[0].Drop {
+		t.Errorf("Syslog rules should be loaded. Got %+v", config.SyslogRules)
+	}
+	if config.Client.Url != "http://samsara:9000" || config.Client.PublishInterval != 5000 {
+		t.Errorf("Client options should be loaded. Got %+v", config.Client)
+	}
+	if config.HttpAddr != "127.0.0.1:9090" || config.Client.Compression != "gzip" {
+		t.Errorf("Missing options should have default values. Got %+v", config)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Loaded config should be valid. Got %v", err)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	var tests = []string{
+		`{"SocketPth": "/tmp/agent.sock"}`,
+		`{"Client": {"Url": 1}}`,
+		`{`,
+	}
+
+	for i, content := range tests {
+		path := filepath.Join(dir, "agent.json")
+		ioutil.WriteFile(path, []byte(content), 0644)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("Set #%d. Loading %s should fail", i, content)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Loading missing file should fail")
+	}
+}
+
+func TestConfig_Validate_WithInvalidData(t *testing.T) {
+	sets := []struct {
+		msg    string
+		config func(*Config)
+	}{
+		{
+			"At least one of socketPath, udpAddr, httpAddr, syslogUdpAddr and syslogTcpAddr should be specified.",
+			func(c *Config) { c.HttpAddr = "" },
+		},
+		{
+			"maxRequestSize should be positive.",
+			func(c *Config) { c.MaxRequestSize = 0 },
+		},
+		{
+			"Spooled events can be forwarded only by http transport.",
+			func(c *Config) {
+				c.SpoolDir = "/var/spool/samsara"
+				c.Client.Transport = "stdout"
+			},
+		},
+		{
+			"URL for Ingestion API should be specified.",
+			func(c *Config) { c.Client.Url = "" },
+		},
+	}
+
+	for i, set := range sets {
+		config := NewConfig()
+		config.Client.Url = "http://foo.bar"
+		set.config(&config)
+		err := config.Validate()
+		if err == nil || err.Error() != set.msg {
+			t.Errorf("Set #%d. Expected error message %q, got %v", i, set.msg, err)
+		}
+	}
+}
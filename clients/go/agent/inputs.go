@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/syslog"
+)
+
+// STATS_PATH is the agent stats endpoint.
+const STATS_PATH = "/stats"
+
+// Max size of a UDP datagram.
+const maxDatagramSize = 64 * 1024
+
+// Bounds of the backoff after temporary errors reading UDP datagrams.
+const (
+	minReadBackoff = 5 * time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+// Time given to HTTP requests in progress to finish when the agent is stopped.
+const shutdownTimeout = 5 * time.Second
+
+// Opens listeners of configured inputs and starts serving them.
+func (a *Agent) listen() error {
+	if a.config.SocketPath != "" {
+		// a socket left behind by a killed agent
+		if info, err := os.Stat(a.config.SocketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(a.config.SocketPath)
+		}
+		socket, err := net.Listen("unix", a.config.SocketPath)
+		if err != nil {
+			return err
+		}
+		a.socket = socket
+		a.running.Add(1)
+		go a.serveSocket()
+	}
+
+	if a.config.UdpAddr != "" {
+		packets, err := net.ListenPacket("udp", a.config.UdpAddr)
+		if err != nil {
+			return err
+		}
+		a.packets = packets
+		a.running.Add(1)
+		go a.servePackets()
+	}
+
+	if a.config.SyslogUdpAddr != "" || a.config.SyslogTcpAddr != "" {
+		a.syslog = syslog.NewServer(syslog.Mapper{Rules: a.config.SyslogRules}, a.receiveSyslog)
+		if a.config.SyslogUdpAddr != "" {
+			addr, err := a.syslog.ListenUDP(a.config.SyslogUdpAddr)
+			if err != nil {
+				return err
+			}
+			a.syslogs = append(a.syslogs, addr)
+		}
+		if a.config.SyslogTcpAddr != "" {
+			addr, err := a.syslog.ListenTCP(a.config.SyslogTcpAddr)
+			if err != nil {
+				return err
+			}
+			a.syslogs = append(a.syslogs, addr)
+		}
+	}
+
+	if a.config.HttpAddr != "" {
+		listener, err := net.Listen("tcp", a.config.HttpAddr)
+		if err != nil {
+			return err
+		}
+		a.listener = listener
+		mux := http.NewServeMux()
+		mux.HandleFunc(client.API_PATH, a.handleEvents)
+		mux.HandleFunc(client.STATUS_PATH, a.handleStatus)
+		mux.HandleFunc(STATS_PATH, a.handleStats)
+		a.server = &http.Server{Handler: mux}
+		a.running.Add(1)
+		go func() {
+			defer a.running.Done()
+			a.server.Serve(listener)
+		}()
+	}
+	return nil
+}
+
+// Closes listeners and open connections.
+func (a *Agent) closeInputs() {
+	if a.socket != nil {
+		a.socket.Close()
+	}
+	if a.packets != nil {
+		a.packets.Close()
+	}
+	if a.syslog != nil {
+		a.syslog.Close()
+	}
+	if a.server != nil {
+		// let requests in progress finish
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		a.server.Shutdown(ctx)
+		cancel()
+		a.server.Close()
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.closed = true
+	for conn := range a.conns {
+		conn.Close()
+	}
+}
+
+// Accepts Unix socket connections.
+func (a *Agent) serveSocket() {
+	defer a.running.Done()
+	for {
+		conn, err := a.socket.Accept()
+		if err != nil {
+			return
+		}
+		a.Lock()
+		if a.closed {
+			a.Unlock()
+			conn.Close()
+			return
+		}
+		a.conns[conn] = true
+		a.Unlock()
+
+		a.running.Add(1)
+		go a.serveConn(conn)
+	}
+}
+
+// Reads events from the connection until it is closed.
+func (a *Agent) serveConn(conn net.Conn) {
+	defer a.running.Done()
+	a.consume(conn)
+	conn.Close()
+
+	a.Lock()
+	delete(a.conns, conn)
+	a.Unlock()
+}
+
+// Reads events from UDP datagrams. Temporary read errors are retried
+// with an exponential backoff, others stop reading.
+func (a *Agent) servePackets() {
+	defer a.running.Done()
+	buf := make([]byte, maxDatagramSize)
+	var backoff time.Duration
+	for {
+		n, _, err := a.packets.ReadFrom(buf)
+		if err == nil {
+			backoff = 0
+			a.consume(bytes.NewReader(buf[:n]))
+			continue
+		}
+		if !temporary(err) {
+			return
+		}
+
+		if backoff *= 2; backoff == 0 {
+			backoff = minReadBackoff
+		} else if backoff > maxReadBackoff {
+			backoff = maxReadBackoff
+		}
+		select {
+		case <-a.stop:
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Tells whether the error is temporary, e.g. a timeout.
+func temporary(err error) bool {
+	t, ok := err.(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
+
+// Records an event converted from a syslog message.
+func (a *Agent) receiveSyslog(event client.Event) {
+	switch a.record(event) {
+	case nil:
+		atomic.AddUint64(&a.received, 1)
+	case errSpool:
+		atomic.AddUint64(&a.dropped, 1)
+	default:
+		atomic.AddUint64(&a.rejected, 1)
+	}
+}
+
+// Accepts events POSTed like to Ingestion API.
+// Responds 202 if all of them are accepted, 400 if some are rejected.
+func (a *Agent) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := client.Decompress(r.Header.Get("Content-Encoding"), http.MaxBytesReader(w, r.Body, a.config.MaxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	accepted, rejected, err := a.consume(body)
+	switch {
+	case err == errSpool:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("%d events accepted, malformed request: %v", accepted, err), http.StatusBadRequest)
+	case rejected > 0:
+		http.Error(w, fmt.Sprintf("%d events accepted, %d rejected", accepted, rejected), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// Health-check, so that clients estimating clock skew can use the agent as Ingestion API.
+func (a *Agent) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Serves stats as JSON.
+func (a *Agent) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.Stats())
+}
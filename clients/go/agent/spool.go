@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/replay"
+)
+
+// Name of the file with progress of the forwarding in the spool directory.
+const checkpointFile = "checkpoint.json"
+
+// Spool of events on disk. Events are appended to segment files,
+// which are periodically closed and replayed upstream.
+// Forwarded segments are deleted.
+type spool struct {
+	files      *client.FilePublisher
+	options    replay.Options
+	forwarded  uint64
+	rejected   uint64
+	lastError  error
+	checkpoint string
+	sync.Mutex
+}
+
+// Creates spool in config.SpoolDir forwarding to the upstream publisher.
+func newSpool(config Config, upstream client.IPublisher) *spool {
+	files := config.Client
+	files.FileDir = config.SpoolDir
+
+	options := replay.NewOptions(upstream)
+	if config.Client.MaxBatchSize > 0 {
+		options.BatchSize = int(config.Client.MaxBatchSize)
+	}
+	options.CheckpointFile = filepath.Join(config.SpoolDir, checkpointFile)
+
+	return &spool{
+		files:      client.NewFilePublisher(files),
+		options:    options,
+		checkpoint: options.CheckpointFile,
+	}
+}
+
+// Post appends events to the spool.
+func (s *spool) Post(data []client.Event) bool {
+	return s.files.Post(data)
+}
+
+// Forwards all spooled events. Segments are deleted once forwarded,
+// a failed segment is continued from the checkpoint next time.
+func (s *spool) forward() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.files.Rotate(); err != nil {
+		return s.fail(err)
+	}
+	segments, err := s.files.Segments()
+	if err != nil || len(segments) == 0 {
+		return s.fail(err)
+	}
+
+	summary, err := replay.Replay(segments, s.options)
+	s.forwarded += uint64(summary.Accepted)
+	s.rejected += uint64(summary.Rejected)
+	for _, segment := range segments[:summary.Files] {
+		os.Remove(segment)
+	}
+	if err == nil {
+		os.Remove(s.checkpoint)
+	}
+	return s.fail(err)
+}
+
+// Remembers the error of the last forwarding.
+func (s *spool) fail(err error) error {
+	s.lastError = err
+	return err
+}
+
+// Returns number of forwarded and rejected events, and the last error.
+func (s *spool) stats() (uint64, uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.forwarded, s.rejected, s.lastError
+}
+
+// Closes the active segment.
+func (s *spool) close() error {
+	return s.files.Close()
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAgent_Spool_ForwardsAfterUpstreamOutage(t *testing.T) {
+	u := newUpstream(t)
+	u.setDown(true)
+	dir := filepath.Join(t.TempDir(), "spool")
+	a := newTestAgent(t, u, dir)
+	defer a.Stop()
+
+	postEvents(a, "", `{"eventName":"a","sourceId":"s"}`)
+	postEvents(a, "", `{"eventName":"b","sourceId":"s"}`)
+	if err := a.Flush(); err == nil {
+		t.Error("Forwarding should fail while upstream is down")
+	}
+	postEvents(a, "", `{"eventName":"c","sourceId":"s"}`)
+	a.spool.files.Rotate()
+
+	stats := a.Stats()
+	if stats.Received != 3 || stats.Forwarded != 0 || stats.Spooled != 2 || stats.LastError == "" {
+		t.Errorf("Events should stay spooled. Got %+v", stats)
+	}
+
+	u.setDown(false)
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.published(); strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("Spooled events should be forwarded once. Got %v", got)
+	}
+	if stats := a.Stats(); stats.Forwarded != 3 || stats.Spooled != 0 || stats.LastError != "" {
+		t.Errorf("Forwarded segments should be deleted. Got %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(dir, checkpointFile)); !os.IsNotExist(err) {
+		t.Error("Checkpoint should be deleted when everything is forwarded")
+	}
+}
+
+func TestAgent_Spool_SurvivesRestart(t *testing.T) {
+	u := newUpstream(t)
+	u.setDown(true)
+	dir := filepath.Join(t.TempDir(), "spool")
+
+	a := newTestAgent(t, u, dir)
+	postEvents(a, "", `{"eventName":"a","sourceId":"s"}`)
+	if err := a.Stop(); err == nil {
+		t.Error("Stop should fail when events can't be forwarded")
+	}
+
+	u.setDown(false)
+	a = newTestAgent(t, u, dir)
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.published(); strings.Join(got, ",") != "a" {
+		t.Errorf("Events spooled before restart should be forwarded. Got %v", got)
+	}
+}
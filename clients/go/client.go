@@ -1,15 +1,31 @@
 package client
 
 import (
+	"sync/atomic"
 	"time"
 )
 
+// ClientSourceId is the sourceId of events generated by the client itself
+// when Config.SourceId is not set.
+const ClientSourceId = "samsara.client"
+
 // Client for ingesting events into Samsara.
 // It is the main interface to communicate with Samsara API.
 type Client struct {
-	config    Config
-	publisher IPublisher
-	queue     *RingBuffer
+	published    uint64 // first for 64-bit alignment of atomic counters
+	failed       uint64
+	metricErrors uint64
+	config       Config
+	publisher    IPublisher
+	queue        *RingBuffer
+	redactor     *redactor
+	sampler      *sampler
+	sessionizer  *sessionizer
+	deduplicator *deduplicator
+	limiter      *rateLimiter
+	metrics      *metricsRegistry
+	clock        *clockSkew
+	sequencer    *sequencer
 }
 
 // NewClient returns a new Samsara SDK client configured based on given Config options.
@@ -18,50 +34,241 @@ func NewClient(config Config) (*Client, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	clock := newClockSkew(config)
+	return newClient(config, newPublisher(config, clock), clock), nil
+}
+
+// NewClientWithPublisher returns a new client publishing events through the given publisher
+// instead of the configured transport, e.g. to spool them before forwarding.
+func NewClientWithPublisher(config Config, publisher IPublisher) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newClient(config, publisher, newClockSkew(config)), nil
+}
 
+// Creates client and starts the publishing activity if configured.
+func newClient(config Config, publisher IPublisher, clock *clockSkew) *Client {
+	if config.PublishWorkers == 0 {
+		config.PublishWorkers = 1
+	}
 	client := &Client{
-		config:    config,
-		publisher: &Publisher{config},
-		queue:     NewRingBuffer(config.MaxBufferSize),
+		config:       config,
+		publisher:    publisher,
+		queue:        NewRingBuffer(config.MaxBufferSize),
+		redactor:     newRedactor(config.Redaction, config.RedactionKey),
+		sampler:      newSampler(config.Sampling),
+		sessionizer:  newSessionizer(config),
+		deduplicator: newDeduplicator(config),
+		limiter:      newRateLimiter(config),
+		metrics:      newMetricsRegistry(config),
+		clock:        clock,
+		sequencer:    newSequencer(config),
 	}
 
 	if config.StartPublishingThread {
 		go client.publishing()
 	}
 
-	return client, nil
+	return client
 }
 
 // PublishEvents publishes given events list to Ingestion API immediately.
+// Duplicates and events dropped by sampling rules or rate limits are not published.
+// Events which aren't published aren't remembered as seen, so they can be retried.
 func (c *Client) PublishEvents(events []Event) (bool, error) {
+	prepared := make([]Event, 0, len(events))
+	var keys []*seenKey
 	for _, event := range events {
-		event.enrich(c.config)
-		if err := event.validate(); err != nil {
+		ready, _, err := c.prepare(event, nil, &keys)
+		if err != nil {
+			c.deduplicator.forget(keys)
 			return false, err
 		}
+		prepared = append(prepared, ready...)
+	}
+	if len(prepared) == 0 {
+		return true, nil
 	}
 
-	return c.publisher.Post(events), nil
+	ok := c.post(prepared)
+	if !ok {
+		c.deduplicator.forget(keys)
+	}
+	return ok, nil
+}
+
+// Flush publishes all buffered events immediately, e.g. before exiting.
+// It waits for batches posted by publishing workers meanwhile.
+// Returns false if some of them couldn't be published.
+func (c *Client) Flush() bool {
+	for {
+		failed := atomic.LoadUint64(&c.failed)
+		for c.publishBatch(false) {
+		}
+		if c.queue.settle() == 0 {
+			return true
+		}
+		// events left are either failed, or were held back by a batch in flight
+		if atomic.LoadUint64(&c.failed) != failed {
+			return false
+		}
+	}
 }
 
 // RecordEvent pushes event to internal events' queue.
+// Duplicates and events dropped by sampling rules or rate limits are silently discarded.
 func (c *Client) RecordEvent(event Event) error {
+	_, err := c.record(event)
+	return err
+}
+
+// Pushes event to internal events' queue. Numbers the client injected
+// into the event in the given facets are redacted only by rules naming them.
+// Returns whether the event has been kept, i.e. not dropped as a duplicate,
+// by sampling or by rate limits.
+func (c *Client) record(event Event, injected ...string) (bool, error) {
+	var keys []*seenKey
+	ready, kept, err := c.prepare(event, injected, &keys)
+	if err != nil {
+		c.deduplicator.forget(keys)
+		return false, err
+	}
+	for _, e := range ready {
+		c.queue.Push(e)
+	}
+	return kept, nil
+}
+
+// Prepares event for sending: enriches, validates, deduplicates, redacts,
+// sessionizes, samples, rate limits and numbers it.
+// Returns events ready to be sent: the given one (unless dropped)
+// preceded by events generated along the way, and whether the given
+// one has been kept. Deduplication keys remembered along the way are
+// appended to keys.
+func (c *Client) prepare(event Event, injected []string, keys *[]*seenKey) ([]Event, bool, error) {
+	return c.prepareRedacted(event, false, injected, keys)
+}
+
+// Prepares event for sending, skipping redaction if the event
+// is built of redacted data already, e.g. session boundaries.
+func (c *Client) prepareRedacted(event Event, redacted bool, injected []string, keys *[]*seenKey) ([]Event, bool, error) {
 	event.enrich(c.config)
-	if err := event.validate(); err != nil {
-		return err
+	if err := event.Validate(); err != nil {
+		return nil, false, err
+	}
+	key, duplicate := c.deduplicator.admit(event)
+	if duplicate {
+		return nil, false, nil
+	}
+	if key != nil {
+		*keys = append(*keys, key)
+	}
+	if !redacted {
+		c.redactor.redact(event, injected...)
 	}
-	c.queue.Push(event)
-	return nil
+
+	var ready []Event
+	for _, generated := range c.sessionizer.sessionize(event) {
+		more, _, err := c.prepareRedacted(generated, true, nil, keys)
+		if err != nil {
+			return nil, false, err
+		}
+		ready = append(ready, more...)
+	}
+
+	kept := c.sampler.sample(event) && c.limiter.allow(event)
+	if kept {
+		c.sequencer.number(event)
+		ready = append(ready, event)
+	}
+
+	if summary := c.limiter.report(); summary != nil {
+		more, _, err := c.prepare(summary, rateLimitFacets, keys)
+		if err != nil {
+			return nil, false, err
+		}
+		ready = append(ready, more...)
+	}
+	return ready, kept, nil
 }
 
 // Publishing activity.
-// Represents an infinite loop that periodically posts queued events to Ingestion API.
-// Used in a background thread.
+// Represents an infinite loop that periodically wakes up idle workers
+// posting queued events to Ingestion API. Used in a background thread.
 func (c *Client) publishing() {
+	wakeup := make(chan struct{}, c.config.PublishWorkers)
+	for i := 0; i < c.config.PublishWorkers; i++ {
+		go c.publishingWorker(wakeup)
+	}
+
 	for {
+		c.FlushMetrics()
+		if summary := c.limiter.report(); summary != nil {
+			c.record(summary, rateLimitFacets...)
+		}
 		if c.queue.Count() >= c.config.MinBufferSize {
-			c.queue.Flush(c.publisher.Post)
+			for i := 0; i < c.config.PublishWorkers; i++ {
+				select {
+				case wakeup <- struct{}{}:
+				default: // all workers are already woken up
+				}
+			}
 		}
 		time.Sleep(time.Duration(c.config.PublishInterval) * time.Millisecond)
 	}
 }
+
+// Publishing worker. When woken up, it posts batches of queued events
+// until there are none left or a post fails.
+func (c *Client) publishingWorker(wakeup <-chan struct{}) {
+	for range wakeup {
+		for ok := c.publishBatch(true); ok; ok = c.publishBatch(false) {
+		}
+	}
+}
+
+// Takes a batch of queued events and posts it. An empty queue
+// is posted as an empty batch if it's the first batch after wakeup.
+// Returns false if there was nothing to post or the post failed.
+func (c *Client) publishBatch(first bool) bool {
+	limit := c.config.MaxBatchSize
+	if limit == 0 {
+		workers := int64(c.config.PublishWorkers)
+		limit = (c.queue.pendingCount() + workers - 1) / workers
+	}
+	var key func(Event) string
+	if c.config.PreserveSourceOrder {
+		key = func(e Event) string {
+			sid, _ := e["sourceId"].(string)
+			return sid
+		}
+	}
+
+	events, positions := c.queue.take(limit, key)
+	if len(events) == 0 && !(first && c.queue.IsEmpty()) {
+		return false
+	}
+	ok := c.post(events)
+	c.queue.ack(positions, ok)
+	return ok && len(events) > 0
+}
+
+// Posts events and counts the result.
+func (c *Client) post(events []Event) bool {
+	ok := c.publisher.Post(events)
+	if ok {
+		atomic.AddUint64(&c.published, uint64(len(events)))
+	} else {
+		atomic.AddUint64(&c.failed, uint64(len(events)))
+	}
+	return ok
+}
+
+// Returns sourceId of events generated by the client itself.
+func clientSourceId(config Config) string {
+	if config.SourceId != "" {
+		return config.SourceId
+	}
+	return ClientSourceId
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -384,3 +385,194 @@ func TestClient_Posting_PostsOnlyWhenQueueThresholdIsReached(t *testing.T) {
 		}
 	}
 }
+
+// Publisher blocking posts of batches with the given sourceId until released.
+type blockingPublisher struct {
+	blockSourceId string
+	release       chan struct{}
+	posted        []string
+	sync.Mutex
+}
+
+func (p *blockingPublisher) Post(events []Event) bool {
+	if len(events) > 0 && events[0]["sourceId"] == p.blockSourceId {
+		<-p.release
+	}
+	p.Lock()
+	defer p.Unlock()
+	for _, event := range events {
+		p.posted = append(p.posted, event["eventName"].(string))
+	}
+	return true
+}
+
+// Returns posted event names once there are at least n of them.
+func (p *blockingPublisher) waitFor(n int) []string {
+	for i := 0; i < 100; i++ {
+		p.Lock()
+		posted := append([]string(nil), p.posted...)
+		p.Unlock()
+		if len(posted) >= n {
+			return posted
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func newWorkersClient(t *testing.T, publisher IPublisher, preserveOrder bool) *Client {
+	config := NewConfig()
+	config.Url = "http://foo.bar"
+	config.StartPublishingThread = false
+	config.PublishInterval = 20
+	config.MinBufferSize = 1
+	config.PublishWorkers = 2
+	config.MaxBatchSize = 1
+	config.PreserveSourceOrder = preserveOrder
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.publisher = publisher
+	return client
+}
+
+func TestClient_Flush_ZeroWorkersMeansOne(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://foo.bar"
+	config.StartPublishingThread = false
+	config.PublishWorkers = 0
+	posted := 0
+	client, err := NewClientWithPublisher(config, &PublisherMock{
+		fakePost: func(events []Event) bool {
+			posted += len(events)
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RecordEvent(Event{"eventName": "a", "sourceId": "s"})
+	client.RecordEvent(Event{"eventName": "b", "sourceId": "s"})
+
+	if !client.Flush() || posted != 2 {
+		t.Errorf("Events should be published by a single worker. Got %d", posted)
+	}
+}
+
+func TestClient_Publishing_SlowBatchDoesNotHoldBackOthers(t *testing.T) {
+	publisher := &blockingPublisher{blockSourceId: "slow", release: make(chan struct{})}
+	client := newWorkersClient(t, publisher, false)
+	client.RecordEvent(Event{"eventName": "a", "sourceId": "slow"})
+	client.RecordEvent(Event{"eventName": "b", "sourceId": "fast"})
+	client.RecordEvent(Event{"eventName": "c", "sourceId": "fast"})
+	go client.publishing()
+
+	if posted := publisher.waitFor(2); !reflect.DeepEqual(posted, []string{"b", "c"}) {
+		t.Errorf("Other batches should be posted while one is in flight. Got %v", posted)
+	}
+	if client.queue.Count() != 3 {
+		t.Errorf("Events should be kept until the batch in flight is posted. Got %d", client.queue.Count())
+	}
+
+	close(publisher.release)
+	publisher.waitFor(3)
+	time.Sleep(10 * time.Millisecond)
+	if !client.queue.IsEmpty() {
+		t.Errorf("Posted events should be deleted from the queue. Got %d", client.queue.Count())
+	}
+}
+
+func TestClient_Publishing_PreservesSourceOrder(t *testing.T) {
+	publisher := &blockingPublisher{blockSourceId: "slow", release: make(chan struct{})}
+	client := newWorkersClient(t, publisher, true)
+	client.RecordEvent(Event{"eventName": "a", "sourceId": "slow"})
+	client.RecordEvent(Event{"eventName": "b", "sourceId": "slow"})
+	client.RecordEvent(Event{"eventName": "c", "sourceId": "fast"})
+	go client.publishing()
+
+	publisher.waitFor(1)
+	time.Sleep(50 * time.Millisecond)
+	publisher.Lock()
+	posted := append([]string(nil), publisher.posted...)
+	publisher.Unlock()
+	if !reflect.DeepEqual(posted, []string{"c"}) {
+		t.Errorf("Events of a source with a batch in flight should wait. Got %v", posted)
+	}
+
+	close(publisher.release)
+	if posted := publisher.waitFor(3); !reflect.DeepEqual(posted, []string{"c", "a", "b"}) {
+		t.Errorf("Events of a source should be posted in order. Got %v", posted)
+	}
+}
+
+func TestClient_Flush(t *testing.T) {
+	var posted []Event
+	ok := true
+	config := NewConfig()
+	config.Url = "http://foo.bar"
+	config.StartPublishingThread = false
+	config.SourceId = "s"
+	client, err := NewClientWithPublisher(config, &PublisherMock{
+		fakePost: func(events []Event) bool {
+			if ok {
+				posted = append(posted, events...)
+			}
+			return ok
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.RecordEvent(Event{"eventName": "a"})
+	client.RecordEvent(Event{"eventName": "b"})
+	ok = false
+	if client.Flush() {
+		t.Error("Flush should fail if events can't be published")
+	}
+	if stats := client.Stats(); stats.Buffered != 2 || stats.Published != 0 || stats.Failed != 2 {
+		t.Errorf("Failed events should stay buffered. Got %+v", stats)
+	}
+
+	ok = true
+	if !client.Flush() || len(posted) != 2 {
+		t.Errorf("Buffered events should be published. Got %v", posted)
+	}
+	if stats := client.Stats(); stats.Buffered != 0 || stats.Published != 2 {
+		t.Errorf("Published events should be counted. Got %+v", stats)
+	}
+}
+
+func TestClient_Flush_WaitsForBatchesInFlight(t *testing.T) {
+	publisher := &blockingPublisher{blockSourceId: "slow", release: make(chan struct{})}
+	client := newWorkersClient(t, publisher, false)
+	client.RecordEvent(Event{"eventName": "a", "sourceId": "slow"})
+	client.RecordEvent(Event{"eventName": "b", "sourceId": "fast"})
+
+	taken := make(chan struct{})
+	go func() {
+		events, positions := client.queue.take(1, nil)
+		close(taken)
+		client.queue.ack(positions, client.post(events))
+	}()
+	<-taken
+
+	flushed := make(chan bool)
+	go func() { flushed <- client.Flush() }()
+	select {
+	case <-flushed:
+		t.Fatal("Flush should wait for the batch in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(publisher.release)
+	select {
+	case ok := <-flushed:
+		if !ok || !client.queue.IsEmpty() {
+			t.Errorf("All events should be published. Got %v", publisher.waitFor(2))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Flush should return once the batch in flight is posted")
+	}
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// STATUS_PATH is Samsara Ingestion API health-check endpoint.
+// It is used to probe the server clock.
+const STATUS_PATH = "/v1/api-status"
+
+// ClockOffsetField is the facet with estimated offset of the local clock in milliseconds.
+const ClockOffsetField = "clockOffset"
+
+// ClockSkewMode tells how to handle the estimated skew of the local clock.
+type ClockSkewMode string
+
+// Clock skew modes.
+const (
+	// Local clock is trusted, the skew isn't estimated.
+	ClockSkewNone ClockSkewMode = "none"
+	// Estimated offset is attached to published events as `clockOffset` facet.
+	ClockSkewFacet ClockSkewMode = "facet"
+	// Timestamps of published events are corrected by the estimated offset.
+	ClockSkewCorrect ClockSkewMode = "correct"
+)
+
+// Number of recent offset samples the estimate is the median of.
+const clockSkewSamples = 8
+
+// Offsets below resolution of the HTTP Date header are ignored.
+const clockSkewResolution = 1000
+
+// Minimum interval between probes of the server clock in milliseconds,
+// so that a server without the Date header isn't probed before every post.
+const clockSkewProbeInterval = 60000
+
+// Estimator of the local clock offset from the server clock,
+// sampled from the `Date` header of Ingestion API responses.
+type clockSkew struct {
+	mode     ClockSkewMode
+	samples  []int64
+	next     int
+	probedAt int64
+	sync.Mutex
+}
+
+// Creates new clock skew estimator. Returns nil if skew should not be estimated.
+func newClockSkew(config Config) *clockSkew {
+	if config.ClockSkew == ClockSkewNone {
+		return nil
+	}
+	return &clockSkew{mode: config.ClockSkew}
+}
+
+// Adds a sample of the offset from the response to a request
+// sent and received at the given local times.
+func (c *clockSkew) observe(sentTs, receivedTs int64, resp *http.Response) {
+	if c == nil {
+		return
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// Date has a second precision, the server time is within the second
+	serverTs := date.UnixNano()/int64(time.Millisecond) + clockSkewResolution/2
+	offset := serverTs - (sentTs+receivedTs)/2
+
+	c.Lock()
+	defer c.Unlock()
+	if len(c.samples) < clockSkewSamples {
+		c.samples = append(c.samples, offset)
+	} else {
+		c.samples[c.next] = offset
+	}
+	c.next = (c.next + 1) % clockSkewSamples
+}
+
+// Returns the estimated offset in milliseconds to add to local timestamps
+// and whether there are any samples yet.
+func (c *clockSkew) offset() (int64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.Lock()
+	defer c.Unlock()
+	if len(c.samples) == 0 {
+		return 0, false
+	}
+
+	sorted := append([]int64(nil), c.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	median := sorted[len(sorted)/2]
+	if -clockSkewResolution < median && median < clockSkewResolution {
+		return 0, true
+	}
+	return median, true
+}
+
+// Returns the event with the estimated offset applied according to the mode.
+// The event is copied, so that retried events aren't corrected twice.
+func (c *clockSkew) apply(offset int64, event Event) Event {
+	copied := make(Event, len(event)+1)
+	for k, v := range event {
+		copied[k] = v
+	}
+	if c.mode == ClockSkewFacet {
+		copied[ClockOffsetField] = offset
+	} else if ts, ok := event["timestamp"].(int64); ok {
+		copied["timestamp"] = ts + offset
+	}
+	return copied
+}
+
+// Returns the local time, corrected by the offset if timestamps are corrected.
+// It's published along with events, so that the server compares it with
+// their timestamps and its own clock consistently.
+func (c *clockSkew) timestamp(offset int64) int64 {
+	if c != nil && c.mode == ClockSkewCorrect {
+		return Timestamp() + offset
+	}
+	return Timestamp()
+}
+
+// Probes the server clock with a health-check request, unless it has already
+// been sampled or probed recently.
+func (c *clockSkew) probe(client *http.Client, url string) {
+	if c == nil {
+		return
+	}
+	if _, ok := c.offset(); ok {
+		return
+	}
+	sentTs := Timestamp()
+	c.Lock()
+	if c.probedAt != 0 && sentTs-c.probedAt < clockSkewProbeInterval {
+		c.Unlock()
+		return
+	}
+	c.probedAt = sentTs
+	c.Unlock()
+
+	resp, err := client.Get(strings.Trim(url, "/") + STATUS_PATH)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	c.observe(sentTs, Timestamp(), resp)
+}
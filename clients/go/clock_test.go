@@ -0,0 +1,163 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func dateResponse(serverTs int64) *http.Response {
+	resp := &http.Response{Header: http.Header{}}
+	date := time.Unix(0, serverTs*int64(time.Millisecond)).UTC()
+	resp.Header.Set("Date", date.Format(http.TimeFormat))
+	return resp
+}
+
+func TestClockSkew_DisabledByDefault(t *testing.T) {
+	c := newClockSkew(NewConfig())
+	c.observe(0, 0, dateResponse(1000000))
+
+	if offset, ok := c.offset(); ok || offset != 0 {
+		t.Errorf("Offset should not be estimated. Got %d", offset)
+	}
+}
+
+func TestClockSkew_Offset(t *testing.T) {
+	sets := []struct {
+		samples [][3]int64 // sentTs, receivedTs, serverTs
+		want    int64
+	}{
+		// server time is estimated in the middle of the Date second
+		{[][3]int64{{100000, 100200, 160000}}, 60400},
+		{[][3]int64{{100000, 100200, 40000}}, -59600},
+		// offsets below the Date resolution are ignored
+		{[][3]int64{{100000, 100000, 100000}}, 0},
+		{[][3]int64{{100000, 100000, 99000}}, 0},
+		// median of samples filters out slow responses
+		{[][3]int64{
+			{100000, 100000, 160000},
+			{200000, 230000, 260000},
+			{300000, 300000, 360000},
+		}, 60500},
+	}
+
+	for i, set := range sets {
+		c := &clockSkew{mode: ClockSkewFacet}
+		for _, s := range set.samples {
+			c.observe(s[0], s[1], dateResponse(s[2]))
+		}
+		if offset, ok := c.offset(); !ok || offset != set.want {
+			t.Errorf("Set #%d. Want: %d Got: %d", i, set.want, offset)
+		}
+	}
+}
+
+func TestClockSkew_KeepsRecentSamples(t *testing.T) {
+	c := &clockSkew{mode: ClockSkewFacet}
+	for i := 0; i < clockSkewSamples; i++ {
+		c.observe(100000, 100000, dateResponse(160000))
+	}
+	for i := 0; i < clockSkewSamples; i++ {
+		c.observe(100000, 100000, dateResponse(130000))
+	}
+
+	if len(c.samples) != clockSkewSamples {
+		t.Errorf("Only %d samples should be kept. Got %d", clockSkewSamples, len(c.samples))
+	}
+	if offset, _ := c.offset(); offset != 30500 {
+		t.Errorf("Offset should follow recent samples. Got %d", offset)
+	}
+}
+
+func TestClockSkew_Apply(t *testing.T) {
+	sets := []struct {
+		mode ClockSkewMode
+		want Event
+	}{
+		{ClockSkewFacet, Event{"eventName": "a", "timestamp": int64(1000), "clockOffset": int64(60500)}},
+		{ClockSkewCorrect, Event{"eventName": "a", "timestamp": int64(61500)}},
+	}
+
+	for i, set := range sets {
+		c := &clockSkew{mode: set.mode}
+		c.observe(100000, 100000, dateResponse(160000))
+		offset, _ := c.offset()
+		event := Event{"eventName": "a", "timestamp": int64(1000)}
+
+		if got := c.apply(offset, event); !reflect.DeepEqual(got, set.want) {
+			t.Errorf("Set #%d. Want: %+v Got: %+v", i, set.want, got)
+		}
+		if event["timestamp"] != int64(1000) || len(event) != 2 {
+			t.Errorf("Set #%d. Original event should not be changed. Got %+v", i, event)
+		}
+	}
+}
+
+func TestPublisher_Post_CorrectsClockSkew(t *testing.T) {
+	var paths []string
+	var received []Event
+	var published string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		ahead := time.Now().Add(time.Hour).UTC()
+		w.Header().Set("Date", ahead.Format(http.TimeFormat))
+		if r.Method == "POST" {
+			if received == nil {
+				json.NewDecoder(r.Body).Decode(&received)
+				published = r.Header.Get(PUBLISHED_TIMESTAMP_HEADER)
+			}
+			w.WriteHeader(202)
+		}
+	}))
+	defer mockServer.Close()
+
+	config := NewConfig()
+	config.Url = mockServer.URL
+	config.Compression = "none"
+	config.ClockSkew = ClockSkewCorrect
+	publisher := Publisher{config: config, clock: newClockSkew(config)}
+
+	now := Timestamp()
+	if !publisher.Post([]Event{{"sourceId": "foo", "eventName": "baz", "timestamp": now}}) {
+		t.Fatal("Events should be published")
+	}
+	publisher.Post([]Event{})
+
+	if !reflect.DeepEqual(paths, []string{STATUS_PATH, API_PATH, API_PATH}) {
+		t.Errorf("Server clock should be probed only before the first post. Got %v", paths)
+	}
+	ts := int64(received[0]["timestamp"].(float64))
+	if diff := ts - now - time.Hour.Nanoseconds()/1e6; diff < -2000 || diff > 2000 {
+		t.Errorf("Timestamp should be corrected by an hour. Got %d for %d", ts, now)
+	}
+	publishedTs, _ := strconv.ParseInt(published, 10, 64)
+	if publishedTs < ts {
+		t.Errorf("Published timestamp should be corrected as well. Got %d for %d", publishedTs, ts)
+	}
+}
+
+func TestClockSkew_Probe_IsRateLimited(t *testing.T) {
+	var probes int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+		probes++
+	}))
+	defer mockServer.Close()
+
+	c := &clockSkew{mode: ClockSkewCorrect}
+	c.probe(mockServer.Client(), mockServer.URL)
+	c.probe(mockServer.Client(), mockServer.URL)
+	if probes != 1 {
+		t.Errorf("Server without Date header should not be probed again. Got %d probes", probes)
+	}
+
+	c.probedAt -= clockSkewProbeInterval
+	c.probe(mockServer.Client(), mockServer.URL)
+	if probes != 2 {
+		t.Errorf("Server should be probed again after the interval. Got %d probes", probes)
+	}
+}
@@ -0,0 +1,63 @@
+// Command samsara-agent is a local daemon forwarding events to Samsara.
+//
+//	samsara-agent -config /etc/samsara-agent.json
+//
+// SIGINT and SIGTERM stop the agent after buffered events are forwarded,
+// SIGHUP forwards them immediately.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/samsara/samsara/clients/go/agent"
+)
+
+func main() {
+	path := flag.String("config", "", "path to the JSON configuration file")
+	url := flag.String("url", "", "Samsara Ingestion API url, overrides Client.Url of the configuration")
+	flag.Parse()
+
+	config := agent.NewConfig()
+	if *path != "" {
+		var err error
+		if config, err = agent.LoadConfig(*path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *url != "" {
+		config.Client.Url = *url
+	}
+
+	a, err := agent.New(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := a.Start(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("samsara-agent forwarding to %s", config.Client.Url)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			if err := a.Flush(); err != nil {
+				log.Printf("forwarding failed: %v", err)
+			}
+			continue
+		}
+
+		log.Printf("%v received, stopping", sig)
+		err := a.Stop()
+		stats := a.Stats()
+		log.Printf("received: %d, rejected: %d, forwarded: %d", stats.Received, stats.Rejected, stats.Forwarded)
+		if err != nil {
+			log.Fatalf("forwarding failed: %v", err)
+		}
+		return
+	}
+}
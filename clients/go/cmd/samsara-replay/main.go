@@ -0,0 +1,71 @@
+// Command samsara-replay publishes events captured to files back to Samsara.
+//
+//	samsara-replay -url http://samsara:9000 -checkpoint replay.json /var/spool/samsara
+//
+// Arguments are NDJSON or JSON array files, optionally gzipped,
+// or directories with them. Rejected events are reported to stderr.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/replay"
+)
+
+func main() {
+	defaults := replay.NewOptions(nil)
+	config := client.NewConfig()
+
+	url := flag.String("url", "", "Samsara Ingestion API url")
+	compression := flag.String("compression", config.Compression, "compression of requests")
+	timeout := flag.Uint("timeout", uint(config.SendTimeout), "request timeout in milliseconds")
+	batch := flag.Int("batch", defaults.BatchSize, "number of events in a request")
+	rate := flag.Float64("rate", 0, "max events per second, 0 for unlimited")
+	retries := flag.Int("retries", defaults.Retries, "retries of a failed request")
+	retryDelay := flag.Duration("retry-delay", defaults.RetryDelay, "delay between retries")
+	checkpoint := flag.String("checkpoint", "", "file to save progress to, so that a stopped replay can be resumed")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] file|dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	config.Url = *url
+	config.Compression = *compression
+	config.SendTimeout = uint32(*timeout)
+	if err := config.Validate(); err != nil {
+		fail(err)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	options := replay.NewOptions(client.NewPublisher(config))
+	options.BatchSize = *batch
+	options.Rate = *rate
+	options.Retries = *retries
+	options.RetryDelay = *retryDelay
+	options.CheckpointFile = *checkpoint
+	options.OnReject = func(file string, event client.Event, err error) {
+		fmt.Fprintf(os.Stderr, "rejected %s: %v %v\n", file, err, event)
+	}
+
+	start := time.Now()
+	summary, err := replay.Replay(flag.Args(), options)
+	fmt.Printf("files: %d, accepted: %d, rejected: %d, failed: %d, skipped: %d, took: %v\n",
+		summary.Files, summary.Accepted, summary.Rejected, summary.Failed, summary.Skipped,
+		time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
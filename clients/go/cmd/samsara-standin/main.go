@@ -0,0 +1,137 @@
+// Command samsara-standin serves a local Ingestion API which misbehaves
+// on demand, to test resilience of services publishing events.
+//
+//	samsara-standin -addr :9000 -random reset=0.05,unavailable=0.1 -status-random unavailable=0.5
+//
+// Faults are none, latency, error, unavailable, reset, partial-read and
+// slow-body. Scripted faults are injected into successive requests,
+// random ones afterwards. Requests are logged to stdout.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/samsaratest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	script := flag.String("script", "", "comma separated faults of successive POST /v1/events requests")
+	random := flag.String("random", "", "comma separated fault=probability of POST /v1/events requests after the script")
+	statusScript := flag.String("status-script", "", "comma separated faults of successive GET /v1/api-status requests")
+	statusRandom := flag.String("status-random", "", "comma separated fault=probability of GET /v1/api-status requests after the script")
+	loop := flag.Bool("loop", false, "repeat the scripts instead of drawing random faults after them")
+	latency := flag.Duration("latency", time.Second, "delay of latency and slow-body faults")
+	status := flag.Int("error-status", http.StatusInternalServerError, "status code of error faults")
+	seed := flag.Int64("seed", 0, "seed of random faults, 0 for a random one")
+	flag.Parse()
+
+	server := &samsaratest.Server{}
+	server.SetCapture(false)
+	paths := []struct{ path, script, random string }{
+		{client.API_PATH, *script, *random},
+		{client.STATUS_PATH, *statusScript, *statusRandom},
+	}
+	for _, p := range paths {
+		faults := samsaratest.Faults{Loop: *loop, Latency: *latency, Status: *status, Seed: *seed}
+		var err error
+		if faults.Script, err = parseScript(p.script); err != nil {
+			fail(err)
+		}
+		if faults.Random, err = parseRandom(p.random); err != nil {
+			fail(err)
+		}
+		server.SetFaults(p.path, faults)
+	}
+
+	log.SetOutput(os.Stdout)
+	log.Printf("Ingestion API stand-in listening on %s", *addr)
+	fail(http.ListenAndServe(*addr, logging(server)))
+}
+
+// Parses comma separated names of faults.
+func parseScript(value string) ([]samsaratest.Fault, error) {
+	var faults []samsaratest.Fault
+	for _, name := range split(value) {
+		fault, ok := samsaratest.ParseFault(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+// Parses comma separated fault=probability pairs.
+func parseRandom(value string) (map[samsaratest.Fault]float64, error) {
+	faults := make(map[samsaratest.Fault]float64)
+	for _, pair := range split(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		fault, ok := samsaratest.ParseFault(parts[0])
+		if !ok {
+			return nil, fmt.Errorf("unknown fault %q", parts[0])
+		}
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("missing probability of %s", parts[0])
+		}
+		probability, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || probability < 0 || probability > 1 {
+			return nil, fmt.Errorf("invalid probability of %s: %s", parts[0], parts[1])
+		}
+		faults[fault] = probability
+	}
+	return faults, nil
+}
+
+func split(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// Logs requests with their response status.
+func logging(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(sw, r)
+		status := strconv.Itoa(sw.status)
+		if sw.hijacked {
+			status = "reset"
+		}
+		log.Printf("%s %s %s %v", r.Method, r.URL.Path, status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// ResponseWriter remembering the status code.
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	hijacked bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
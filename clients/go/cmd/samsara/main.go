@@ -0,0 +1,272 @@
+// Command samsara sends events to Samsara and validates files of events.
+//
+//	samsara send --url http://samsara:9000 --name user.logged.in --facet userId=42
+//	samsara send --url http://samsara:9000 --file events.ndjson
+//	producer | samsara send --url http://samsara:9000
+//	samsara validate events.ndjson...
+//	samsara status --url http://samsara:9000
+//
+// The url defaults to $SAMSARA_URL.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Max size of a line of a validated file.
+const maxLineSize = 10 * 1024 * 1024
+
+var commands = []struct {
+	name, usage string
+	run         func(args []string) error
+}{
+	{"send", "send events built from flags, a JSON file or stdin", send},
+	{"validate", "validate NDJSON files of events", validate},
+	{"status", "query status of the Ingestion API", status},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, command := range commands {
+		if command.name == os.Args[1] {
+			if err := command.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, command := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", command.name, command.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for flags of the command.\n", os.Args[0])
+	os.Exit(2)
+}
+
+// Registers flags of the client configuration. The returned function
+// completes the configuration after the flags are parsed.
+func clientFlags(flags *flag.FlagSet) func() client.Config {
+	config := client.NewConfig()
+	flags.StringVar(&config.Url, "url", os.Getenv("SAMSARA_URL"), "Samsara Ingestion API url")
+	flags.StringVar(&config.Compression, "compression", config.Compression, "compression of requests")
+	flags.StringVar(&config.SourceId, "source-id", "", "sourceId of events without one")
+	timeout := flags.Uint("timeout", uint(config.SendTimeout), "request timeout in milliseconds")
+	return func() client.Config {
+		config.SendTimeout = uint32(*timeout)
+		config.StartPublishingThread = false
+		return config
+	}
+}
+
+// Facets given as repeated name=value flags.
+type facets client.Event
+
+func (f facets) String() string {
+	return fmt.Sprint(client.Event(f))
+}
+
+// Set parses name=value. Integers, floats and booleans are converted,
+// other values are strings.
+func (f facets) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.New("facet should be name=value")
+	}
+	f[parts[0]] = parseValue(parts[1])
+	return nil
+}
+
+// Converts a facet value given on the command line.
+func parseValue(value string) interface{} {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return value
+}
+
+// Sends an event built from flags, or events from a file or stdin.
+func send(args []string) error {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	configure := clientFlags(flags)
+	name := flags.String("name", "", "eventName of an event built from flags")
+	timestamp := flags.Int64("timestamp", 0, "timestamp of an event built from flags in milliseconds, default is now")
+	event := facets{}
+	flags.Var(event, "facet", "facet of an event built from flags as name=value, can be repeated")
+	file := flags.String("file", "-", "JSON or NDJSON file with events, - for stdin")
+	flags.Parse(args)
+
+	var events []client.Event
+	if *name != "" {
+		event["eventName"] = *name
+		if *timestamp != 0 {
+			event["timestamp"] = *timestamp
+		}
+		events = append(events, client.Event(event))
+	} else {
+		var err error
+		if events, err = readEvents(*file); err != nil {
+			return err
+		}
+	}
+	if len(events) == 0 {
+		return errors.New("no events to send")
+	}
+
+	config := configure()
+	c, err := client.NewClient(config)
+	if err != nil {
+		return err
+	}
+
+	ok, err := c.PublishEvents(events)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("%d events couldn't be sent to %s", len(events), config.Url)
+	}
+	fmt.Printf("sent %d events\n", len(events))
+	return nil
+}
+
+// Reads events from the file, or stdin if it's "-".
+func readEvents(path string) ([]client.Event, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var events []client.Event
+	err := client.DecodeEvents(r, func(event client.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// Validates files with an event per line, reporting invalid lines.
+func validate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s validate file...\n", os.Args[0])
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	invalid := 0
+	for _, path := range flags.Args() {
+		valid, failed, err := validateFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d valid, %d invalid events\n", path, valid, failed)
+		invalid += failed
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid events", invalid)
+	}
+	return nil
+}
+
+// Validates events of the file, printing errors of invalid ones.
+// Returns numbers of valid and invalid events.
+func validateFile(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	valid, invalid := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if err := validateLine(text); err != nil {
+			fmt.Printf("%s:%d: %v\n", path, line, err)
+			invalid++
+		} else {
+			valid++
+		}
+	}
+	return valid, invalid, scanner.Err()
+}
+
+// Validates a line with an event by the rules of the client.
+// The line is decoded as NDJSON, which rejects data after the event.
+func validateLine(line string) error {
+	if !strings.HasPrefix(line, "{") {
+		return errors.New("line should be a JSON object")
+	}
+	event, err := client.NewEventDecoder(strings.NewReader(line)).Decode()
+	if err != nil {
+		return err
+	}
+	return event.Validate()
+}
+
+// Queries GET /v1/api-status. Fails unless the API is online.
+func status(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	configure := clientFlags(flags)
+	flags.Parse(args)
+
+	config := configure()
+	if config.Url == "" {
+		return client.ConfigValidationError{Message: "URL for Ingestion API should be specified."}
+	}
+	httpClient := &http.Client{Timeout: time.Duration(config.SendTimeout) * time.Millisecond}
+	start := time.Now()
+	resp, err := httpClient.Get(strings.Trim(config.Url, "/") + client.STATUS_PATH)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct{ Status string }
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Status == "" {
+		body.Status = "unknown"
+	}
+	fmt.Printf("%s: %s (%s) in %v\n", config.Url, body.Status, resp.Status, time.Since(start).Round(time.Millisecond))
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Ingestion API is %s", body.Status)
+	}
+	return nil
+}
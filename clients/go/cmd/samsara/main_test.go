@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samsara/samsara/clients/go/samsaratest"
+)
+
+func TestValidateLine(t *testing.T) {
+	sets := []struct {
+		line  string
+		valid bool
+	}{
+		{`{"eventName":"a","sourceId":"s","timestamp":1}`, true},
+		{`{"eventName":"a","sourceId":"s","timestamp":1}   `, true},
+		{`{"eventName":"a","sourceId":"s"}`, false},
+		{`{"eventName":"a","sourceId":"s","timestamp":1.5}`, false},
+		{`[{"eventName":"a","sourceId":"s","timestamp":1}]`, false},
+		{`{"eventName":"a","sourceId":"s","timestamp":1} garbage`, false},
+		{`{"eventName":"a","sourceId":"s","timestamp":1}{"eventName":"b"}`, false},
+		{`{"eventName":`, false},
+	}
+
+	for i, set := range sets {
+		if err := validateLine(set.line); (err == nil) != set.valid {
+			t.Errorf("Set #%d. Line %s should be valid: %t. Got %v", i, set.line, set.valid, err)
+		}
+	}
+}
+
+// Replaces stdin with the given input for the duration of the test.
+func setStdin(t *testing.T, input string) {
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestSend(t *testing.T) {
+	server := samsaratest.NewServer()
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "events.ndjson")
+	os.WriteFile(file, []byte("{\"eventName\":\"file.a\",\"sourceId\":\"s\",\"timestamp\":1}\n{\"eventName\":\"file.b\",\"sourceId\":\"s\",\"timestamp\":2}\n"), 0644)
+
+	sets := []struct {
+		args  []string
+		stdin string
+		want  []samsaratest.Matcher
+	}{
+		{
+			[]string{"--name", "user.logged.in", "--facet", "userId=42", "--source-id", "s", "--timestamp", "1"},
+			"",
+			[]samsaratest.Matcher{{EventName: "user.logged.in", Facets: map[string]interface{}{"userId": int64(42), "sourceId": "s", "timestamp": int64(1)}}},
+		},
+		{
+			[]string{"--file", file},
+			"",
+			[]samsaratest.Matcher{{EventName: "file.a"}, {EventName: "file.b"}},
+		},
+		{
+			nil,
+			`[{"eventName":"stdin.a","sourceId":"s","timestamp":1}]`,
+			[]samsaratest.Matcher{{EventName: "stdin.a"}},
+		},
+	}
+
+	for i, set := range sets {
+		server.Reset()
+		setStdin(t, set.stdin)
+		if err := send(append([]string{"--url", server.URL}, set.args...)); err != nil {
+			t.Errorf("Set #%d. Events should be sent. Got %v", i, err)
+			continue
+		}
+		events := server.WaitForEvents(t, len(set.want), time.Second)
+		if len(events) != len(set.want) {
+			t.Errorf("Set #%d. Want %d events, Got %v", i, len(set.want), events)
+			continue
+		}
+		for j, m := range set.want {
+			if !m.Match(events[j]) {
+				t.Errorf("Set #%d. Event %v should match %v", i, events[j], m)
+			}
+		}
+	}
+}
+
+func TestSend_ReportsNoEventsWithoutUrl(t *testing.T) {
+	t.Setenv("SAMSARA_URL", "")
+	setStdin(t, "")
+
+	if err := send(nil); err == nil || err.Error() != "no events to send" {
+		t.Errorf("Missing events should be reported. Got %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	server := samsaratest.NewServer()
+	defer server.Close()
+
+	sets := []struct {
+		online bool
+		ok     bool
+	}{
+		{true, true},
+		{false, false},
+	}
+
+	for i, set := range sets {
+		server.SetOnline(set.online)
+		if err := status([]string{"--url", server.URL}); (err == nil) != set.ok {
+			t.Errorf("Set #%d. Status of online: %t API should succeed: %t. Got %v", i, set.online, set.ok, err)
+		}
+	}
+}
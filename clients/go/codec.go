@@ -0,0 +1,215 @@
+package client
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Codec compresses payloads sent to Ingestion API.
+type Codec interface {
+	// ContentEncoding returns the value of the Content-Encoding header.
+	ContentEncoding() string
+
+	// NewWriter returns a writer compressing data written to it into w.
+	// Close flushes compressed data, it doesn't close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// Codecs registered by name.
+var codecs = struct {
+	byName map[string]Codec
+	sync.RWMutex
+}{byName: make(map[string]Codec)}
+
+// Built-in codecs supporting Config.CompressionLevel.
+var leveledCodecs = map[string]func(level int) Codec{
+	"gzip":    GzipCodec,
+	"deflate": ZlibCodec,
+	"zlib":    ZlibCodec,
+}
+
+// Built-in codecs created with a compression level other than the default, by name and level.
+var configuredCodecs = struct {
+	byLevel map[string]map[int]Codec
+	sync.Mutex
+}{byLevel: make(map[string]map[int]Codec)}
+
+func init() {
+	RegisterCodec("none", IdentityCodec())
+	RegisterCodec("gzip", GzipCodec(gzip.DefaultCompression))
+	RegisterCodec("deflate", ZlibCodec(zlib.DefaultCompression))
+	// zlib is an alias of deflate, which is zlib format per HTTP specification
+	RegisterCodec("zlib", ZlibCodec(zlib.DefaultCompression))
+}
+
+// RegisterCodec makes the codec available as Config.Compression option
+// under the given name. It replaces a codec registered under the same name.
+func RegisterCodec(name string, codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.byName[name] = codec
+}
+
+// LookupCodec returns the codec registered under the given name.
+func LookupCodec(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	codec, ok := codecs.byName[name]
+	return codec, ok
+}
+
+// Decompress returns a reader decompressing r of the given Content-Encoding.
+// Deflate is accepted both zlib-wrapped, per HTTP specification, and raw.
+func Decompress(encoding string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(encoding) {
+	case "", "identity", "none":
+		return r, nil
+	case "gzip":
+		return gzip.NewReader(r)
+	case "deflate", "zlib":
+		buffered := bufio.NewReader(r)
+		header, _ := buffered.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	}
+	return nil, EncodingError{"Unsupported content encoding " + encoding}
+}
+
+// Tells whether a codec is registered under the given name.
+func validCodec(name string) bool {
+	_, ok := LookupCodec(name)
+	return ok
+}
+
+// Returns the codec of Config.Compression at Config.CompressionLevel.
+// Codecs are created once per level, so that their writers are pooled.
+func configuredCodec(config Config) (Codec, bool) {
+	if config.CompressionLevel == 0 {
+		return LookupCodec(config.Compression)
+	}
+	create, ok := leveledCodecs[config.Compression]
+	if !ok {
+		return nil, false
+	}
+
+	configuredCodecs.Lock()
+	defer configuredCodecs.Unlock()
+	byLevel := configuredCodecs.byLevel[config.Compression]
+	if byLevel == nil {
+		byLevel = make(map[int]Codec)
+		configuredCodecs.byLevel[config.Compression] = byLevel
+	}
+	codec, ok := byLevel[config.CompressionLevel]
+	if !ok {
+		codec = create(config.CompressionLevel)
+		byLevel[config.CompressionLevel] = codec
+	}
+	return codec, true
+}
+
+// IdentityCodec returns codec sending payloads uncompressed.
+func IdentityCodec() Codec {
+	return identityCodec{}
+}
+
+// Codec without compression.
+type identityCodec struct{}
+
+// ContentEncoding returns "identity".
+func (identityCodec) ContentEncoding() string {
+	return "identity"
+}
+
+// NewWriter returns w with no-op Close.
+func (identityCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopCloser{w}, nil
+}
+
+// Writer with no-op Close.
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopCloser) Close() error {
+	return nil
+}
+
+// GzipCodec returns codec compressing payloads with gzip at the given level.
+// Writers are pooled, so that their buffers are reused between batches.
+func GzipCodec(level int) Codec {
+	return &pooledCodec{
+		encoding: "gzip",
+		create: func(w io.Writer) (resettableWriter, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+	}
+}
+
+// ZlibCodec returns codec compressing payloads into zlib stream at the given level.
+// It is sent as "deflate" encoding, which is zlib format per HTTP specification.
+// Writers are pooled, so that their buffers are reused between batches.
+func ZlibCodec(level int) Codec {
+	return &pooledCodec{
+		encoding: "deflate",
+		create: func(w io.Writer) (resettableWriter, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+}
+
+// Compressing writer which can be reused for another destination.
+type resettableWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// Codec reusing compressing writers.
+type pooledCodec struct {
+	encoding string
+	create   func(w io.Writer) (resettableWriter, error)
+	pool     sync.Pool
+}
+
+// ContentEncoding returns encoding of the codec.
+func (c *pooledCodec) ContentEncoding() string {
+	return c.encoding
+}
+
+// NewWriter returns a pooled writer, or creates one if the pool is empty.
+func (c *pooledCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := c.pool.Get().(resettableWriter); ok {
+		zw.Reset(w)
+		return &pooledWriter{resettableWriter: zw, codec: c}, nil
+	}
+	zw, err := c.create(w)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{resettableWriter: zw, codec: c}, nil
+}
+
+// Writer returning to the pool when closed.
+type pooledWriter struct {
+	resettableWriter
+	codec  *pooledCodec
+	closed bool
+}
+
+// Close flushes compressed data and returns the writer to the pool.
+func (w *pooledWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.resettableWriter.Close()
+	w.codec.pool.Put(w.resettableWriter)
+	return err
+}
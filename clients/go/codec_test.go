@@ -0,0 +1,264 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestCodec_BuiltIns(t *testing.T) {
+	sets := []struct {
+		name       string
+		encoding   string
+		decompress func(io.Reader) (io.Reader, error)
+	}{
+		{"none", "identity", func(r io.Reader) (io.Reader, error) { return r, nil }},
+		{"gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate", "deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+		{"zlib", "deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+	}
+	payload := []byte(`[{"sourceId":"foo","eventName":"bar","timestamp":1}]`)
+
+	for i, set := range sets {
+		codec, ok := LookupCodec(set.name)
+		if !ok {
+			t.Fatalf("Set #%d. Codec %q should be registered", i, set.name)
+		}
+		if codec.ContentEncoding() != set.encoding {
+			t.Errorf("Set #%d. Want encoding: %q Got: %q", i, set.encoding, codec.ContentEncoding())
+		}
+
+		// second round uses the pooled writer
+		for round := 0; round < 2; round++ {
+			var buf bytes.Buffer
+			w, err := codec.NewWriter(&buf)
+			if err != nil {
+				t.Fatalf("Set #%d. Unexpected error %v", i, err)
+			}
+			w.Write(payload)
+			if err := w.Close(); err != nil {
+				t.Errorf("Set #%d. Unexpected error %v", i, err)
+			}
+
+			r, err := set.decompress(&buf)
+			if err != nil {
+				t.Fatalf("Set #%d. Unexpected error %v", i, err)
+			}
+			if got, _ := ioutil.ReadAll(r); !bytes.Equal(got, payload) {
+				t.Errorf("Set #%d. Round %d. Want: %s Got: %s", i, round, payload, got)
+			}
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	payload := `[{"eventName":"a"}]`
+	compress := func(create func(io.Writer) io.WriteCloser) string {
+		var buf bytes.Buffer
+		w := create(&buf)
+		w.Write([]byte(payload))
+		w.Close()
+		return buf.String()
+	}
+	gzipped := compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	zlibbed := compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })
+	raw := compress(func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	})
+
+	sets := []struct {
+		encoding string
+		body     string
+		err      error
+	}{
+		{"", payload, nil},
+		{"identity", payload, nil},
+		{"none", payload, nil},
+		{"GZIP", gzipped, nil},
+		{"deflate", zlibbed, nil},
+		{"deflate", raw, nil},
+		{"zlib", zlibbed, nil},
+		{"br", payload, EncodingError{"Unsupported content encoding br"}},
+	}
+
+	for i, set := range sets {
+		r, err := Decompress(set.encoding, bytes.NewReader([]byte(set.body)))
+		if err != set.err {
+			t.Errorf("Set #%d. Want error: %v Got: %v", i, set.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got, _ := ioutil.ReadAll(r); string(got) != payload {
+			t.Errorf("Set #%d. Want: %s Got: %q", i, payload, got)
+		}
+	}
+}
+
+func TestCodec_GzipLevel(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"eventName":"sensor.reading.taken"}`), 1000)
+	compress := func(codec Codec) int {
+		var buf bytes.Buffer
+		w, _ := codec.NewWriter(&buf)
+		w.Write(payload)
+		w.Close()
+		return buf.Len()
+	}
+
+	if compress(GzipCodec(gzip.NoCompression)) <= compress(GzipCodec(gzip.BestCompression)) {
+		t.Error("Compression level should be applied")
+	}
+	if _, err := GzipCodec(42).NewWriter(ioutil.Discard); err == nil {
+		t.Error("Invalid level should be reported")
+	}
+}
+
+func TestConfig_Validate_RegisteredCodec(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://foo.bar"
+
+	for i, name := range []string{"gzip", "deflate", "zlib", "none"} {
+		config.Compression = name
+		if err := config.Validate(); err != nil {
+			t.Errorf("Set #%d. Codec %q should be valid. Got %v", i, name, err)
+		}
+	}
+
+	config.Compression = "test-fast"
+	if config.Validate() == nil {
+		t.Error("Unregistered codec should be invalid")
+	}
+	RegisterCodec("test-fast", GzipCodec(gzip.BestSpeed))
+	defer func() {
+		codecs.Lock()
+		delete(codecs.byName, "test-fast")
+		codecs.Unlock()
+	}()
+	if err := config.Validate(); err != nil {
+		t.Errorf("Registered codec should be valid. Got %v", err)
+	}
+}
+
+func TestConfig_Validate_CompressionLevel(t *testing.T) {
+	sets := []struct {
+		compression string
+		level       int
+		valid       bool
+	}{
+		{"gzip", 0, true},
+		{"gzip", 1, true},
+		{"deflate", 9, true},
+		{"zlib", 5, true},
+		{"gzip", 10, false},
+		{"gzip", -1, false},
+		{"none", 1, false},
+	}
+
+	for i, set := range sets {
+		config := NewConfig()
+		config.Url = "http://foo.bar"
+		config.Compression = set.compression
+		config.CompressionLevel = set.level
+		if err := config.Validate(); (err == nil) != set.valid {
+			t.Errorf("Set #%d. Config should be valid: %t. Got %v", i, set.valid, err)
+		}
+	}
+}
+
+func TestConfiguredCodec_Level(t *testing.T) {
+	var payload []byte
+	for i := 0; i < 5000; i++ {
+		payload = strconv.AppendInt(payload, int64(i*i%7919), 10)
+	}
+	compress := func(codec Codec) []byte {
+		var buf bytes.Buffer
+		w, _ := codec.NewWriter(&buf)
+		w.Write(payload)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	for i, level := range []int{gzip.BestSpeed, gzip.BestCompression} {
+		config := NewConfig()
+		config.CompressionLevel = level
+		codec, _ := configuredCodec(config)
+		if !bytes.Equal(compress(codec), compress(GzipCodec(level))) {
+			t.Errorf("Set #%d. Compression level %d should be applied", i, level)
+		}
+		if again, _ := configuredCodec(config); again != codec {
+			t.Errorf("Set #%d. Codec of the same level should be reused", i)
+		}
+	}
+	if bytes.Equal(compress(GzipCodec(gzip.BestSpeed)), compress(GzipCodec(gzip.BestCompression))) {
+		t.Error("Levels should compress differently")
+	}
+}
+
+type failingCodec struct{}
+
+func (failingCodec) ContentEncoding() string {
+	return "failing"
+}
+
+func (failingCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return failingCloser{w}, nil
+}
+
+type failingCloser struct {
+	io.Writer
+}
+
+func (failingCloser) Close() error {
+	return errors.New("compression failed")
+}
+
+func TestPublisher_Post_Codecs(t *testing.T) {
+	RegisterCodec("test-failing", failingCodec{})
+
+	sets := []struct {
+		compression string
+		encoding    string
+		want        bool
+	}{
+		{"zlib", "deflate", true},
+		{"test-failing", "", false},
+		{"unknown", "", false},
+	}
+
+	for i, set := range sets {
+		var encoding string
+		var complete bool
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding = r.Header.Get("Content-Encoding")
+			_, err := ioutil.ReadAll(r.Body)
+			complete = err == nil
+			w.WriteHeader(http.StatusAccepted)
+		}))
+
+		config := NewConfig()
+		config.Url = mockServer.URL
+		config.Compression = set.compression
+		publisher := Publisher{config: config}
+
+		if got := publisher.Post([]Event{{"sourceId": "foo"}}); got != set.want {
+			t.Errorf("Set #%d. Post method should return %t", i, set.want)
+		}
+		mockServer.Close()
+		if set.want && encoding != set.encoding {
+			t.Errorf("Set #%d. Want encoding: %q Got: %q", i, set.encoding, encoding)
+		}
+		if complete != set.want {
+			t.Errorf("Set #%d. Body should be complete: %t", i, set.want)
+		}
+	}
+}
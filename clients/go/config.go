@@ -30,15 +30,167 @@ type Config struct {
 	// before attempting to publish them.
 	MinBufferSize int64
 
+	// Number of workers publishing batches of buffered events concurrently,
+	// so that a slow request doesn't hold back the others.
+	// Zero means a single worker.
+	// default = 1
+	PublishWorkers int
+
+	// Max number of events in a batch published by a worker.
+	// default = 0 (buffered events are split evenly between workers)
+	MaxBatchSize int64
+
+	// Should events of a sourceId be published in the order they were recorded?
+	// A batch with events of a sourceId isn't published
+	// while another one with its events is in flight.
+	// default = false
+	PreserveSourceOrder bool
+
+	// Transport used to publish events: http, mqtt, file or stdout.
+	// For mqtt Url is the address of the broker,
+	// e.g. "tcp://samsara-ingestion.local:10010".
+	// Url isn't required for file and stdout.
+	// Empty value means http.
+	// default = http
+	Transport string
+
+	// Directory of segment files written by file transport.
+	FileDir string
+
+	// Max size of a segment file in bytes before it is rotated.
+	// default = 64MB
+	FileMaxSize int64
+
+	// Max age of a segment file in milliseconds before it is rotated.
+	// default = 1h
+	FileMaxAge uint32
+
+	// Should closed segment files be gzipped?
+	// default = true
+	FileCompress bool
+
+	// MQTT topic events are published to.
+	// default = "samsara/events"
+	MqttTopic string
+
+	// MQTT quality of service: 0 (at most once)
+	// or 1 (at least once, acknowledged by the broker).
+	// default = 1
+	MqttQoS byte
+
+	// MQTT keepalive interval in seconds.
+	// default = 60
+	MqttKeepAlive uint16
+
+	// MQTT client identifier.
+	// default = "" (random identifier)
+	MqttClientId string
+
 	// Network timeout for send operations
 	// in milliseconds.
 	// default 30s
 	SendTimeout uint32
 
 	// Should the payload be compressed?
-	// allowed values :gzip, :none
+	// allowed values: gzip, deflate, zlib (an alias of deflate), none
+	// and names of codecs added with RegisterCodec.
+	// default = gzip
 	Compression string
 
+	// Compression level of gzip, deflate and zlib codecs
+	// from 1 (best speed) to 9 (best compression).
+	// default = 0, the default level of the codec
+	CompressionLevel int
+
+	// Ordered chain of enrichers adding facets to every event.
+	// Facets explicitly set in the event are never overridden.
+	// See RuntimeEnrichers for built-in host and runtime facets.
+	// default = none
+	Enrichers []Enricher
+
+	// Rules for scrubbing sensitive data out of events
+	// before they are buffered or published.
+	// See PIIRules for built-in detectors of common PII.
+	// default = none
+	Redaction []RedactionRule
+
+	// Secret key used to hash values with RedactHash action.
+	// Required if any of redaction rules hashes data.
+	RedactionKey string
+
+	// Should spans emit the merged `.done` event when stopped?
+	// Enable it only if the session-boundaries module isn't used
+	// server-side, otherwise `.done` events are duplicated.
+	// default = false
+	EmitSpanDone bool
+
+	// Inactivity gap in milliseconds after which a new session
+	// is started for a sourceId. Sessions are tracked client-side
+	// and injected into events as `sessionId` facet.
+	// default = 0 (sessionization disabled)
+	SessionInactivityGap uint32
+
+	// Max number of sourceIds which sessions are tracked.
+	// When exceeded least recently active sessions are stopped.
+	// default = 10000
+	SessionMaxSources int
+
+	// Should `session.started` and `session.stopped` events
+	// be emitted on session boundaries?
+	// default = false
+	EmitSessionEvents bool
+
+	// Max number of recent events remembered to detect duplicates.
+	// Duplicates are dropped and counted in Stats.
+	// default = 0 (deduplication disabled)
+	DedupeWindowSize int
+
+	// Max time in milliseconds an event is remembered to detect duplicates.
+	// default = 0 (bounded only by DedupeWindowSize)
+	DedupeWindowTime uint32
+
+	// Function returning deduplication key of an event
+	// and whether the event has one. See DedupeOnFields.
+	// default = nil (event's `id` is used)
+	DedupeKey func(Event) (string, bool)
+
+	// Token bucket limits for recorded events.
+	// Events exceeding any of matching limits are dropped,
+	// counted in Stats and periodically summarized
+	// as `samsara.client.ratelimited` event.
+	// default = none
+	RateLimits []RateLimit
+
+	// How often should dropped events be summarized
+	// in milliseconds.
+	// default = 60s
+	RateLimitReportInterval uint32
+
+	// Max number of sourceIds which buckets of per-source limits are kept.
+	// When exceeded least recently limited sources are forgotten.
+	// default = 10000
+	RateLimitMaxSources int
+
+	// Rules for sampling events at the source by eventName.
+	// The first rule matching event's name is applied,
+	// events without matching rule are always kept.
+	// default = none
+	Sampling []SamplingRule
+
+	// Should events be numbered with monotonic `seq` per sourceId
+	// and the `incarnation` id of the client?
+	// It lets consumers reorder events and detect missing ones,
+	// see SequenceChecker.
+	// default = false
+	SequenceEvents bool
+
+	// How to handle skew of the local clock, estimated from
+	// the `Date` header of Ingestion API responses:
+	// ClockSkewNone, ClockSkewFacet or ClockSkewCorrect.
+	// Other modes than ClockSkewNone require http transport.
+	// default = ClockSkewNone
+	ClockSkew ClockSkewMode
+
 	// NOT CURRENTLY SUPPORTED
 	// Add Samsara client statistics events
 	// this helps you to understand whether the
@@ -57,8 +209,20 @@ func NewConfig() Config {
 	config.PublishInterval = 30000
 	config.MaxBufferSize = 10000
 	config.MinBufferSize = 100
+	config.PublishWorkers = 1
 	config.SendTimeout = 30000
+	config.Transport = "http"
+	config.MqttTopic = "samsara/events"
+	config.MqttQoS = 1
+	config.MqttKeepAlive = 60
+	config.FileMaxSize = 64 * 1024 * 1024
+	config.FileMaxAge = 3600000
+	config.FileCompress = true
 	config.Compression = "gzip"
+	config.SessionMaxSources = 10000
+	config.RateLimitReportInterval = 60000
+	config.RateLimitMaxSources = 10000
+	config.ClockSkew = ClockSkewNone
 	//config.SendClientStats = true
 	return config
 }
@@ -66,17 +230,102 @@ func NewConfig() Config {
 // Validate validates given configuration values.
 func (c *Config) Validate() error {
 	switch {
-	case len(c.Url) == 0:
+	case len(c.Url) == 0 && (c.Transport == "" || c.Transport == "http" || c.Transport == "mqtt"):
 		return ConfigValidationError{"URL for Ingestion API should be specified."}
-	case c.Compression != "gzip" && c.Compression != "none":
+	case !validCodec(c.Compression):
 		return ConfigValidationError{"Incorrect compression option."}
+	case c.CompressionLevel < 0 || c.CompressionLevel > 9:
+		return ConfigValidationError{"compressionLevel should be between 1 and 9."}
+	case c.CompressionLevel != 0 && leveledCodecs[c.Compression] == nil:
+		return ConfigValidationError{"compressionLevel is supported only by gzip, deflate and zlib compression."}
+	case c.Transport != "" && c.Transport != "http" && c.Transport != "mqtt" && c.Transport != "file" && c.Transport != "stdout":
+		return ConfigValidationError{"Incorrect transport option."}
+	case c.Transport == "file" && len(c.FileDir) == 0:
+		return ConfigValidationError{"FileDir should be specified for file transport."}
+	case c.Transport == "file" && c.FileMaxSize <= 0:
+		return ConfigValidationError{"fileMaxSize should be positive."}
+	case c.Transport == "mqtt" && c.MqttQoS > 1:
+		return ConfigValidationError{"MQTT QoS should be 0 or 1."}
 	case c.PublishInterval <= 0:
 		return ConfigValidationError{"Invalid interval time for Samsara client."}
 	case c.MaxBufferSize < c.MinBufferSize:
 		return ConfigValidationError{"maxBufferSize can not be less than minBufferSize."}
-	default:
-		return nil
+	case c.PublishWorkers < 0:
+		return ConfigValidationError{"publishWorkers can not be negative."}
+	case c.MaxBatchSize < 0:
+		return ConfigValidationError{"maxBatchSize can not be negative."}
+	case c.SessionInactivityGap > 0 && c.SessionMaxSources <= 0:
+		return ConfigValidationError{"sessionMaxSources should be positive."}
+	case c.DedupeWindowSize < 0:
+		return ConfigValidationError{"dedupeWindowSize can not be negative."}
+	case c.ClockSkew != ClockSkewNone && c.ClockSkew != ClockSkewFacet && c.ClockSkew != ClockSkewCorrect:
+		return ConfigValidationError{"Incorrect clock skew option."}
+	case c.ClockSkew != ClockSkewNone && c.Transport != "" && c.Transport != "http":
+		return ConfigValidationError{"Clock skew is supported only by http transport."}
+	}
+
+	validators := []func() error{
+		c.validateRedaction,
+		c.validateSampling,
+		c.validateRateLimits,
+	}
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validates redaction rules.
+func (c *Config) validateRedaction() error {
+	for _, rule := range c.Redaction {
+		switch rule.Action {
+		case RedactDrop, RedactMask:
+			if rule.Field != "" && MatchGlob(rule.Field, "sourceId") {
+				return ConfigValidationError{"sourceId can only be redacted by hashing."}
+			}
+		case RedactHash:
+			if len(c.RedactionKey) == 0 {
+				return ConfigValidationError{"RedactionKey should be specified for hashing redaction rules."}
+			}
+		default:
+			return ConfigValidationError{"Incorrect redaction action."}
+		}
+	}
+	return nil
+}
+
+// Validates sampling rules.
+func (c *Config) validateSampling() error {
+	for _, rule := range c.Sampling {
+		switch rule.Mode {
+		case SampleAlways, SampleNever:
+		case SampleRandom, SampleBySource:
+			if !validRate(rule.Rate) {
+				return ConfigValidationError{"Sampling rate should be in range [0, 1]."}
+			}
+		default:
+			return ConfigValidationError{"Incorrect sampling mode."}
+		}
+	}
+	return nil
+}
+
+// Validates rate limits.
+func (c *Config) validateRateLimits() error {
+	for _, limit := range c.RateLimits {
+		if !(limit.Rate > 0) || limit.Burst < 1 {
+			return ConfigValidationError{"Rate limit should have positive rate and burst."}
+		}
+	}
+	if len(c.RateLimits) > 0 && c.RateLimitReportInterval == 0 {
+		return ConfigValidationError{"Invalid rate limit report interval."}
+	}
+	if len(c.RateLimits) > 0 && c.RateLimitMaxSources <= 0 {
+		return ConfigValidationError{"rateLimitMaxSources should be positive."}
 	}
+	return nil
 }
 
 // Timestamp generates current timestamp.
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -8,18 +9,30 @@ import (
 
 func TestNewConfig(t *testing.T) {
 	expected := Config{
-		Url:                   "",
-		SourceId:              "",
-		StartPublishingThread: true,
-		PublishInterval:       30000,
-		MaxBufferSize:         10000,
-		MinBufferSize:         100,
-		SendTimeout:           30000,
-		Compression:           "gzip",
+		Url:                     "",
+		SourceId:                "",
+		StartPublishingThread:   true,
+		PublishInterval:         30000,
+		MaxBufferSize:           10000,
+		MinBufferSize:           100,
+		PublishWorkers:          1,
+		SendTimeout:             30000,
+		Transport:               "http",
+		MqttTopic:               "samsara/events",
+		MqttQoS:                 1,
+		MqttKeepAlive:           60,
+		FileMaxSize:             64 * 1024 * 1024,
+		FileMaxAge:              3600000,
+		FileCompress:            true,
+		Compression:             "gzip",
+		SessionMaxSources:       10000,
+		RateLimitReportInterval: 60000,
+		RateLimitMaxSources:     10000,
+		ClockSkew:               ClockSkewNone,
 	}
 
 	initial := NewConfig()
-	if expected != initial {
+	if !reflect.DeepEqual(expected, initial) {
 		t.Error("New config doesn't contain correct default values")
 	}
 }
@@ -41,6 +54,23 @@ func TestConfig_Validate_WithCorrectData(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_EmptyTransportIsHttp(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://foo.bar"
+	config.Transport = ""
+	if err := config.Validate(); err != nil {
+		t.Errorf("Empty transport should be valid. Got %s", err)
+	}
+	if _, ok := newPublisher(config, nil).(*Publisher); !ok {
+		t.Errorf("Empty transport should publish over http")
+	}
+
+	config.Url = ""
+	if err := config.Validate(); err == nil {
+		t.Errorf("URL should be required by empty transport")
+	}
+}
+
 func TestConfig_Validate_WithInvalidData(t *testing.T) {
 	sets := []struct {
 		msg    string
@@ -82,6 +112,172 @@ func TestConfig_Validate_WithInvalidData(t *testing.T) {
 				return config
 			}(),
 		},
+		{
+			"publishWorkers can not be negative.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.PublishWorkers = -1
+				return config
+			}(),
+		},
+		{
+			"maxBatchSize can not be negative.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.MaxBatchSize = -1
+				return config
+			}(),
+		},
+		{
+			"sessionMaxSources should be positive.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.SessionInactivityGap = 1000
+				config.SessionMaxSources = 0
+				return config
+			}(),
+		},
+		{
+			"dedupeWindowSize can not be negative.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.DedupeWindowSize = -1
+				return config
+			}(),
+		},
+		{
+			"Incorrect transport option.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.Transport = "carrier-pigeon"
+				return config
+			}(),
+		},
+		{
+			"FileDir should be specified for file transport.",
+			func() Config {
+				config := NewConfig()
+				config.Transport = "file"
+				return config
+			}(),
+		},
+		{
+			"fileMaxSize should be positive.",
+			func() Config {
+				config := NewConfig()
+				config.Transport = "file"
+				config.FileDir = "/tmp/events"
+				config.FileMaxSize = 0
+				return config
+			}(),
+		},
+		{
+			"MQTT QoS should be 0 or 1.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "tcp://foo.bar:10010"
+				config.Transport = "mqtt"
+				config.MqttQoS = 2
+				return config
+			}(),
+		},
+		{
+			"Incorrect clock skew option.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.ClockSkew = "ntp"
+				return config
+			}(),
+		},
+		{
+			"Rate limit should have positive rate and burst.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.RateLimits = []RateLimit{{Rate: 10}}
+				return config
+			}(),
+		},
+		{
+			"Invalid rate limit report interval.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.RateLimits = []RateLimit{{Rate: 10, Burst: 10}}
+				config.RateLimitReportInterval = 0
+				return config
+			}(),
+		},
+		{
+			"rateLimitMaxSources should be positive.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.RateLimits = []RateLimit{{Rate: 10, Burst: 10}}
+				config.RateLimitMaxSources = 0
+				return config
+			}(),
+		},
+		{
+			"Incorrect redaction action.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.Redaction = []RedactionRule{{Field: "email", Action: "erase"}}
+				return config
+			}(),
+		},
+		{
+			"sourceId can only be redacted by hashing.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.Redaction = []RedactionRule{{Field: "*Id", Action: RedactMask}}
+				return config
+			}(),
+		},
+		{
+			"RedactionKey should be specified for hashing redaction rules.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.Redaction = PIIRules(RedactHash)
+				return config
+			}(),
+		},
+		{
+			"Clock skew is supported only by http transport.",
+			func() Config {
+				config := NewConfig()
+				config.Transport = "stdout"
+				config.ClockSkew = ClockSkewFacet
+				return config
+			}(),
+		},
+		{
+			"Incorrect sampling mode.",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.Sampling = []SamplingRule{{EventName: "cache.hit", Mode: "sometimes"}}
+				return config
+			}(),
+		},
+		{
+			"Sampling rate should be in range [0, 1].",
+			func() Config {
+				config := NewConfig()
+				config.Url = "http://foo.bar"
+				config.Sampling = []SamplingRule{{EventName: "cache.hit", Mode: SampleRandom, Rate: 1.5}}
+				return config
+			}(),
+		},
 	}
 	for i, v := range sets {
 		err := v.config.Validate()
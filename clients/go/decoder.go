@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EventDecoder reads events from a stream of NDJSON or JSON arrays of events,
+// as written by EventEncoder. The format is detected from the first character.
+// Integral numbers are decoded as int64, others as float64,
+// so that decoded events pass Event.Validate.
+// In NDJSON a malformed line, including one with data after the event,
+// is reported as EventDecodeError
+// and doesn't prevent decoding of the following ones.
+// So is an element of a JSON array which isn't an object.
+type EventDecoder struct {
+	r        *bufio.Reader
+	dec      *json.Decoder
+	array    bool
+	inArray  bool
+	line     int
+	elements int
+}
+
+// NewEventDecoder returns a decoder reading events from r.
+func NewEventDecoder(r io.Reader) *EventDecoder {
+	return &EventDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the next event, or io.EOF at the end of the stream.
+func (d *EventDecoder) Decode() (Event, error) {
+	if d.dec == nil {
+		if err := d.detect(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !d.array {
+		return d.decodeLine()
+	}
+	for !d.inArray || !d.dec.More() {
+		if err := d.nextArray(); err != nil {
+			return nil, err
+		}
+	}
+	d.elements++
+	event, err := decodeEvent(d.dec)
+	if _, ok := err.(*json.UnmarshalTypeError); ok || err == errNotObject {
+		return nil, EventDecodeError{fmt.Sprintf("element %d: %v", d.elements, err)}
+	}
+	return event, err
+}
+
+// Decodes the next non-empty line.
+func (d *EventDecoder) decodeLine() (Event, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		d.line++
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		event, err := decodeEvent(dec)
+		if err == nil {
+			if _, trailing := dec.Token(); trailing != io.EOF {
+				err = errors.New("unexpected data after the event")
+			}
+		}
+		if err != nil {
+			return nil, EventDecodeError{fmt.Sprintf("line %d: %v", d.line, err)}
+		}
+		return event, nil
+	}
+}
+
+// Error of a JSON value which isn't an object.
+var errNotObject = errors.New("event should be a JSON object")
+
+// Decodes a single event.
+func decodeEvent(dec *json.Decoder) (Event, error) {
+	var event Event
+	if err := dec.Decode(&event); err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, errNotObject
+	}
+	return ConvertNumbers(event).(Event), nil
+}
+
+// Detects the format from the first non-whitespace character.
+func (d *EventDecoder) detect() error {
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			d.r.UnreadByte()
+			d.array = b == '['
+			d.dec = json.NewDecoder(d.r)
+			d.dec.UseNumber()
+			return nil
+		}
+	}
+}
+
+// Moves to the next of concatenated arrays.
+func (d *EventDecoder) nextArray() error {
+	token, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('['):
+		d.inArray = true
+	case json.Delim(']'):
+		d.inArray = false
+	default:
+		return errors.New("events should be in a JSON array")
+	}
+	return nil
+}
+
+// ConvertNumbers converts json.Number values in v, decoded with
+// json.Decoder.UseNumber, into int64 if integral or float64 otherwise.
+// Nested maps and slices are converted in place.
+func ConvertNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case Event:
+		for k, nested := range value {
+			value[k] = ConvertNumbers(nested)
+		}
+	case map[string]interface{}:
+		for k, nested := range value {
+			value[k] = ConvertNumbers(nested)
+		}
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = ConvertNumbers(nested)
+		}
+	}
+	return v
+}
+
+// DecodeEvents reads all events from r and calls fn with each of them.
+// It stops at the first decoding error or error returned by fn.
+func DecodeEvents(r io.Reader, fn func(Event) error) error {
+	dec := NewEventDecoder(r)
+	for {
+		event, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
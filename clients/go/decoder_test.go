@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Decodes all events from the input, stopping at the first error.
+func decodeAll(input string) ([]Event, error) {
+	var events []Event
+	err := DecodeEvents(strings.NewReader(input), func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+func TestEventDecoder_Decode(t *testing.T) {
+	a := Event{"eventName": "a", "timestamp": int64(1), "value": 1.5}
+	b := Event{"eventName": "b", "nested": map[string]interface{}{"n": int64(2)}, "list": []interface{}{int64(3), "x"}}
+
+	var tests = []struct {
+		input string
+		want  []Event
+	}{
+		{"", nil},
+		{" \n ", nil},
+		{"[]", nil},
+		{`{"eventName":"a","timestamp":1,"value":1.5}`, []Event{a}},
+		{"{\"eventName\":\"a\",\"timestamp\":1,\"value\":1.5}\n\n{\"eventName\":\"b\",\"nested\":{\"n\":2},\"list\":[3,\"x\"]}\n", []Event{a, b}},
+		{` [{"eventName":"a","timestamp":1,"value":1.5}, {"eventName":"b","nested":{"n":2},"list":[3,"x"]}]`, []Event{a, b}},
+		{`[{"eventName":"a","timestamp":1,"value":1.5}][][{"eventName":"b","nested":{"n":2},"list":[3,"x"]}]`, []Event{a, b}},
+	}
+
+	for i, test := range tests {
+		events, err := decodeAll(test.input)
+		if err != nil {
+			t.Errorf("Set #%d. Unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(events, test.want) {
+			t.Errorf("Set #%d.\nWant: %#v\nGot: %#v", i, test.want, events)
+		}
+	}
+}
+
+func TestEventDecoder_Decode_Errors(t *testing.T) {
+	var tests = []string{
+		`[{"eventName":"a"}`,
+		`[1]`,
+		`[{"eventName":"a"}] {"eventName":"b"}`,
+		"null\n",
+		"{\"eventName\":\n",
+		"{\"eventName\":\"a\"} garbage\n",
+		"{\"eventName\":\"a\"}{\"eventName\":\"b\"}\n",
+		"{\"eventName\":\"a\"}}\n",
+	}
+
+	for i, input := range tests {
+		if _, err := decodeAll(input); err == nil {
+			t.Errorf("Set #%d. Decoding %q should fail", i, input)
+		}
+	}
+}
+
+func TestEventDecoder_Decode_SkipsMalformedLines(t *testing.T) {
+	dec := NewEventDecoder(strings.NewReader("{\"eventName\":\"a\"}\n{\"event\n[1]\n{\"eventName\":\"c\"} 1\n{\"eventName\":\"b\"} \r\n"))
+
+	var names []string
+	var decodeErrors int
+	for {
+		event, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(EventDecodeError); ok {
+			decodeErrors++
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, event["eventName"].(string))
+	}
+
+	if decodeErrors != 3 || !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Malformed lines should be reported and skipped. Got %v and %d errors", names, decodeErrors)
+	}
+}
+
+func TestEventDecoder_Decode_SkipsArrayElementsWhichAreNotObjects(t *testing.T) {
+	dec := NewEventDecoder(strings.NewReader(`[{"eventName":"a"},1,null,"x",[{}],{"eventName":"b"}]`))
+
+	var names []string
+	var decodeErrors int
+	for {
+		event, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(EventDecodeError); ok {
+			decodeErrors++
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, event["eventName"].(string))
+	}
+
+	if decodeErrors != 4 || !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Elements which are not objects should be reported and skipped. Got %v and %d errors", names, decodeErrors)
+	}
+}
+
+func TestEventDecoder_DecodesEncodedEvents(t *testing.T) {
+	events := []Event{
+		{"eventName": "a", "sourceId": "s", "timestamp": int64(1)},
+		{"eventName": "b", "sourceId": "s", "timestamp": int64(2), "value": 0.5},
+	}
+
+	for _, format := range []EventFormat{FormatJSONArray, FormatNDJSON} {
+		var buf bytes.Buffer
+		if err := EncodeEvents(&buf, format, events); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := decodeAll(buf.String())
+		if err != nil || !reflect.DeepEqual(decoded, events) {
+			t.Errorf("Events should survive %s encoding. Got %v, %v", format, decoded, err)
+		}
+		for _, event := range decoded {
+			if err := event.Validate(); err != nil {
+				t.Errorf("Decoded event should be valid. Got %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// DedupeOnFields returns a deduplication key function composed of
+// values of the given fields. Events missing all of the fields have no key.
+func DedupeOnFields(fields ...string) func(Event) (string, bool) {
+	return func(e Event) (string, bool) {
+		found := false
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			value, ok := e[field]
+			values[i] = value
+			found = found || ok
+		}
+		return fmt.Sprintf("%#v", values), found
+	}
+}
+
+// Default deduplication key is event's `id`.
+func dedupeOnId(e Event) (string, bool) {
+	id, ok := e["id"].(string)
+	return id, ok && id != ""
+}
+
+// Recently seen deduplication key, stored as 64-bit hash to keep memory compact.
+type seenKey struct {
+	hash   uint64
+	seenAt int64
+}
+
+// Deduplicator drops events which key has been seen within the window.
+// Keys are kept in order they were first seen, the oldest at the back,
+// so that duplicates don't extend the window of their key.
+type deduplicator struct {
+	key     func(Event) (string, bool)
+	size    int
+	maxAge  int64
+	now     func() int64
+	seen    map[uint64]*list.Element
+	window  *list.List
+	dropped uint64
+	sync.Mutex
+}
+
+// Creates new deduplicator. Returns nil if deduplication is disabled.
+func newDeduplicator(config Config) *deduplicator {
+	if config.DedupeWindowSize == 0 {
+		return nil
+	}
+	key := config.DedupeKey
+	if key == nil {
+		key = dedupeOnId
+	}
+	return &deduplicator{
+		key:    key,
+		size:   config.DedupeWindowSize,
+		maxAge: int64(config.DedupeWindowTime),
+		now:    Timestamp,
+		seen:   make(map[uint64]*list.Element),
+		window: list.New(),
+	}
+}
+
+// Tells whether event is a duplicate of one seen within the window.
+// Events without a key are never duplicates. Otherwise returns the remembered key, nil for events without a key,
+// so that it can be forgotten if the event isn't published.
+func (d *deduplicator) admit(e Event) (*seenKey, bool) {
+	if d == nil {
+		return nil, false
+	}
+	key, ok := d.key(e)
+	if !ok {
+		return nil, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	hash := h.Sum64()
+
+	d.Lock()
+	defer d.Unlock()
+
+	now := d.now()
+	d.expire(now)
+
+	if _, ok := d.seen[hash]; ok {
+		d.dropped++
+		return nil, true
+	}
+
+	seen := &seenKey{hash: hash, seenAt: now}
+	d.seen[hash] = d.window.PushFront(seen)
+	for d.window.Len() > d.size {
+		d.remove(d.window.Back())
+	}
+	return seen, false
+}
+
+// Forgets keys remembered by admit, so that retries of events which
+// weren't published aren't dropped as duplicates.
+func (d *deduplicator) forget(keys []*seenKey) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	for _, key := range keys {
+		if elem, ok := d.seen[key.hash]; ok && elem.Value == key {
+			d.remove(elem)
+		}
+	}
+}
+
+// Number of dropped duplicates.
+func (d *deduplicator) droppedCount() uint64 {
+	if d == nil {
+		return 0
+	}
+	d.Lock()
+	defer d.Unlock()
+	return d.dropped
+}
+
+// Forgets keys older than the window time.
+func (d *deduplicator) expire(now int64) {
+	if d.maxAge == 0 {
+		return
+	}
+	for back := d.window.Back(); back != nil && now-back.Value.(*seenKey).seenAt > d.maxAge; back = d.window.Back() {
+		d.remove(back)
+	}
+}
+
+// Removes key from the window.
+func (d *deduplicator) remove(elem *list.Element) {
+	d.window.Remove(elem)
+	delete(d.seen, elem.Value.(*seenKey).hash)
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"testing"
+)
+
+func newTestDeduplicator(size int, maxAge uint32, key func(Event) (string, bool)) *deduplicator {
+	config := NewConfig()
+	config.DedupeWindowSize = size
+	config.DedupeWindowTime = maxAge
+	config.DedupeKey = key
+	return newDeduplicator(config)
+}
+
+// Tells whether event is a duplicate of one seen within the window.
+func isDuplicate(d *deduplicator, e Event) bool {
+	_, duplicate := d.admit(e)
+	return duplicate
+}
+
+func TestDeduplicator_DisabledByDefault(t *testing.T) {
+	d := newDeduplicator(NewConfig())
+	event := Event{"id": "a"}
+
+	if isDuplicate(d, event) || isDuplicate(d, event) {
+		t.Error("Events should never be duplicates when deduplication is disabled")
+	}
+	if d.droppedCount() != 0 {
+		t.Errorf("Nothing should be counted. Got %d", d.droppedCount())
+	}
+}
+
+func TestDeduplicator_DropsEventsWithSeenId(t *testing.T) {
+	d := newTestDeduplicator(10, 0, nil)
+
+	sets := []struct {
+		event Event
+		want  bool
+	}{
+		{Event{"id": "a"}, false},
+		{Event{"id": "b"}, false},
+		{Event{"id": "a"}, true},
+		{Event{"eventName": "no-id"}, false},
+		{Event{"eventName": "no-id"}, false},
+		{Event{"id": ""}, false},
+		{Event{"id": ""}, false},
+	}
+
+	for i, set := range sets {
+		if got := isDuplicate(d, set.event); got != set.want {
+			t.Errorf("Set #%d. Event %+v should be duplicate: %t", i, set.event, set.want)
+		}
+	}
+	if d.droppedCount() != 1 {
+		t.Errorf("1 duplicate should be counted. Got %d", d.droppedCount())
+	}
+}
+
+func TestDeduplicator_CountBoundedWindow(t *testing.T) {
+	d := newTestDeduplicator(2, 0, nil)
+
+	isDuplicate(d, Event{"id": "a"})
+	isDuplicate(d, Event{"id": "b"})
+	isDuplicate(d, Event{"id": "c"})
+
+	if d.window.Len() != 2 || len(d.seen) != 2 {
+		t.Errorf("Only 2 keys should be remembered. Got %d", d.window.Len())
+	}
+	if isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Oldest key should be forgotten")
+	}
+	if !isDuplicate(d, Event{"id": "c"}) {
+		t.Error("Recent key should be remembered")
+	}
+}
+
+func TestDeduplicator_TimeBoundedWindow(t *testing.T) {
+	d := newTestDeduplicator(100, 50, nil)
+	now := int64(1000)
+	d.now = func() int64 { return now }
+
+	isDuplicate(d, Event{"id": "a"})
+	now = 1040
+	if !isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Key should be remembered within window time")
+	}
+	now = 1100
+	if isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Key should be forgotten after window time")
+	}
+}
+
+func TestDeduplicator_DuplicatesDoNotExtendWindow(t *testing.T) {
+	d := newTestDeduplicator(2, 50, nil)
+	now := int64(1000)
+	d.now = func() int64 { return now }
+
+	isDuplicate(d, Event{"id": "a"})
+	isDuplicate(d, Event{"id": "b"})
+	now = 1040
+	isDuplicate(d, Event{"id": "a"})
+	now = 1060
+	if isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Key should be forgotten after window time since it was first seen")
+	}
+
+	isDuplicate(d, Event{"id": "c"})
+	isDuplicate(d, Event{"id": "a"})
+	isDuplicate(d, Event{"id": "d"})
+	if isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Key first seen earliest should be forgotten first")
+	}
+}
+
+func TestDeduplicator_KeyOnFields(t *testing.T) {
+	d := newTestDeduplicator(10, 0, DedupeOnFields("orderId", "status"))
+
+	sets := []struct {
+		event Event
+		want  bool
+	}{
+		{Event{"orderId": 1, "status": "paid", "retry": 1}, false},
+		{Event{"orderId": 1, "status": "paid", "retry": 2}, true},
+		{Event{"orderId": 1, "status": "shipped"}, false},
+		{Event{"orderId": "1", "status": "paid"}, false},
+		{Event{"id": "a"}, false},
+		{Event{"id": "a"}, false},
+	}
+
+	for i, set := range sets {
+		if got := isDuplicate(d, set.event); got != set.want {
+			t.Errorf("Set #%d. Event %+v should be duplicate: %t", i, set.event, set.want)
+		}
+	}
+}
+
+func TestClient_RecordEvent_DropsAndCountsDuplicates(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	config.DedupeWindowSize = 100
+	client, _ := NewClient(config)
+
+	client.RecordEvent(Event{"eventName": "order.paid", "id": "1"})
+	client.RecordEvent(Event{"eventName": "order.paid", "id": "1"})
+	client.RecordEvent(Event{"eventName": "order.paid", "id": "2"})
+
+	out := client.queue.Flush()
+	if len(out) != 2 {
+		t.Errorf("Duplicate should be dropped. Got %+v", out)
+	}
+	if client.Stats().Duplicates != 1 {
+		t.Errorf("Duplicate should be counted. Got %+v", client.Stats())
+	}
+}
+
+func TestClient_PublishEvents_RetriesAreNotDuplicates(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	config.DedupeWindowSize = 100
+
+	online := false
+	var posted [][]Event
+	publisher := &PublisherMock{fakePost: func(events []Event) bool {
+		posted = append(posted, events)
+		return online
+	}}
+	client, _ := NewClientWithPublisher(config, publisher)
+
+	if ok, _ := client.PublishEvents([]Event{{"eventName": "order.paid", "id": "1"}}); ok {
+		t.Error("Failed post should be reported")
+	}
+	if _, err := client.PublishEvents([]Event{{"eventName": "order.paid", "id": "2"}, {"id": "3"}}); err == nil {
+		t.Error("Invalid event should be reported")
+	}
+
+	online = true
+	ok, _ := client.PublishEvents([]Event{
+		{"eventName": "order.paid", "id": "1"},
+		{"eventName": "order.paid", "id": "2"},
+	})
+	if !ok || len(posted) != 2 || len(posted[1]) != 2 {
+		t.Errorf("Retried events should be posted. Got %+v", posted)
+	}
+
+	ok, _ = client.PublishEvents([]Event{{"eventName": "order.paid", "id": "1"}})
+	if !ok || len(posted) != 2 {
+		t.Errorf("Only duplicates of published events should be dropped without posting. Got %+v", posted)
+	}
+	if client.Stats().Duplicates != 1 {
+		t.Errorf("Duplicate should be counted. Got %+v", client.Stats())
+	}
+}
+
+func TestDeduplicator_ForgetsOnlyOwnKeys(t *testing.T) {
+	d := newTestDeduplicator(10, 0, nil)
+
+	key, _ := d.admit(Event{"id": "a"})
+	d.forget([]*seenKey{key})
+	if isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Forgotten key should not be a duplicate")
+	}
+	d.forget([]*seenKey{key})
+	if !isDuplicate(d, Event{"id": "a"}) {
+		t.Error("Key remembered again should not be forgotten by a stale key")
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// EventFormat is the serialization format of a stream of events.
+type EventFormat string
+
+// Event formats.
+const (
+	// JSON array of events, as accepted by Ingestion API.
+	FormatJSONArray EventFormat = "json"
+	// Newline delimited JSON, one event per line.
+	FormatNDJSON EventFormat = "ndjson"
+)
+
+// EventEncoder writes events to a stream one by one,
+// so that a batch is never held in memory as a whole.
+// The first error aborts the encoding and is returned by all further calls.
+type EventEncoder struct {
+	w      io.Writer
+	format EventFormat
+	buf    bytes.Buffer
+	json   *json.Encoder
+	count  int
+	err    error
+}
+
+// NewEventEncoder returns an encoder writing events in the given format to w.
+func NewEventEncoder(w io.Writer, format EventFormat) *EventEncoder {
+	e := &EventEncoder{w: w, format: format}
+	e.json = json.NewEncoder(&e.buf)
+	return e
+}
+
+// Encode writes the event to the stream.
+func (e *EventEncoder) Encode(event Event) error {
+	if e.err != nil {
+		return e.err
+	}
+	// the buffer is reused, json.Encoder terminates each value with a newline
+	e.buf.Reset()
+	if err := e.json.Encode(event); err != nil {
+		e.err = err
+		return err
+	}
+	data := e.buf.Bytes()
+
+	if e.format == FormatJSONArray {
+		data = data[:len(data)-1]
+		if e.count == 0 {
+			e.write("[")
+		} else {
+			e.write(",")
+		}
+	}
+	if e.err == nil {
+		_, e.err = e.w.Write(data)
+	}
+	e.count++
+	return e.err
+}
+
+// Close terminates the stream. It doesn't close the underlying writer.
+func (e *EventEncoder) Close() error {
+	if e.format == FormatJSONArray {
+		if e.count == 0 {
+			e.write("[")
+		}
+		e.write("]")
+	}
+	return e.err
+}
+
+// Writes separator unless there has been an error.
+func (e *EventEncoder) write(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
+
+// EncodeEvents writes all events in the given format to w.
+func EncodeEvents(w io.Writer, format EventFormat, events []Event) error {
+	enc := NewEventEncoder(w, format)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
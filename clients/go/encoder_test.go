@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEventEncoder_Formats(t *testing.T) {
+	events := []Event{
+		{"eventName": "a", "timestamp": int64(1)},
+		{"eventName": "<b>", "tags": []string{"x"}},
+	}
+
+	sets := []struct {
+		format EventFormat
+		events []Event
+		want   string
+	}{
+		{FormatJSONArray, nil, `[]`},
+		{FormatJSONArray, events[:1], `[{"eventName":"a","timestamp":1}]`},
+		{FormatJSONArray, events, `[{"eventName":"a","timestamp":1},{"eventName":"\u003cb\u003e","tags":["x"]}]`},
+		{FormatNDJSON, nil, ``},
+		{FormatNDJSON, events, "{\"eventName\":\"a\",\"timestamp\":1}\n{\"eventName\":\"\\u003cb\\u003e\",\"tags\":[\"x\"]}\n"},
+	}
+
+	for i, set := range sets {
+		var buf bytes.Buffer
+		if err := EncodeEvents(&buf, set.format, set.events); err != nil {
+			t.Errorf("Set #%d. Unexpected error %v", i, err)
+		}
+		if buf.String() != set.want {
+			t.Errorf("Set #%d. Want: %s Got: %s", i, set.want, buf.String())
+		}
+	}
+}
+
+func TestEventEncoder_MatchesMarshal(t *testing.T) {
+	events := []Event{
+		{"sourceId": "событие", "你": "baz", "timestamp": int64(1479988864057)},
+		{"sourceId": "foo", "eventName": "bar", "nested": map[string]interface{}{"a": 1.5}},
+	}
+	want, _ := json.Marshal(events)
+
+	var buf bytes.Buffer
+	EncodeEvents(&buf, FormatJSONArray, events)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Encoded batch should match json.Marshal.\nWant: %s\nGot: %s", want, buf.Bytes())
+	}
+}
+
+func TestEventEncoder_AbortsOnError(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEventEncoder(&buf, FormatJSONArray)
+
+	enc.Encode(Event{"a": 1})
+	err := enc.Encode(Event{"a": math.NaN()})
+	if err == nil {
+		t.Fatal("Encoding error should be returned")
+	}
+	if enc.Encode(Event{"b": 1}) != err || enc.Close() != err {
+		t.Error("The first error should be returned by all further calls")
+	}
+	if buf.String() != `[{"a":1}` {
+		t.Errorf("Nothing should be written after the error. Got %s", buf.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEventEncoder_ReturnsWriteError(t *testing.T) {
+	if err := EncodeEvents(failingWriter{}, FormatNDJSON, []Event{{"a": 1}}); err == nil || err.Error() != "disk full" {
+		t.Errorf("Write error should be returned. Got %v", err)
+	}
+}
+
+func TestPublisher_Post_AbortsOnEncodingError(t *testing.T) {
+	received := make(chan bool, 1)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		received <- err == nil
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer mockServer.Close()
+
+	config := NewConfig()
+	config.Url = mockServer.URL
+	config.Compression = "none"
+	publisher := Publisher{config: config}
+
+	events := benchmarkEvents(5000)
+	events[4000]["value"] = math.NaN()
+	if publisher.Post(events) {
+		t.Error("Post should fail on encoding error")
+	}
+	if complete := <-received; complete {
+		t.Error("Server should not receive the complete body")
+	}
+}
+
+// Generates a batch of events for benchmarks.
+func benchmarkEvents(n int) []Event {
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = Event{
+			"sourceId":  fmt.Sprintf("device-%d", i%100),
+			"eventName": "sensor.reading.taken",
+			"timestamp": int64(1479988864057 + i),
+			"value":     float64(i) / 3,
+			"unit":      "celsius",
+		}
+	}
+	return events
+}
+
+// Encodes batch as Publisher did before streaming: marshal as a whole, then compress.
+func marshalAndCompress(events []Event) []byte {
+	data, _ := json.Marshal(events)
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	gz.Write(data)
+	gz.Close()
+	return buffer.Bytes()
+}
+
+func BenchmarkEncode_MarshalAndCompress(b *testing.B) {
+	events := benchmarkEvents(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ioutil.Discard.Write(marshalAndCompress(events))
+	}
+}
+
+func BenchmarkEncode_Streaming(b *testing.B) {
+	events := benchmarkEvents(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gz := gzip.NewWriter(ioutil.Discard)
+		EncodeEvents(gz, FormatJSONArray, events)
+		gz.Close()
+	}
+}
+
+func BenchmarkPublisher_Post(b *testing.B) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer mockServer.Close()
+
+	config := NewConfig()
+	config.Url = mockServer.URL
+	publisher := Publisher{config: config}
+	events := benchmarkEvents(10000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		publisher.Post(events)
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"os"
+	"runtime"
+)
+
+// Enricher adds facets to events.
+type Enricher interface {
+	// Enrich returns facets to be added to the given event.
+	// Facets explicitly set in the event are never overridden.
+	Enrich(event Event) Event
+}
+
+// EnricherFunc is an adapter to allow the use of ordinary functions as Enricher.
+type EnricherFunc func(event Event) Event
+
+// Enrich calls f(event).
+func (f EnricherFunc) Enrich(event Event) Event {
+	return f(event)
+}
+
+// StaticEnricher adds given global facets to every event.
+func StaticEnricher(facets Event) Enricher {
+	return EnricherFunc(func(Event) Event {
+		return facets
+	})
+}
+
+// HostnameEnricher adds `hostname` facet with the host name reported by the kernel.
+func HostnameEnricher() Enricher {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return StaticEnricher(nil)
+	}
+	return StaticEnricher(Event{"hostname": hostname})
+}
+
+// PidEnricher adds `pid` facet with the process id.
+func PidEnricher() Enricher {
+	return StaticEnricher(Event{"pid": os.Getpid()})
+}
+
+// GoVersionEnricher adds `goVersion` facet with the Go runtime version.
+func GoVersionEnricher() Enricher {
+	return StaticEnricher(Event{"goVersion": runtime.Version()})
+}
+
+// PlatformEnricher adds `os` and `arch` facets with GOOS and GOARCH of the running program.
+func PlatformEnricher() Enricher {
+	return StaticEnricher(Event{"os": runtime.GOOS, "arch": runtime.GOARCH})
+}
+
+// AppEnricher adds `appName` and `appVersion` facets.
+func AppEnricher(name, version string) Enricher {
+	return StaticEnricher(Event{"appName": name, "appVersion": version})
+}
+
+// RuntimeEnrichers returns built-in enrichers describing host and runtime
+// of the process: hostname, pid, Go version, GOOS and GOARCH.
+func RuntimeEnrichers() []Enricher {
+	return []Enricher{
+		HostnameEnricher(),
+		PidEnricher(),
+		GoVersionEnricher(),
+		PlatformEnricher(),
+	}
+}
+
+// Applies enrichers in the given order, keeping already present facets untouched.
+func enrichWith(e Event, enrichers []Enricher) {
+	for _, enricher := range enrichers {
+		for k, v := range enricher.Enrich(e) {
+			if _, ok := e[k]; !ok {
+				e[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestEnrichers_BuiltInFacets(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	sets := []struct {
+		enricher Enricher
+		want     Event
+	}{
+		{HostnameEnricher(), Event{"hostname": hostname}},
+		{PidEnricher(), Event{"pid": os.Getpid()}},
+		{GoVersionEnricher(), Event{"goVersion": runtime.Version()}},
+		{PlatformEnricher(), Event{"os": runtime.GOOS, "arch": runtime.GOARCH}},
+		{AppEnricher("billing", "1.2.3"), Event{"appName": "billing", "appVersion": "1.2.3"}},
+		{StaticEnricher(Event{"dc": "eu-west"}), Event{"dc": "eu-west"}},
+	}
+
+	for i, set := range sets {
+		got := set.enricher.Enrich(Event{"eventName": "foo"})
+		if !reflect.DeepEqual(got, set.want) {
+			t.Errorf("Set #%d. Want facets %+v, Got %+v", i, set.want, got)
+		}
+	}
+}
+
+func TestRuntimeEnrichers(t *testing.T) {
+	event := Event{}
+	enrichWith(event, RuntimeEnrichers())
+
+	for _, facet := range []string{"hostname", "pid", "goVersion", "os", "arch"} {
+		if _, ok := event[facet]; !ok {
+			t.Errorf("Facet %q should be added. Got %+v", facet, event)
+		}
+	}
+}
+
+func TestEvent_Enrich_AppliesEnrichersInOrderWithoutOverriding(t *testing.T) {
+	config := NewConfig()
+	config.SourceId = "mobile"
+	config.Enrichers = []Enricher{
+		StaticEnricher(Event{"color": "red", "level": 1}),
+		StaticEnricher(Event{"level": 2, "size": "L", "sourceId": "enricher"}),
+		EnricherFunc(func(e Event) Event {
+			return Event{"nameLength": len(e["eventName"].(string))}
+		}),
+	}
+
+	event := Event{"eventName": "foo", "color": "blue"}
+	event.enrich(config)
+
+	want := Event{
+		"eventName":  "foo",
+		"sourceId":   "mobile",
+		"timestamp":  event["timestamp"],
+		"color":      "blue",
+		"level":      1,
+		"size":       "L",
+		"nameLength": 3,
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("Incorrectly enriched event.\nWant: %+v\nGot: %+v", want, event)
+	}
+}
+
+func TestClient_RecordEvent_AppliesEnrichers(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "secret"
+	config.StartPublishingThread = false
+	config.Enrichers = []Enricher{AppEnricher("billing", "1.0")}
+	client, _ := NewClient(config)
+
+	client.RecordEvent(Event{"eventName": "foo"})
+
+	out := client.queue.Flush()
+	if out[0]["appName"] != "billing" || out[0]["appVersion"] != "1.0" {
+		t.Errorf("Event should be enriched with app facets. Got %+v", out[0])
+	}
+}
@@ -10,6 +10,21 @@ type EventValidationError struct {
 	Message string
 }
 
+// EventDecodeError is an error of a malformed event in NDJSON.
+type EventDecodeError struct {
+	Message string
+}
+
+// SpanError is an error of span misuse.
+type SpanError struct {
+	Message string
+}
+
+// EncodingError is an error of an unsupported content encoding.
+type EncodingError struct {
+	Message string
+}
+
 // Error returns error message.
 func (e ConfigValidationError) Error() string {
 	return e.Message
@@ -19,3 +34,18 @@ func (e ConfigValidationError) Error() string {
 func (e EventValidationError) Error() string {
 	return e.Message
 }
+
+// Error returns error message.
+func (e EventDecodeError) Error() string {
+	return e.Message
+}
+
+// Error returns error message.
+func (e SpanError) Error() string {
+	return e.Message
+}
+
+// Error returns error message.
+func (e EncodingError) Error() string {
+	return e.Message
+}
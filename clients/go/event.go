@@ -1,6 +1,8 @@
 package client
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
@@ -8,7 +10,8 @@ import (
 // Event for Samsara Ingestion API.
 type Event map[string]interface{}
 
-// Enriches missing event properties with ones from config.
+// Enriches missing event properties with ones from config
+// and then with configured enrichers chain.
 func (e Event) enrich(config Config) {
 	if e["sourceId"] == nil {
 		e["sourceId"] = config.SourceId
@@ -16,10 +19,11 @@ func (e Event) enrich(config Config) {
 	if e["timestamp"] == nil {
 		e["timestamp"] = Timestamp()
 	}
+	enrichWith(e, config.Enrichers)
 }
 
-// Validates event to conform Ingestion API requirements.
-func (e Event) validate() error {
+// Validate validates event to conform Ingestion API requirements.
+func (e Event) Validate() error {
 	mainMsg := "Field '%s' is required and must be of %s type"
 	notBlankMsg := "Field '%s' can't be blank"
 
@@ -46,3 +50,10 @@ func (e Event) validate() error {
 
 	return nil
 }
+
+// Generates a random unique identifier.
+func newId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -135,7 +135,7 @@ func TestEvent_Validate_DoesNotChangeGivenData(t *testing.T) {
 		original[k] = v
 	}
 
-	event.validate()
+	event.Validate()
 
 	if !reflect.DeepEqual(original, event) {
 		t.Errorf("Event should remain the same. Event: %+v", event)
@@ -210,7 +210,7 @@ func TestEvent_Validate_InvalidData(t *testing.T) {
 	}
 
 	for i, v := range sets {
-		err := v.event.validate()
+		err := v.event.Validate()
 		if err == nil {
 			t.Errorf("Set #%d. Expected error validation for Event %+v", i, v.event)
 		}
@@ -260,7 +260,7 @@ func TestEvent_Validate_CorrectData(t *testing.T) {
 	}
 
 	for i, event := range sets {
-		err := event.validate()
+		err := event.Validate()
 		if err != nil {
 			t.Errorf("Set #%d. Expected NO error validation for Event %+v. Got Error: %v", i, event, err)
 		}
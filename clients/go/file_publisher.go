@@ -0,0 +1,248 @@
+package client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Extensions of segment files written by FilePublisher.
+const (
+	SegmentExt     = ".ndjson"
+	SegmentGzipExt = ".ndjson.gz"
+)
+
+// FilePublisher writes batches of events as NDJSON to segment files
+// in a directory, e.g. to capture events in air-gapped environments.
+// The active segment is rotated when it exceeds the max size or age,
+// an idle one is closed once it's too old, and closed segments are
+// optionally gzipped in the background.
+// Segment names sort in the order they were written.
+type FilePublisher struct {
+	dir      string
+	maxSize  int64
+	maxAge   int64
+	compress bool
+	now      func() int64
+
+	file      *os.File
+	buffered  *bufio.Writer
+	size      int64
+	startTs   int64
+	expiry    *time.Timer
+	segments  int
+	compacted sync.WaitGroup
+	sync.Mutex
+}
+
+// NewFilePublisher creates a publisher writing segments to config.FileDir.
+// The directory is created on the first Post if it doesn't exist.
+func NewFilePublisher(config Config) *FilePublisher {
+	return &FilePublisher{
+		dir:      config.FileDir,
+		maxSize:  config.FileMaxSize,
+		maxAge:   int64(config.FileMaxAge),
+		compress: config.FileCompress,
+		now:      Timestamp,
+	}
+}
+
+// Post appends events to the active segment, one per line.
+// Events are flushed to the file before returning. If any of them fails,
+// the segment is truncated back, so that a retried batch isn't written twice.
+func (p *FilePublisher) Post(data []Event) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if err := p.rotateIfNeeded(); err != nil {
+		return false
+	}
+	counter := &countingWriter{w: p.buffered}
+	err := EncodeEvents(counter, FormatNDJSON, data)
+	if err == nil {
+		err = p.buffered.Flush()
+	}
+	if err != nil {
+		p.truncate()
+		return false
+	}
+	p.size += counter.n
+	return true
+}
+
+// Close closes the active segment and waits until closed segments are compressed.
+func (p *FilePublisher) Close() error {
+	p.Lock()
+	err := p.closeSegment()
+	p.Unlock()
+	p.compacted.Wait()
+	return err
+}
+
+// Rotate closes the active segment, the next Post opens a new one.
+func (p *FilePublisher) Rotate() error {
+	p.Lock()
+	defer p.Unlock()
+	return p.closeSegment()
+}
+
+// Segments returns paths of closed segments in the order they were written.
+// It waits until closed segments are compressed.
+func (p *FilePublisher) Segments() ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.compacted.Wait()
+
+	files, err := ioutil.ReadDir(p.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var segments []string
+	for _, file := range files {
+		path := filepath.Join(p.dir, file.Name())
+		if p.file != nil && path == p.file.Name() {
+			continue
+		}
+		if strings.HasSuffix(path, SegmentExt) || strings.HasSuffix(path, SegmentGzipExt) {
+			segments = append(segments, path)
+		}
+	}
+	return segments, nil
+}
+
+// Discards events written since the last successful Post.
+// The segment is closed if it can't be truncated.
+func (p *FilePublisher) truncate() {
+	p.buffered.Reset(p.file)
+	if p.file.Truncate(p.size) != nil {
+		p.closeSegment()
+		return
+	}
+	if _, err := p.file.Seek(p.size, io.SeekStart); err != nil {
+		p.closeSegment()
+	}
+}
+
+// Opens a new segment if there is none yet or the active one is full or too old.
+func (p *FilePublisher) rotateIfNeeded() error {
+	now := p.now()
+	if p.file != nil && p.size < p.maxSize && (p.maxAge == 0 || now-p.startTs < p.maxAge) {
+		return nil
+	}
+	if err := p.closeSegment(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+
+	p.segments++
+	name := fmt.Sprintf("events-%013d-%04d%s", now, p.segments, SegmentExt)
+	file, err := os.OpenFile(filepath.Join(p.dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	p.file = file
+	p.buffered = bufio.NewWriter(file)
+	p.size = 0
+	p.startTs = now
+	if p.maxAge > 0 {
+		p.expiry = time.AfterFunc(time.Duration(p.maxAge)*time.Millisecond, func() { p.expire(file) })
+	}
+	return nil
+}
+
+// Closes the segment once it's too old, so that an idle publisher
+// doesn't keep it open. The next Post opens a new one.
+func (p *FilePublisher) expire(file *os.File) {
+	p.Lock()
+	defer p.Unlock()
+	if p.file == file {
+		p.closeSegment()
+	}
+}
+
+// Closes the active segment and schedules its compression.
+func (p *FilePublisher) closeSegment() error {
+	if p.file == nil {
+		return nil
+	}
+	file := p.file
+	p.file = nil
+	if p.expiry != nil {
+		p.expiry.Stop()
+		p.expiry = nil
+	}
+	err := p.buffered.Flush()
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if p.compress {
+		p.compacted.Add(1)
+		go func() {
+			defer p.compacted.Done()
+			gzipSegment(file.Name())
+		}()
+	}
+	return nil
+}
+
+// Compresses the segment file and removes the original.
+// The original is kept if the compression fails.
+func gzipSegment(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	target := path[:len(path)-len(SegmentExt)] + SegmentGzipExt
+	tmp := target + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Writer counting written bytes.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes to the underlying writer and counts bytes.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFilePublisher(t *testing.T, maxSize int64, maxAge uint32, compress bool) (*FilePublisher, *int64) {
+	config := NewConfig()
+	config.FileDir = filepath.Join(t.TempDir(), "segments")
+	config.FileMaxSize = maxSize
+	config.FileMaxAge = maxAge
+	config.FileCompress = compress
+	now := int64(1000)
+	p := NewFilePublisher(config)
+	p.now = func() int64 { return now }
+	return p, &now
+}
+
+// Returns names and contents of segment files, gzipped ones decompressed.
+func readSegments(t *testing.T, dir string) ([]string, []string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, contents []string
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		data, _ := ioutil.ReadFile(path)
+		if strings.HasSuffix(path, SegmentGzipExt) {
+			f, _ := os.Open(path)
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ = ioutil.ReadAll(gz)
+			f.Close()
+		}
+		names = append(names, file.Name())
+		contents = append(contents, string(data))
+	}
+	return names, contents
+}
+
+func TestFilePublisher_Post(t *testing.T) {
+	p, _ := newTestFilePublisher(t, 1024, 0, false)
+
+	p.Post([]Event{{"eventName": "a"}})
+	p.Post([]Event{{"eventName": "b"}, {"eventName": "c"}})
+
+	names, contents := readSegments(t, p.dir)
+	if len(names) != 1 || !strings.HasSuffix(names[0], SegmentExt) {
+		t.Fatalf("Events should be written to a single segment. Got %v", names)
+	}
+	want := "{\"eventName\":\"a\"}\n{\"eventName\":\"b\"}\n{\"eventName\":\"c\"}\n"
+	if contents[0] != want {
+		t.Errorf("Incorrect segment.\nWant: %q\nGot: %q", want, contents[0])
+	}
+}
+
+func TestFilePublisher_Post_RotatesBySizeAndAge(t *testing.T) {
+	p, now := newTestFilePublisher(t, 30, 1000, false)
+
+	p.Post([]Event{{"eventName": "a"}})
+	p.Post([]Event{{"eventName": "b"}}) // exceeds size after this batch
+	p.Post([]Event{{"eventName": "c"}})
+	*now += 1000
+	p.Post([]Event{{"eventName": "d"}})
+	p.Close()
+
+	names, contents := readSegments(t, p.dir)
+	want := []string{
+		"{\"eventName\":\"a\"}\n{\"eventName\":\"b\"}\n",
+		"{\"eventName\":\"c\"}\n",
+		"{\"eventName\":\"d\"}\n",
+	}
+	if len(names) != 3 || !sort.StringsAreSorted(names) {
+		t.Fatalf("Segments should be rotated and sorted by name. Got %v", names)
+	}
+	for i := range want {
+		if contents[i] != want[i] {
+			t.Errorf("Set #%d. Want: %q Got: %q", i, want[i], contents[i])
+		}
+	}
+}
+
+func TestFilePublisher_ClosesIdleSegmentByAge(t *testing.T) {
+	p, _ := newTestFilePublisher(t, 1024, 20, false)
+	defer p.Close()
+
+	p.Post([]Event{{"eventName": "a"}})
+	for i := 0; i < 100; i++ {
+		if segments, _ := p.Segments(); len(segments) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Idle segment should be closed once it's too old")
+}
+
+func TestFilePublisher_Close_GzipsClosedSegments(t *testing.T) {
+	p, now := newTestFilePublisher(t, 1024, 1000, true)
+
+	p.Post([]Event{{"eventName": "a"}})
+	*now += 1000
+	p.Post([]Event{{"eventName": "b"}})
+	p.Close()
+
+	names, contents := readSegments(t, p.dir)
+	if len(names) != 2 || !strings.HasSuffix(names[0], SegmentGzipExt) || !strings.HasSuffix(names[1], SegmentGzipExt) {
+		t.Fatalf("Closed segments should be gzipped. Got %v", names)
+	}
+	if contents[0] != "{\"eventName\":\"a\"}\n" || contents[1] != "{\"eventName\":\"b\"}\n" {
+		t.Errorf("Incorrect segments. Got %q", contents)
+	}
+}
+
+func TestFilePublisher_Post_FailedBatchIsNotWritten(t *testing.T) {
+	p, _ := newTestFilePublisher(t, 1024, 0, false)
+
+	p.Post([]Event{{"eventName": "a"}})
+	if p.Post([]Event{{"eventName": "b"}, {"eventName": "c", "value": math.NaN()}}) {
+		t.Error("Post should fail on encoding error")
+	}
+	p.Post([]Event{{"eventName": "d"}})
+	p.Close()
+
+	_, contents := readSegments(t, p.dir)
+	want := "{\"eventName\":\"a\"}\n{\"eventName\":\"d\"}\n"
+	if len(contents) != 1 || contents[0] != want {
+		t.Errorf("Failed batch should be discarded.\nWant: %q\nGot: %q", want, contents)
+	}
+}
+
+func TestClient_FileTransport(t *testing.T) {
+	config := NewConfig()
+	config.Transport = "file"
+	config.FileDir = t.TempDir()
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := client.PublishEvents([]Event{{"eventName": "a", "timestamp": int64(1)}}); !ok {
+		t.Fatal("Events should be written")
+	}
+	client.publisher.(*FilePublisher).Close()
+
+	_, contents := readSegments(t, config.FileDir)
+	if len(contents) != 1 || contents[0] != "{\"eventName\":\"a\",\"sourceId\":\"dev1\",\"timestamp\":1}\n" {
+		t.Errorf("Incorrect segments. Got %q", contents)
+	}
+}
+
+func TestFilePublisher_Segments(t *testing.T) {
+	p, now := newTestFilePublisher(t, 1024, 0, true)
+
+	if segments, err := p.Segments(); err != nil || len(segments) != 0 {
+		t.Errorf("There should be no segments before the first Post. Got %v, %v", segments, err)
+	}
+
+	p.Post([]Event{{"eventName": "a"}})
+	p.Rotate()
+	*now += 1000
+	p.Post([]Event{{"eventName": "b"}})
+
+	segments, err := p.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || !strings.HasSuffix(segments[0], SegmentGzipExt) {
+		t.Fatalf("Only the closed and compressed segment should be listed. Got %v", segments)
+	}
+
+	p.Rotate()
+	segments, _ = p.Segments()
+	if len(segments) != 2 || !sort.StringsAreSorted(segments) {
+		t.Errorf("Rotated segment should be listed. Got %v", segments)
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Compiled glob patterns, so common patterns are compiled only once.
+var globPatterns sync.Map
+
+// MatchGlob simplifies matching of names in a dotted form
+// <segment>.<segment>.<...>.<segment>.
+// A single `*` matches any single segment (or a part of it),
+// while `**` matches multiple segments.
+// For example:
+//
+//	MatchGlob("game.*.started", "game.level.started")    => true
+//	MatchGlob("game.*.started", "game.level.2.started")  => false
+//	MatchGlob("game.**.started", "game.level.2.started") => true
+//	MatchGlob("game.**", "game.anything.else")           => true
+//
+// It follows the semantic of moebius `match-glob`.
+func MatchGlob(glob, name string) bool {
+	return globPattern(glob).MatchString(name)
+}
+
+// Compiles glob pattern down to a regular expression.
+func globPattern(glob string) *regexp.Regexp {
+	if re, ok := globPatterns.Load(glob); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			pattern.WriteString(".*")
+			i += 2
+		case glob[i] == '*':
+			pattern.WriteString("[^.]*")
+			i++
+		default:
+			next := strings.IndexByte(glob[i:], '*')
+			if next < 0 {
+				next = len(glob) - i
+			}
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+next]))
+			i += next
+		}
+	}
+	pattern.WriteString("$")
+
+	re := regexp.MustCompile(pattern.String())
+	globPatterns.Store(glob, re)
+	return re
+}
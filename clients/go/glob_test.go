@@ -0,0 +1,35 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	sets := []struct {
+		glob string
+		name string
+		want bool
+	}{
+		{"game.started", "game.started", true},
+		{"game.started", "game.stopped", false},
+		{"game.*.started", "game.level.started", true},
+		{"game.*.started", "game.level.2.started", false},
+		{"game.**.started", "game.level.2.started", true},
+		{"game.**", "game.level.5.stopped", true},
+		{"game.**", "game.anything.else", true},
+		{"game.**.ended", "game.1.2.3.ended", true},
+		{"ui.mouse.*", "ui.mouse.moved", true},
+		{"ui.mouse.*", "ui.keyboard.pressed", false},
+		{"*Token", "accessToken", true},
+		{"*", "user.email", false},
+		{"**", "user.email", true},
+		{"cache(hit)+", "cache(hit)+", true},
+		{"cache.hit", "cacheXhit", false},
+	}
+
+	for i, set := range sets {
+		if got := MatchGlob(set.glob, set.name); got != set.want {
+			t.Errorf("Set #%d. MatchGlob(%q, %q) should be %t", i, set.glob, set.name, set.want)
+		}
+	}
+}
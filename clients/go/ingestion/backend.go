@@ -0,0 +1,106 @@
+package ingestion
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Backend is the queueing system accepted events are sent to,
+// e.g. Kafka, or the console for testing purposes.
+type Backend interface {
+	// Send sends the events to the backend. Events are acknowledged
+	// to the client only if it succeeds.
+	Send(events []client.Event) error
+}
+
+// BackendFunc adapts a function to Backend.
+type BackendFunc func(events []client.Event) error
+
+// Send calls f(events).
+func (f BackendFunc) Send(events []client.Event) error {
+	return f(events)
+}
+
+// ConsoleBackend writes events as JSON to a writer, one per line,
+// or indented if pretty. Batches aren't interleaved.
+type ConsoleBackend struct {
+	w      io.Writer
+	pretty bool
+	sync.Mutex
+}
+
+// NewConsoleBackend creates a backend writing events to stdout.
+func NewConsoleBackend(pretty bool) *ConsoleBackend {
+	return NewWriterBackend(os.Stdout, pretty)
+}
+
+// NewWriterBackend creates a backend writing events to w.
+func NewWriterBackend(w io.Writer, pretty bool) *ConsoleBackend {
+	return &ConsoleBackend{w: w, pretty: pretty}
+}
+
+// Send writes the events.
+func (b *ConsoleBackend) Send(events []client.Event) error {
+	b.Lock()
+	defer b.Unlock()
+
+	buffered := bufio.NewWriter(b.w)
+	if !b.pretty {
+		if err := client.EncodeEvents(buffered, client.FormatNDJSON, events); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}
+
+	enc := json.NewEncoder(buffered)
+	enc.SetIndent("", "  ")
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// PublisherBackend sends events by a client publisher, e.g. to segment
+// files by client.FilePublisher, or upstream by client.Publisher.
+type PublisherBackend struct {
+	publisher client.IPublisher
+}
+
+// NewPublisherBackend creates a backend sending events by the publisher.
+func NewPublisherBackend(publisher client.IPublisher) *PublisherBackend {
+	return &PublisherBackend{publisher: publisher}
+}
+
+// NewFileBackend creates a backend writing events to segment files
+// in config.FileDir, see client.FilePublisher.
+func NewFileBackend(config client.Config) (*PublisherBackend, error) {
+	config.Transport = "file"
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewPublisherBackend(client.NewFilePublisher(config)), nil
+}
+
+// Send posts the events by the publisher.
+func (b *PublisherBackend) Send(events []client.Event) error {
+	if !b.publisher.Post(events) {
+		return errors.New("events couldn't be sent to the backend")
+	}
+	return nil
+}
+
+// Close closes the publisher, if it can be closed.
+func (b *PublisherBackend) Close() error {
+	if closer, ok := b.publisher.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package ingestion
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+func TestConsoleBackend(t *testing.T) {
+	events := []client.Event{{"eventName": "a", "n": 1}, {"eventName": "b"}}
+	var tests = []struct {
+		pretty bool
+		want   string
+	}{
+		{false, "{\"eventName\":\"a\",\"n\":1}\n{\"eventName\":\"b\"}\n"},
+		{true, "{\n  \"eventName\": \"a\",\n  \"n\": 1\n}\n{\n  \"eventName\": \"b\"\n}\n"},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := NewWriterBackend(&buf, test.pretty).Send(events); err != nil {
+			t.Errorf("Set #%d. Unexpected error: %v", i, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("Set #%d.\nWant: %q\nGot: %q", i, test.want, buf.String())
+		}
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	config := client.NewConfig()
+	config.FileDir = t.TempDir()
+	config.FileCompress = false
+	backend, err := NewFileBackend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Send([]client.Event{{"eventName": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(config.FileDir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("Events should be written to a segment. Got %v", files)
+	}
+	if data, _ := ioutil.ReadFile(files[0]); string(data) != "{\"eventName\":\"a\"}\n" {
+		t.Errorf("Incorrect segment content. Got %q", data)
+	}
+
+	config.FileDir = ""
+	if _, err := NewFileBackend(config); err == nil {
+		t.Error("Backend without FileDir should not be created")
+	}
+}
+
+func TestPublisherBackend_Failure(t *testing.T) {
+	config := client.NewConfig()
+	config.Url = "http://127.0.0.1:1"
+	if err := NewPublisherBackend(client.NewPublisher(config)).Send([]client.Event{{"eventName": "a"}}); err == nil {
+		t.Error("Failed post should be reported")
+	}
+}
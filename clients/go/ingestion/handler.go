@@ -0,0 +1,192 @@
+// Package ingestion implements the Samsara Ingestion API as an embeddable
+// http.Handler, per ingestion-api-spec.yaml. Accepted events are validated,
+// enriched with `receivedAt` and `publishedAt` and sent to a Backend, e.g.
+//
+//	handler := ingestion.NewHandler(ingestion.NewConsoleBackend(false))
+//	http.ListenAndServe(":9000", handler)
+//	http.ListenAndServe("127.0.0.1:9010", handler.AdminHandler())
+package ingestion
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// MissingHeaderWarning is returned when events are accepted without
+// the X-Samsara-publishedTimestamp header.
+const MissingHeaderWarning = "For completeness, please provide the 'X-Samsara-publishedTimestamp' header."
+
+// DefaultMaxRequestSize is the default max size of a request body in bytes.
+const DefaultMaxRequestSize = 10 * 1024 * 1024
+
+// Content types accepted by POST /v1/events.
+var jsonContentType = regexp.MustCompile(`^application/(.+\+)?json`)
+
+// Handler serves POST /v1/events and GET /v1/api-status.
+type Handler struct {
+	offline int32
+
+	backend        Backend
+	maxRequestSize int64
+}
+
+// NewHandler creates a handler sending accepted events to the backend.
+// The handler is online.
+func NewHandler(backend Backend) *Handler {
+	return &Handler{backend: backend, maxRequestSize: DefaultMaxRequestSize}
+}
+
+// SetMaxRequestSize sets the max size of a request body in bytes,
+// both as received and decompressed. Larger requests are answered 413.
+// It should be set before the handler starts serving.
+func (h *Handler) SetMaxRequestSize(size int64) {
+	h.maxRequestSize = size
+}
+
+// SetOnline sets the status reported by GET /v1/api-status, so that
+// load balancers can take the instance out of service. Events are
+// accepted either way.
+func (h *Handler) SetOnline(online bool) {
+	var offline int32
+	if !online {
+		offline = 1
+	}
+	atomic.StoreInt32(&h.offline, offline)
+}
+
+// Online tells whether the handler reports it's online.
+func (h *Handler) Online() bool {
+	return atomic.LoadInt32(&h.offline) == 0
+}
+
+// ServeHTTP serves the Ingestion API.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == client.API_PATH && r.Method == "POST":
+		h.postEvents(w, r)
+	case r.URL.Path == client.STATUS_PATH && r.Method == "GET":
+		h.getStatus(w)
+	default:
+		Reply(w, http.StatusNotFound, ErrorBody("Not found"))
+	}
+}
+
+// AdminHandler returns handler of the admin port serving
+// GET and PUT /v1/api-status. It shouldn't be reachable by clients.
+func (h *Handler) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == client.STATUS_PATH && r.Method == "GET":
+			h.getStatus(w)
+		case r.URL.Path == client.STATUS_PATH && r.Method == "PUT":
+			h.putStatus(w, r)
+		default:
+			Reply(w, http.StatusNotFound, ErrorBody("Not found"))
+		}
+	})
+}
+
+// Validates, enriches and sends events to the backend.
+// Responds 202 if they are accepted, 400 if any of them is invalid,
+// 413 if the request is too large, and 500 if the backend fails.
+func (h *Handler) postEvents(w http.ResponseWriter, r *http.Request) {
+	if !jsonContentType.MatchString(r.Header.Get("Content-Type")) {
+		Reply(w, http.StatusBadRequest, ErrorBody("Invalid format, content-type must be application/json"))
+		return
+	}
+	header := r.Header.Get(client.PUBLISHED_TIMESTAMP_HEADER)
+	publishedAt, err := strconv.ParseInt(header, 10, 64)
+	if header != "" && err != nil {
+		Reply(w, http.StatusBadRequest, ErrorBody("X-Samsara-publishedTimestamp must be a valid timestamp."))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxRequestSize)
+	body, err := Decompress(r)
+	switch {
+	case tooLarge(err):
+		Reply(w, http.StatusRequestEntityTooLarge, ErrorBody("Request body too large"))
+		return
+	case err != nil:
+		Reply(w, http.StatusBadRequest, ErrorBody(err.Error()))
+		return
+	}
+	events, err := DecodeEvents(http.MaxBytesReader(w, ioutil.NopCloser(body), h.maxRequestSize))
+	switch {
+	case tooLarge(err):
+		Reply(w, http.StatusRequestEntityTooLarge, ErrorBody("Request body too large"))
+		return
+	case err != nil:
+		Reply(w, http.StatusBadRequest, ErrorBody("Malformed JSON in request body"))
+		return
+	}
+	if results, ok := Validate(events); !ok {
+		Reply(w, http.StatusBadRequest, results)
+		return
+	}
+
+	InjectReceivedAt(client.Timestamp(), events)
+	if header != "" {
+		InjectPublishedAt(publishedAt, events)
+	}
+	if err := h.backend.Send(events); err != nil {
+		Reply(w, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if header == "" {
+		Reply(w, http.StatusAccepted, map[string]string{"status": "OK", "warning": MissingHeaderWarning})
+		return
+	}
+	Reply(w, http.StatusAccepted, nil)
+}
+
+// Serves GET /v1/api-status.
+func (h *Handler) getStatus(w http.ResponseWriter) {
+	if !h.Online() {
+		Reply(w, http.StatusServiceUnavailable, map[string]string{"status": "offline"})
+		return
+	}
+	Reply(w, http.StatusOK, map[string]string{"status": "online"})
+}
+
+// Serves PUT /v1/api-status with `{"status": "online"}` or "offline".
+func (h *Handler) putStatus(w http.ResponseWriter, r *http.Request) {
+	var status struct{ Status string }
+	json.NewDecoder(r.Body).Decode(&status)
+	if status.Status != "online" && status.Status != "offline" {
+		Reply(w, http.StatusBadRequest, nil)
+		return
+	}
+	h.SetOnline(status.Status == "online")
+	Reply(w, http.StatusOK, nil)
+}
+
+// Tells whether the error is caused by a request body exceeding the max size.
+func tooLarge(err error) bool {
+	var maxBytes *http.MaxBytesError
+	return errors.As(err, &maxBytes)
+}
+
+// ErrorBody returns the body of an error response of the Ingestion API.
+func ErrorBody(message string) map[string]string {
+	return map[string]string{"status": "ERROR", "message": message}
+}
+
+// Reply writes the response with a JSON body, unless the body is nil.
+func Reply(w http.ResponseWriter, status int, body interface{}) {
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,181 @@
+package ingestion
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Backend collecting sent events.
+type memoryBackend struct {
+	events []client.Event
+	err    error
+	sync.Mutex
+}
+
+func (b *memoryBackend) Send(events []client.Event) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.events = append(b.events, events...)
+	return nil
+}
+
+func serve(h http.Handler, method, path string, header map[string]string, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code, strings.TrimSpace(w.Body.String())
+}
+
+func TestHandler_PostEvents(t *testing.T) {
+	backend := &memoryBackend{}
+	h := NewHandler(backend)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`[{"eventName":"b","sourceId":"s","timestamp":1}]`))
+	gz.Close()
+
+	json := map[string]string{"Content-Type": "application/json", client.PUBLISHED_TIMESTAMP_HEADER: "2"}
+	var tests = []struct {
+		header map[string]string
+		body   string
+		status int
+		reply  string
+	}{
+		{json, `[{"eventName":"a","sourceId":"s","timestamp":1}]`, 202, ``},
+		{map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"}, compressed.String(), 202,
+			`{"status":"OK","warning":"` + MissingHeaderWarning + `"}`},
+		{map[string]string{"Content-Type": "application/vnd.samsara+json"}, `[]`, 202,
+			`{"status":"OK","warning":"` + MissingHeaderWarning + `"}`},
+		{json, `[{"eventName":"a","sourceId":"s"}]`, 400, `[{"timestamp":"missing required key"}]`},
+		{json, `[{"eventName":"a"`, 400, `{"message":"Malformed JSON in request body","status":"ERROR"}`},
+		{map[string]string{"Content-Type": "text/plain"}, `[]`, 400,
+			`{"message":"Invalid format, content-type must be application/json","status":"ERROR"}`},
+		{map[string]string{"Content-Type": "application/json", client.PUBLISHED_TIMESTAMP_HEADER: "now"}, `[]`, 400,
+			`{"message":"X-Samsara-publishedTimestamp must be a valid timestamp.","status":"ERROR"}`},
+		{map[string]string{"Content-Type": "application/json", "Content-Encoding": "br"}, `[]`, 400,
+			`{"message":"Unsupported content encoding br","status":"ERROR"}`},
+	}
+
+	for i, test := range tests {
+		status, reply := serve(h, "POST", client.API_PATH, test.header, test.body)
+		if status != test.status || reply != test.reply {
+			t.Errorf("Set #%d.\nWant: %d %s\nGot: %d %s", i, test.status, test.reply, status, reply)
+		}
+	}
+
+	if len(backend.events) != 2 {
+		t.Fatalf("Accepted events should be sent to the backend. Got %v", backend.events)
+	}
+	if a := backend.events[0]; a["publishedAt"] != int64(2) || a["receivedAt"] == nil {
+		t.Errorf("Events should be enriched. Got %v", a)
+	}
+	if b := backend.events[1]; b["publishedAt"] != nil || b["receivedAt"] == nil {
+		t.Errorf("publishedAt should be injected only with the header. Got %v", b)
+	}
+}
+
+func TestHandler_PostEvents_TooLarge(t *testing.T) {
+	h := NewHandler(&memoryBackend{})
+	h.SetMaxRequestSize(100)
+
+	events := `[` + strings.Repeat(`{"eventName":"a","sourceId":"s","timestamp":1},`, 10) + `{"eventName":"a","sourceId":"s","timestamp":1}]`
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(events))
+	gz.Close()
+	if compressed.Len() > 100 {
+		t.Fatalf("Compressed body should fit the limit. Got %d bytes", compressed.Len())
+	}
+
+	json := map[string]string{"Content-Type": "application/json"}
+	gzipped := map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"}
+	var tests = []struct {
+		header map[string]string
+		body   string
+		status int
+	}{
+		{json, `[{"eventName":"a","sourceId":"s","timestamp":1}]`, 202},
+		{json, events, 413},
+		{gzipped, compressed.String(), 413},
+	}
+
+	for i, test := range tests {
+		if status, reply := serve(h, "POST", client.API_PATH, test.header, test.body); status != test.status {
+			t.Errorf("Set #%d.\nWant: %d\nGot: %d %s", i, test.status, status, reply)
+		}
+	}
+}
+
+func TestHandler_PostEvents_BackendFailure(t *testing.T) {
+	h := NewHandler(&memoryBackend{err: errors.New("kafka is down")})
+	status, _ := serve(h, "POST", client.API_PATH, map[string]string{"Content-Type": "application/json"},
+		`[{"eventName":"a","sourceId":"s","timestamp":1}]`)
+	if status != http.StatusInternalServerError {
+		t.Errorf("Events should not be acknowledged. Got %d", status)
+	}
+}
+
+func TestHandler_ApiStatus(t *testing.T) {
+	h := NewHandler(&memoryBackend{})
+	admin := h.AdminHandler()
+
+	var tests = []struct {
+		handler http.Handler
+		method  string
+		body    string
+		status  int
+		reply   string
+	}{
+		{h, "GET", ``, 200, `{"status":"online"}`},
+		{h, "PUT", `{"status":"offline"}`, 404, `{"message":"Not found","status":"ERROR"}`},
+		{admin, "PUT", `{"status":"offline"}`, 200, ``},
+		{h, "GET", ``, 503, `{"status":"offline"}`},
+		{admin, "GET", ``, 503, `{"status":"offline"}`},
+		{admin, "PUT", `{"status":"maintenance"}`, 400, ``},
+		{admin, "PUT", `{"status":"online"}`, 200, ``},
+		{h, "GET", ``, 200, `{"status":"online"}`},
+		{admin, "POST", ``, 404, `{"message":"Not found","status":"ERROR"}`},
+	}
+
+	for i, test := range tests {
+		status, reply := serve(test.handler, test.method, client.STATUS_PATH, nil, test.body)
+		if status != test.status || reply != test.reply {
+			t.Errorf("Set #%d.\nWant: %d %s\nGot: %d %s", i, test.status, test.reply, status, reply)
+		}
+	}
+}
+
+func TestHandler_PublishedByClient(t *testing.T) {
+	backend := &memoryBackend{}
+	server := httptest.NewServer(NewHandler(backend))
+	defer server.Close()
+
+	config := client.NewConfig()
+	config.Url = server.URL
+	config.StartPublishingThread = false
+	c, err := client.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.PublishEvents([]client.Event{{"eventName": "a", "sourceId": "s"}}); !ok || err != nil {
+		t.Fatalf("Events should be accepted. Got %v %v", ok, err)
+	}
+	if len(backend.events) != 1 || backend.events[0]["eventName"] != "a" {
+		t.Errorf("Events should be sent to the backend. Got %v", backend.events)
+	}
+}
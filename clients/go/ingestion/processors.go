@@ -0,0 +1,99 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Decompress returns the body of the request, decompressed according
+// to its Content-Encoding (see client.Decompress).
+func Decompress(r *http.Request) (io.Reader, error) {
+	return client.Decompress(r.Header.Get("Content-Encoding"), r.Body)
+}
+
+// DecodeEvents decodes a JSON array of events. Integral numbers are
+// decoded as int64, others as float64.
+func DecodeEvents(r io.Reader) ([]client.Event, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var events []client.Event
+	if err := dec.Decode(&events); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the events")
+	}
+	if events == nil {
+		return nil, errors.New("events should be an array")
+	}
+	for _, event := range events {
+		if event == nil {
+			return nil, errors.New("events should be objects")
+		}
+		for name, value := range event {
+			event[name] = client.ConvertNumbers(value)
+		}
+	}
+	return events, nil
+}
+
+// Validate checks events against the schema of the spec: `timestamp`
+// is an integer, `sourceId` and `eventName` are strings, and any other
+// facets are allowed. Returns "OK" or problems by field name for each
+// event, and whether all events are valid.
+func Validate(events []client.Event) ([]interface{}, bool) {
+	results := make([]interface{}, len(events))
+	valid := true
+	for i, event := range events {
+		problems := map[string]string{}
+		if _, ok := event["timestamp"].(int64); !ok {
+			problems["timestamp"] = problem(event, "timestamp", "integer")
+		}
+		if _, ok := event["sourceId"].(string); !ok {
+			problems["sourceId"] = problem(event, "sourceId", "string")
+		}
+		if _, ok := event["eventName"].(string); !ok {
+			problems["eventName"] = problem(event, "eventName", "string")
+		}
+
+		if len(problems) == 0 {
+			results[i] = "OK"
+		} else {
+			results[i] = problems
+			valid = false
+		}
+	}
+	return results, valid
+}
+
+// Describes a field which is missing or has a wrong type.
+func problem(event client.Event, field, kind string) string {
+	if _, ok := event[field]; !ok {
+		return "missing required key"
+	}
+	return "should be " + kind
+}
+
+// InjectReceivedAt sets `receivedAt` of events which don't have it
+// to the time the events were received by the server.
+func InjectReceivedAt(receivedAt int64, events []client.Event) {
+	for _, event := range events {
+		if _, ok := event["receivedAt"]; !ok {
+			event["receivedAt"] = receivedAt
+		}
+	}
+}
+
+// InjectPublishedAt sets `publishedAt` of events which don't have it
+// to the time the events were sent by the client.
+func InjectPublishedAt(publishedAt int64, events []client.Event) {
+	for _, event := range events {
+		if _, ok := event["publishedAt"]; !ok {
+			event["publishedAt"] = publishedAt
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ingestion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+func TestDecodeEvents(t *testing.T) {
+	var tests = []struct {
+		input string
+		want  []client.Event
+	}{
+		{`[]`, []client.Event{}},
+		{` [{"eventName":"a","timestamp":1,"ratio":0.5}] `, []client.Event{{"eventName": "a", "timestamp": int64(1), "ratio": 0.5}}},
+		{`[{"nested":{"n":2},"list":[3,1.5]},{}]`, []client.Event{
+			{"nested": map[string]interface{}{"n": int64(2)}, "list": []interface{}{int64(3), 1.5}}, {},
+		}},
+	}
+
+	for i, test := range tests {
+		events, err := DecodeEvents(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("Set #%d. Unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(events, test.want) {
+			t.Errorf("Set #%d.\nWant: %#v\nGot: %#v", i, test.want, events)
+		}
+	}
+}
+
+func TestDecodeEvents_Errors(t *testing.T) {
+	var tests = []string{
+		``,
+		`null`,
+		`{"eventName":"a"}`,
+		`[{"eventName":"a"}`,
+		`[{"eventName":"a"}] []`,
+		"{\"eventName\":\"a\"}\n{\"eventName\":\"b\"}",
+		`[1]`,
+		`[null]`,
+	}
+
+	for i, input := range tests {
+		if _, err := DecodeEvents(strings.NewReader(input)); err == nil {
+			t.Errorf("Set #%d. Decoding %q should fail", i, input)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	events := []client.Event{
+		{"eventName": "a", "sourceId": "s", "timestamp": int64(1), "any": "facet"},
+		{"eventName": "", "sourceId": "", "timestamp": int64(-1)},
+		{"eventName": 1, "sourceId": nil, "timestamp": 1.5},
+		{},
+	}
+	want := []interface{}{
+		"OK",
+		"OK",
+		map[string]string{"eventName": "should be string", "sourceId": "should be string", "timestamp": "should be integer"},
+		map[string]string{"eventName": "missing required key", "sourceId": "missing required key", "timestamp": "missing required key"},
+	}
+
+	results, ok := Validate(events)
+	if ok {
+		t.Error("Events should be invalid")
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Want: %v\nGot: %v", want, results)
+	}
+	if _, ok := Validate(events[:2]); !ok {
+		t.Error("Events conforming the schema should be valid")
+	}
+}
+
+func TestInjectReceivedAtAndPublishedAt(t *testing.T) {
+	events := []client.Event{{"eventName": "a"}, {"eventName": "b", "receivedAt": int64(1), "publishedAt": int64(2)}}
+	InjectReceivedAt(10, events)
+	InjectPublishedAt(20, events)
+
+	want := []client.Event{
+		{"eventName": "a", "receivedAt": int64(10), "publishedAt": int64(20)},
+		{"eventName": "b", "receivedAt": int64(1), "publishedAt": int64(2)},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Want: %v\nGot: %v", want, events)
+	}
+}
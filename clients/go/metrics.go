@@ -0,0 +1,462 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Metric types recorded in the `metricType` facet of metric events.
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+// Max number of values kept per interval to estimate histogram percentiles.
+const histogramReservoirSize = 1024
+
+// Percentiles reported by histograms.
+var histogramPercentiles = []struct {
+	facet string
+	q     float64
+}{
+	{"p50", 0.50},
+	{"p90", 0.90},
+	{"p95", 0.95},
+	{"p99", 0.99},
+}
+
+// Registration of a metric in the registry. Metrics which haven't been
+// updated between two flushes are evicted from the registry and registered
+// again once updated. Guarded by the lock of the metric.
+type registration struct {
+	entry   *registeredMetric
+	updated bool
+	evicted bool
+}
+
+// Sets the registry entry of the metric.
+func (g *registration) setEntry(entry *registeredMetric) {
+	g.entry = entry
+}
+
+// Marks the metric updated. Returns its entry to register again if it was evicted.
+func (g *registration) update() *registeredMetric {
+	g.updated = true
+	if !g.evicted {
+		return nil
+	}
+	g.evicted = false
+	return g.entry
+}
+
+// Marks the metric evicted unless it has been updated since the last call.
+func (g *registration) evictIdle() bool {
+	if g.updated {
+		g.updated = false
+		return false
+	}
+	g.evicted = true
+	return true
+}
+
+// Counter aggregates increments, e.g. number of requests served.
+type Counter struct {
+	count uint64
+	sum   float64
+	registration
+	sync.Mutex
+}
+
+// Inc increments the counter by one.
+func (m *Counter) Inc() {
+	m.Add(1)
+}
+
+// Add increments the counter by the given delta.
+func (m *Counter) Add(delta float64) {
+	m.Lock()
+	m.count++
+	m.sum += delta
+	evicted := m.update()
+	m.Unlock()
+	evicted.register()
+}
+
+// Evicts the counter unless it has been updated since the last eviction check.
+func (m *Counter) evict() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.evictIdle()
+}
+
+// Returns facets aggregated since the last flush and resets the counter.
+// Idle counters report nothing.
+func (m *Counter) flush() (Event, func()) {
+	m.Lock()
+	defer m.Unlock()
+	if m.count == 0 {
+		return nil, nil
+	}
+	count, sum := m.count, m.sum
+	m.count, m.sum = 0, 0
+	restore := func() {
+		m.Lock()
+		defer m.Unlock()
+		m.count += count
+		m.sum += sum
+	}
+	return Event{"count": count, "sum": sum}, restore
+}
+
+// Gauge tracks the current value of something, e.g. queue length.
+type Gauge struct {
+	isSet bool
+	value float64
+	count uint64
+	min   float64
+	max   float64
+	registration
+	sync.Mutex
+}
+
+// Set sets the current value of the gauge.
+func (m *Gauge) Set(value float64) {
+	m.Lock()
+	if m.count == 0 || value < m.min {
+		m.min = value
+	}
+	if m.count == 0 || value > m.max {
+		m.max = value
+	}
+	m.count++
+	m.value = value
+	m.isSet = true
+	evicted := m.update()
+	m.Unlock()
+	evicted.register()
+}
+
+// Evicts the gauge unless it has been set since the last eviction check.
+func (m *Gauge) evict() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.evictIdle()
+}
+
+// Returns facets aggregated since the last flush.
+// Once set, the gauge reports its last value in every interval.
+func (m *Gauge) flush() (Event, func()) {
+	m.Lock()
+	defer m.Unlock()
+	if !m.isSet {
+		return nil, nil
+	}
+	facets := Event{"value": m.value, "count": m.count}
+	if m.count > 0 {
+		facets["min"] = m.min
+		facets["max"] = m.max
+	}
+	count, min, max := m.count, m.min, m.max
+	m.count = 0
+	restore := func() {
+		m.Lock()
+		defer m.Unlock()
+		m.min, m.max = mergeRange(m.count, m.min, m.max, count, min, max)
+		m.count += count
+	}
+	return facets, restore
+}
+
+// Histogram aggregates distribution of observed values, e.g. request durations.
+// Percentiles are estimated from a uniform sample of values of each interval.
+type Histogram struct {
+	count  uint64
+	sum    float64
+	min    float64
+	max    float64
+	sample []float64
+	registration
+	sync.Mutex
+}
+
+// Observe adds the value to the distribution.
+func (m *Histogram) Observe(value float64) {
+	m.Lock()
+	if m.count == 0 || value < m.min {
+		m.min = value
+	}
+	if m.count == 0 || value > m.max {
+		m.max = value
+	}
+	m.count++
+	m.sum += value
+
+	// reservoir sampling
+	if len(m.sample) < histogramReservoirSize {
+		m.sample = append(m.sample, value)
+	} else if i := rand.Int63n(int64(m.count)); i < histogramReservoirSize {
+		m.sample[i] = value
+	}
+	evicted := m.update()
+	m.Unlock()
+	evicted.register()
+}
+
+// Evicts the histogram unless it has been updated since the last eviction check.
+func (m *Histogram) evict() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.evictIdle()
+}
+
+// Returns facets aggregated since the last flush and resets the histogram.
+// Idle histograms report nothing.
+func (m *Histogram) flush() (Event, func()) {
+	m.Lock()
+	defer m.Unlock()
+	if m.count == 0 {
+		return nil, nil
+	}
+	facets := Event{
+		"count": m.count,
+		"sum":   m.sum,
+		"min":   m.min,
+		"max":   m.max,
+		"mean":  m.sum / float64(m.count),
+	}
+	sort.Float64s(m.sample)
+	for _, p := range histogramPercentiles {
+		facets[p.facet] = percentile(m.sample, p.q)
+	}
+	count, sum, min, max, sample := m.count, m.sum, m.min, m.max, m.sample
+	m.count, m.sum, m.min, m.max = 0, 0, 0, 0
+	m.sample = nil
+	restore := func() {
+		m.Lock()
+		defer m.Unlock()
+		m.min, m.max = mergeRange(m.count, m.min, m.max, count, min, max)
+		m.count += count
+		m.sum += sum
+		for _, value := range sample {
+			if len(m.sample) == histogramReservoirSize {
+				break
+			}
+			m.sample = append(m.sample, value)
+		}
+	}
+	return facets, restore
+}
+
+// Merges ranges of values of two aggregates with the given counts.
+func mergeRange(count uint64, min, max float64, otherCount uint64, otherMin, otherMax float64) (float64, float64) {
+	if count == 0 {
+		return otherMin, otherMax
+	}
+	if otherCount == 0 {
+		return min, max
+	}
+	return math.Min(min, otherMin), math.Max(max, otherMax)
+}
+
+// Returns the q-th quantile of sorted values using the nearest rank.
+func percentile(sorted []float64, q float64) float64 {
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// Aggregated metric.
+type metric interface {
+	// Returns facets aggregated since the last flush, nil if there's
+	// nothing to report, and a function restoring them into the metric
+	// if they couldn't be recorded.
+	flush() (Event, func())
+
+	// Marks the metric evicted from the registry if it hasn't been
+	// updated since the last call. Returns true if it has been evicted.
+	evict() bool
+
+	// Sets the registry entry of the metric.
+	setEntry(entry *registeredMetric)
+}
+
+// Summary event of a metric, which can be restored into the metric.
+type metricSummary struct {
+	event    Event
+	injected []string
+	restore  func()
+}
+
+// Registered metric with its name and facets.
+type registeredMetric struct {
+	key        string
+	name       string
+	metricType string
+	facets     Event
+	metric     metric
+	registry   *metricsRegistry
+}
+
+// Registers the evicted metric again, unless the entry is nil.
+func (m *registeredMetric) register() {
+	if m == nil {
+		return
+	}
+	r := m.registry
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.metrics[m.key]; !ok {
+		r.metrics[m.key] = m
+	}
+	r.order = append(r.order, m)
+}
+
+// Registry of metrics aggregated in memory until flushed as events.
+// Metrics which haven't been updated between two flushes are evicted.
+type metricsRegistry struct {
+	metrics  map[string]*registeredMetric
+	order    []*registeredMetric
+	sourceId string
+	now      func() int64
+	sinceTs  int64
+	sync.Mutex
+}
+
+// Creates new metrics registry.
+func newMetricsRegistry(config Config) *metricsRegistry {
+	r := &metricsRegistry{
+		metrics:  make(map[string]*registeredMetric),
+		sourceId: clientSourceId(config),
+		now:      Timestamp,
+	}
+	r.sinceTs = r.now()
+	return r
+}
+
+// Gets the metric of the given type, name and facets,
+// registering a new one created by create if there is none yet.
+func (r *metricsRegistry) get(metricType, name string, facets Event, create func() metric) metric {
+	key := metricKey(metricType, name, facets)
+
+	r.Lock()
+	defer r.Unlock()
+
+	if m, ok := r.metrics[key]; ok {
+		return m.metric
+	}
+	copied := Event{}
+	for k, v := range facets {
+		copied[k] = v
+	}
+	m := &registeredMetric{key: key, name: name, metricType: metricType, facets: copied, metric: create(), registry: r}
+	m.metric.setEntry(m)
+	r.metrics[key] = m
+	r.order = append(r.order, m)
+	return m.metric
+}
+
+// Builds the registry key of a metric from its type, name, and facets
+// sorted by name, with types of their values.
+func metricKey(metricType, name string, facets Event) string {
+	names := make([]string, 0, len(facets))
+	for k := range facets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	fmt.Fprintf(&key, "%q %q", metricType, name)
+	for _, k := range names {
+		fmt.Fprintf(&key, " %q=%T:%#v", k, facets[k], facets[k])
+	}
+	return key.String()
+}
+
+// Returns summary events of metrics updated since the last flush,
+// in order of registration. Metrics idle since the previous flush are evicted.
+func (r *metricsRegistry) flush() []metricSummary {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	var summaries []metricSummary
+	order := r.order[:0]
+	for _, m := range r.order {
+		aggregated, restore := m.metric.flush()
+		if m.metric.evict() {
+			if r.metrics[m.key] == m {
+				delete(r.metrics, m.key)
+			}
+		} else {
+			order = append(order, m)
+		}
+		if aggregated == nil {
+			continue
+		}
+		event := Event{"sourceId": r.sourceId}
+		for k, v := range m.facets {
+			event[k] = v
+		}
+		injected := []string{"sinceTs"}
+		for k, v := range aggregated {
+			event[k] = v
+			injected = append(injected, k)
+		}
+		event["eventName"] = m.name
+		event["timestamp"] = now
+		event["sinceTs"] = r.sinceTs
+		event["metricType"] = m.metricType
+		summaries = append(summaries, metricSummary{event, injected, restore})
+	}
+	for i := len(order); i < len(r.order); i++ {
+		r.order[i] = nil
+	}
+	r.order = order
+	r.sinceTs = now
+	return summaries
+}
+
+// Counter returns the counter of the given name and facets.
+// Counters are reported as `name` events with `count` of updates and their `sum`.
+func (c *Client) Counter(name string, facets Event) *Counter {
+	return c.metrics.get(MetricCounter, name, facets, func() metric { return &Counter{} }).(*Counter)
+}
+
+// Gauge returns the gauge of the given name and facets.
+// Gauges are reported as `name` events with the last `value`,
+// and `min`, `max` and `count` of updates within the interval.
+func (c *Client) Gauge(name string, facets Event) *Gauge {
+	return c.metrics.get(MetricGauge, name, facets, func() metric { return &Gauge{} }).(*Gauge)
+}
+
+// Histogram returns the histogram of the given name and facets.
+// Histograms are reported as `name` events with `count`, `sum`, `min`, `max`,
+// `mean` and `p50`, `p90`, `p95`, `p99` percentiles of observed values.
+func (c *Client) Histogram(name string, facets Event) *Histogram {
+	return c.metrics.get(MetricHistogram, name, facets, func() metric { return &Histogram{} }).(*Histogram)
+}
+
+// FlushMetrics records summary events of metrics aggregated since the last flush.
+// It is called by the publishing activity every publish interval.
+// Aggregates of summaries which couldn't be recorded are kept for the next flush,
+// and the errors are returned joined.
+func (c *Client) FlushMetrics() error {
+	var errs []error
+	for _, summary := range c.metrics.flush() {
+		if _, err := c.record(summary.event, summary.injected...); err != nil {
+			summary.restore()
+			atomic.AddUint64(&c.metricErrors, 1)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,291 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newMetricsTestClient() *Client {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	client, _ := NewClient(config)
+	return client
+}
+
+// Returns facets flushed by the metric.
+func flushed(m metric) Event {
+	facets, _ := m.flush()
+	return facets
+}
+
+func TestCounter_Flush(t *testing.T) {
+	m := &Counter{}
+	if flushed(m) != nil {
+		t.Error("Idle counter should report nothing")
+	}
+
+	m.Inc()
+	m.Add(2.5)
+	if got, want := flushed(m), (Event{"count": uint64(2), "sum": 3.5}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect counter facets. Want: %+v Got: %+v", want, got)
+	}
+	if flushed(m) != nil {
+		t.Error("Counter should be reset after flush")
+	}
+}
+
+func TestGauge_Flush(t *testing.T) {
+	m := &Gauge{}
+	if flushed(m) != nil {
+		t.Error("Unset gauge should report nothing")
+	}
+
+	m.Set(5)
+	m.Set(2)
+	m.Set(3)
+	want := Event{"value": 3.0, "count": uint64(3), "min": 2.0, "max": 5.0}
+	if got := flushed(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect gauge facets. Want: %+v Got: %+v", want, got)
+	}
+
+	want = Event{"value": 3.0, "count": uint64(0)}
+	if got := flushed(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("Gauge should keep reporting its last value. Want: %+v Got: %+v", want, got)
+	}
+}
+
+func TestHistogram_Flush(t *testing.T) {
+	m := &Histogram{}
+	if flushed(m) != nil {
+		t.Error("Idle histogram should report nothing")
+	}
+
+	for i := 1; i <= 100; i++ {
+		m.Observe(float64(i))
+	}
+	want := Event{
+		"count": uint64(100), "sum": 5050.0, "min": 1.0, "max": 100.0, "mean": 50.5,
+		"p50": 50.0, "p90": 90.0, "p95": 95.0, "p99": 99.0,
+	}
+	if got := flushed(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect histogram facets.\nWant: %+v\nGot: %+v", want, got)
+	}
+	if flushed(m) != nil {
+		t.Error("Histogram should be reset after flush")
+	}
+}
+
+func TestHistogram_KeepsBoundedSample(t *testing.T) {
+	m := &Histogram{}
+	for i := 0; i < 10*histogramReservoirSize; i++ {
+		m.Observe(float64(i % 100))
+	}
+
+	if len(m.sample) != histogramReservoirSize {
+		t.Errorf("Sample should be bounded. Got %d", len(m.sample))
+	}
+	facets := flushed(m)
+	if facets["count"] != uint64(10*histogramReservoirSize) || facets["max"] != 99.0 {
+		t.Errorf("Exact count and max should be reported. Got %+v", facets)
+	}
+	if p50 := facets["p50"].(float64); p50 < 40 || p50 > 60 {
+		t.Errorf("Median should be estimated from the sample. Got %v", p50)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sets := []struct {
+		sorted []float64
+		q      float64
+		want   float64
+	}{
+		{[]float64{7}, 0.5, 7},
+		{[]float64{7}, 0.99, 7},
+		{[]float64{1, 2}, 0.5, 1},
+		{[]float64{1, 2, 3, 4}, 0.75, 3},
+		{[]float64{1, 2, 3, 4}, 0.99, 4},
+		{[]float64{1, 2, 3, 4}, 0, 1},
+	}
+
+	for i, set := range sets {
+		if got := percentile(set.sorted, set.q); got != set.want {
+			t.Errorf("Set #%d. Want: %v Got: %v", i, set.want, got)
+		}
+	}
+}
+
+func TestClient_Metrics_SameNameAndFacetsShareMetric(t *testing.T) {
+	client := newMetricsTestClient()
+
+	if client.Counter("requests", Event{"path": "/"}) != client.Counter("requests", Event{"path": "/"}) {
+		t.Error("Same counter should be returned for the same name and facets")
+	}
+	if client.Counter("requests", Event{"path": "/"}) == client.Counter("requests", Event{"path": "/api"}) {
+		t.Error("Different counters should be returned for different facets")
+	}
+	if client.Counter("requests", nil) == client.Counter("requests", Event{"path": "/"}) {
+		t.Error("Different counters should be returned for different facets")
+	}
+}
+
+func TestMetricKey(t *testing.T) {
+	sets := []struct {
+		a, b Event
+	}{
+		{Event{"code": "1"}, Event{"code": 1}},
+		{Event{"code": 1}, Event{"code": int64(1)}},
+		{Event{"a": "x|b=y"}, Event{"a": "x", "b": "y"}},
+		{Event{"a": "x"}, Event{"a": "x", "b": nil}},
+	}
+
+	for i, set := range sets {
+		if metricKey(MetricCounter, "a", set.a) == metricKey(MetricCounter, "a", set.b) {
+			t.Errorf("Set #%d. Keys of %v and %v should differ", i, set.a, set.b)
+		}
+	}
+	if metricKey(MetricCounter, "a", Event{"x": 1, "y": 2}) != metricKey(MetricCounter, "a", Event{"y": 2, "x": 1}) {
+		t.Error("Keys should not depend on the order of facets")
+	}
+}
+
+func TestClient_FlushMetrics_EvictsIdleMetrics(t *testing.T) {
+	client := newMetricsTestClient()
+
+	counter := client.Counter("requests", nil)
+	gauge := client.Gauge("queue.length", nil)
+	counter.Inc()
+	gauge.Set(1)
+	client.FlushMetrics()
+	if len(client.metrics.metrics) != 2 {
+		t.Errorf("Updated metrics should be kept. Got %d", len(client.metrics.metrics))
+	}
+
+	client.FlushMetrics()
+	out := client.queue.Flush()
+	if len(client.metrics.metrics) != 0 || len(client.metrics.order) != 0 {
+		t.Errorf("Idle metrics should be evicted. Got %d", len(client.metrics.metrics))
+	}
+	if len(out) != 3 || out[2]["eventName"] != "queue.length" || out[2]["value"] != 1.0 {
+		t.Errorf("Gauge should report its last value before eviction. Got %+v", out)
+	}
+
+	counter.Inc()
+	if client.Counter("requests", nil) != counter {
+		t.Error("Evicted metric should be registered again once updated")
+	}
+	client.FlushMetrics()
+	if out := client.queue.Flush(); len(out) != 1 || out[0]["count"] != uint64(1) {
+		t.Errorf("Evicted metric should be reported once updated. Got %+v", out)
+	}
+}
+
+func TestClient_FlushMetrics_RecordsSummaryEvents(t *testing.T) {
+	client := newMetricsTestClient()
+	now := int64(1000)
+	client.metrics.now = func() int64 { return now }
+	client.metrics.sinceTs = 0
+
+	facets := Event{"path": "/"}
+	client.Counter("http.requests", facets).Inc()
+	client.Counter("http.requests", facets).Inc()
+	client.Histogram("http.latency", facets).Observe(12)
+	client.Gauge("queue.length", nil)
+	facets["path"] = "/changed"
+
+	if err := client.FlushMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	out := client.queue.Flush()
+	if len(out) != 2 {
+		t.Fatalf("Only updated metrics should be recorded. Got %+v", out)
+	}
+
+	want := Event{
+		"eventName": "http.requests", "sourceId": "dev1", "timestamp": int64(1000), "sinceTs": int64(0),
+		"metricType": MetricCounter, "path": "/", "count": uint64(2), "sum": 2.0,
+	}
+	if !reflect.DeepEqual(out[0], want) {
+		t.Errorf("Incorrect counter event.\nWant: %+v\nGot: %+v", want, out[0])
+	}
+	if out[1]["eventName"] != "http.latency" || out[1]["metricType"] != MetricHistogram || out[1]["p99"] != 12.0 {
+		t.Errorf("Incorrect histogram event. Got %+v", out[1])
+	}
+
+	now = 2000
+	client.Gauge("queue.length", nil).Set(4)
+	client.FlushMetrics()
+	out = client.queue.Flush()
+	if len(out) != 1 || out[0]["value"] != 4.0 || out[0]["sinceTs"] != int64(1000) {
+		t.Errorf("Only metrics updated since the last flush should be recorded. Got %+v", out)
+	}
+}
+
+func TestMetrics_FlushRestoresUnrecordedAggregates(t *testing.T) {
+	counter := &Counter{}
+	counter.Add(2)
+	_, restore := counter.flush()
+	counter.Add(3)
+	restore()
+	if got, want := flushed(counter), (Event{"count": uint64(2), "sum": 5.0}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counter should be restored. Want: %+v Got: %+v", want, got)
+	}
+
+	gauge := &Gauge{}
+	gauge.Set(1)
+	_, restore = gauge.flush()
+	gauge.Set(5)
+	restore()
+	if got, want := flushed(gauge), (Event{"value": 5.0, "count": uint64(2), "min": 1.0, "max": 5.0}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Gauge should be restored. Want: %+v Got: %+v", want, got)
+	}
+
+	histogram := &Histogram{}
+	histogram.Observe(10)
+	_, restore = histogram.flush()
+	histogram.Observe(20)
+	restore()
+	got := flushed(histogram)
+	if got["count"] != uint64(2) || got["min"] != 10.0 || got["max"] != 20.0 || got["p50"] != 10.0 {
+		t.Errorf("Histogram should be restored. Got %+v", got)
+	}
+}
+
+func TestClient_FlushMetrics_RecordsAllSummariesAndKeepsFailedOnes(t *testing.T) {
+	client := newMetricsTestClient()
+
+	client.Counter("bad", Event{"sourceId": 42}).Inc()
+	client.Counter("good", nil).Inc()
+	client.Counter("worse", Event{"sourceId": " "}).Inc()
+
+	err := client.FlushMetrics()
+	if err == nil {
+		t.Fatal("Errors of invalid summaries should be returned")
+	}
+	if out := client.queue.Flush(); len(out) != 1 || out[0]["eventName"] != "good" {
+		t.Errorf("Valid summaries should be recorded. Got %+v", out)
+	}
+	if client.Stats().MetricErrors != 2 {
+		t.Errorf("Failed summaries should be counted. Got %+v", client.Stats())
+	}
+	if got := flushed(client.Counter("bad", Event{"sourceId": 42})); got["count"] != uint64(1) {
+		t.Errorf("Failed summary should be kept for the next flush. Got %+v", got)
+	}
+}
+
+func TestClient_FlushMetrics_FallsBackToClientSourceId(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.StartPublishingThread = false
+	client, _ := NewClient(config)
+
+	client.Counter("jobs.done", nil).Inc()
+	client.Counter("jobs.done", Event{"sourceId": "worker1"}).Inc()
+	client.FlushMetrics()
+
+	out := client.queue.Flush()
+	if len(out) != 2 || out[0]["sourceId"] != ClientSourceId || out[1]["sourceId"] != "worker1" {
+		t.Errorf("Metric events should have sourceId. Got %+v", out)
+	}
+}
@@ -0,0 +1,68 @@
+package moebius
+
+import (
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// MatchGlob simplifies the event matching when names are in a dotted form.
+// See client.MatchGlob for allowed globs.
+func MatchGlob(glob, name string) bool {
+	return client.MatchGlob(glob, name)
+}
+
+// EventNameIs tells whether the eventName of the given event is equal to one of the names.
+func EventNameIs(event client.Event, names ...string) bool {
+	name, _ := event["eventName"].(string)
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EventNameMatches tells whether the eventName of the given event matches one of the globs.
+func EventNameMatches(event client.Event, globs ...string) bool {
+	name, _ := event["eventName"].(string)
+	for _, glob := range globs {
+		if client.MatchGlob(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// WhenEventNameIs applies f only to events which eventName is equal to one of the names.
+// Other events are passed through unchanged.
+func WhenEventNameIs(names []string, f Fn) Fn {
+	return when(func(event client.Event) bool { return EventNameIs(event, names...) }, f)
+}
+
+// WhenEventNameMatches applies f only to events which eventName matches one of the globs.
+// Other events are passed through unchanged.
+func WhenEventNameMatches(globs []string, f Fn) Fn {
+	return when(func(event client.Event) bool { return EventNameMatches(event, globs...) }, f)
+}
+
+// Applies f only to events satisfying pred.
+func when(pred func(client.Event) bool, f Fn) Fn {
+	return func(state interface{}, event client.Event) (interface{}, []client.Event) {
+		if !pred(event) {
+			return state, []client.Event{event}
+		}
+		return f(state, event)
+	}
+}
+
+// InjectIf injects value with the property name to the given event if condition is true.
+func InjectIf(event client.Event, condition bool, property string, value interface{}) client.Event {
+	if condition {
+		event[property] = value
+	}
+	return event
+}
+
+// InjectAs injects value with the property name to the given event if the value isn't nil.
+func InjectAs(event client.Event, property string, value interface{}) client.Event {
+	return InjectIf(event, value != nil, property, value)
+}
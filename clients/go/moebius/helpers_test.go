@@ -0,0 +1,82 @@
+package moebius
+
+import (
+	"reflect"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+func TestEventNameIs(t *testing.T) {
+	event := client.Event{"eventName": "game.started"}
+
+	if !EventNameIs(event, "game.started") {
+		t.Error("Event name should be equal to the given one")
+	}
+	if !EventNameIs(event, "game.level.completed", "game.started") {
+		t.Error("Event name should be equal to one of the given names")
+	}
+	if EventNameIs(event, "game.*") {
+		t.Error("Event name should not be matched as glob")
+	}
+	if EventNameIs(client.Event{}) {
+		t.Error("Event without name should not match")
+	}
+}
+
+func TestEventNameMatches(t *testing.T) {
+	event := client.Event{"eventName": "game.level.2.started"}
+
+	if !EventNameMatches(event, "game.**") {
+		t.Error("Event name should match the glob")
+	}
+	if EventNameMatches(event, "game.*.started", "user.**") {
+		t.Error("Event name should not match the globs")
+	}
+}
+
+func TestWhenEventName(t *testing.T) {
+	mark := Enrich(func(e client.Event) client.Event { return assoc(e, "marked", true) })
+
+	sets := []struct {
+		fn   Fn
+		want []client.Event
+	}{
+		{
+			WhenEventNameIs([]string{"game.started"}, mark),
+			[]client.Event{{"eventName": "game.started", "marked": true}, {"eventName": "game.level.started"}},
+		},
+		{
+			WhenEventNameMatches([]string{"game.**"}, mark),
+			[]client.Event{
+				{"eventName": "game.started", "marked": true},
+				{"eventName": "game.level.started", "marked": true},
+			},
+		},
+	}
+
+	for i, set := range sets {
+		_, got := Moebius(set.fn)(nil, []client.Event{
+			{"eventName": "game.started"},
+			{"eventName": "game.level.started"},
+		})
+		if !reflect.DeepEqual(got, set.want) {
+			t.Errorf("Set #%d.\nWant: %v\nGot: %v", i, set.want, got)
+		}
+	}
+}
+
+func TestInjectIfAndInjectAs(t *testing.T) {
+	if got := InjectIf(client.Event{"a": 1}, true, "b", 2); !reflect.DeepEqual(got, client.Event{"a": 1, "b": 2}) {
+		t.Errorf("Value should be injected. Got %v", got)
+	}
+	if got := InjectIf(client.Event{"a": 1}, false, "b", 2); !reflect.DeepEqual(got, client.Event{"a": 1}) {
+		t.Errorf("Value should not be injected. Got %v", got)
+	}
+	if got := InjectAs(client.Event{"a": 1}, "b", 2); !reflect.DeepEqual(got, client.Event{"a": 1, "b": 2}) {
+		t.Errorf("Value should be injected. Got %v", got)
+	}
+	if got := InjectAs(client.Event{"a": 1}, "b", nil); !reflect.DeepEqual(got, client.Event{"a": 1}) {
+		t.Errorf("Nil value should not be injected. Got %v", got)
+	}
+}
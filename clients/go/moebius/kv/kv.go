@@ -0,0 +1,221 @@
+// Package kv provides a Key/Value store for stateful stream processing
+// and the operations necessary to persist and restore its state.
+// It is a Go port of moebius.kv.
+//
+// Values are stored per sourceId, so that the state is sharded the same
+// way events are. Every change is recorded in a transaction log made of
+// events which can be published to Samsara and replayed to restore the state.
+package kv
+
+import (
+	"sync"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// EventStateUpdated is the eventName of transaction log records.
+const EventStateUpdated = "kvstore.state.updated"
+
+// KV is a key value store protocol.
+type KV interface {
+	// Set sets the given key to the given value. Setting nil deletes the key.
+	Set(sourceId, key string, value interface{})
+
+	// Get returns the current value of the given key, nil if not found.
+	Get(sourceId, key string) interface{}
+
+	// Del deletes the given key.
+	Del(sourceId, key string)
+}
+
+// TxLog is a transaction log protocol attached to a KV store.
+type TxLog interface {
+	// Update replaces all values of the given sourceId with the result of f.
+	// f may be called again if the values change meanwhile.
+	Update(sourceId string, f func(values map[string]interface{}) map[string]interface{})
+
+	// Snapshot returns the current values of the store per sourceId.
+	Snapshot() map[string]map[string]interface{}
+
+	// TxLog returns pending transaction log records and the checkpoint of the last one.
+	TxLog() (checkpoint int64, records []client.Event)
+
+	// Restore restores the state from the given transaction log records.
+	// A record is applied only if its version is newer than the current one.
+	Restore(records []client.Event)
+
+	// CheckpointTxLog flushes out records up to the given checkpoint from the pending transactions.
+	CheckpointTxLog(checkpoint int64)
+}
+
+// Transaction log record with its checkpoint mark.
+type txRecord struct {
+	checkpoint int64
+	event      client.Event
+}
+
+// InMemoryKVStore is an in-memory implementation of KV and TxLog.
+// It is safe for concurrent use.
+type InMemoryKVStore struct {
+	versions   map[string]int64
+	snapshot   map[string]map[string]interface{}
+	txLog      []txRecord
+	checkpoint int64
+	sync.RWMutex
+}
+
+// NewInMemoryKVStore creates a new empty in-memory store.
+func NewInMemoryKVStore() *InMemoryKVStore {
+	return &InMemoryKVStore{
+		versions: make(map[string]int64),
+		snapshot: make(map[string]map[string]interface{}),
+	}
+}
+
+// Set sets the given key to the given value. Setting nil deletes the key.
+func (s *InMemoryKVStore) Set(sourceId, key string, value interface{}) {
+	if value == nil {
+		s.Del(sourceId, key)
+		return
+	}
+	s.Update(sourceId, func(values map[string]interface{}) map[string]interface{} {
+		values[key] = value
+		return values
+	})
+}
+
+// Get returns the current value of the given key, nil if not found.
+func (s *InMemoryKVStore) Get(sourceId, key string) interface{} {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.snapshot[sourceId][key]
+}
+
+// Del deletes the given key.
+func (s *InMemoryKVStore) Del(sourceId, key string) {
+	s.Update(sourceId, func(values map[string]interface{}) map[string]interface{} {
+		delete(values, key)
+		return values
+	})
+}
+
+// Update replaces all values of the given sourceId with the result of f.
+// f receives a copy of the current values which it is free to modify.
+// It is called without holding the lock, so it may use the store, and
+// it is called again if the values of the sourceId change meanwhile.
+func (s *InMemoryKVStore) Update(sourceId string, f func(values map[string]interface{}) map[string]interface{}) {
+	for !s.tryUpdate(sourceId, f) {
+	}
+}
+
+// Computes new values of the sourceId by f and stores them, unless
+// the version of the sourceId has changed meanwhile. Returns false if it has.
+func (s *InMemoryKVStore) tryUpdate(sourceId string, f func(values map[string]interface{}) map[string]interface{}) bool {
+	s.RLock()
+	version := s.versions[sourceId]
+	current := copyValues(s.snapshot[sourceId])
+	s.RUnlock()
+
+	values := f(current)
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.versions[sourceId] != version {
+		return false
+	}
+	s.versions[sourceId]++
+	s.snapshot[sourceId] = values
+	s.checkpoint++
+	s.txLog = append(s.txLog, txRecord{
+		checkpoint: s.checkpoint,
+		event: client.Event{
+			"timestamp": client.Timestamp(),
+			"sourceId":  sourceId,
+			"eventName": EventStateUpdated,
+			"version":   s.versions[sourceId],
+			"value":     values,
+		},
+	})
+	return true
+}
+
+// Snapshot returns a copy of the current values of the store per sourceId.
+func (s *InMemoryKVStore) Snapshot() map[string]map[string]interface{} {
+	s.RLock()
+	defer s.RUnlock()
+
+	result := make(map[string]map[string]interface{}, len(s.snapshot))
+	for sourceId, values := range s.snapshot {
+		result[sourceId] = copyValues(values)
+	}
+	return result
+}
+
+// TxLog returns pending transaction log records and the checkpoint of the last one.
+func (s *InMemoryKVStore) TxLog() (int64, []client.Event) {
+	s.RLock()
+	defer s.RUnlock()
+
+	records := make([]client.Event, len(s.txLog))
+	for i, record := range s.txLog {
+		records[i] = record.event
+	}
+	return s.checkpoint, records
+}
+
+// Restore restores the state from the given transaction log records.
+// A record is applied only if its version is newer than the current one.
+func (s *InMemoryKVStore) Restore(records []client.Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, record := range records {
+		sourceId, _ := record["sourceId"].(string)
+		version, ok := toInt64(record["version"])
+		if !ok || s.versions[sourceId] >= version {
+			continue
+		}
+		values, _ := record["value"].(map[string]interface{})
+		s.versions[sourceId] = version
+		s.snapshot[sourceId] = copyValues(values)
+	}
+}
+
+// CheckpointTxLog flushes out records up to the given checkpoint from the pending transactions.
+func (s *InMemoryKVStore) CheckpointTxLog(checkpoint int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	i := 0
+	for i < len(s.txLog) && s.txLog[i].checkpoint <= checkpoint {
+		i++
+	}
+	s.txLog = append([]txRecord(nil), s.txLog[i:]...)
+}
+
+// Shallow copy of values, so that published snapshots and restored records are never modified.
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+	return result
+}
+
+// Converts numbers which may come out of JSON decoding to int64.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
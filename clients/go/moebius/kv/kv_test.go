@@ -0,0 +1,210 @@
+package kv
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/moebius"
+)
+
+func TestInMemoryKVStore_SetAndGet(t *testing.T) {
+	sets := []interface{}{"v1", 1, true, 3.14, []string{"a"}}
+
+	for i, value := range sets {
+		s := NewInMemoryKVStore()
+		s.Set("s1", "key1", value)
+		if got := s.Get("s1", "key1"); !reflect.DeepEqual(got, value) {
+			t.Errorf("Set #%d. Want %v, Got %v", i, value, got)
+		}
+	}
+}
+
+func TestInMemoryKVStore_SnapshotIsCopied(t *testing.T) {
+	s := NewInMemoryKVStore()
+	s.Set("s1", "key1", "v1")
+
+	s.Snapshot()["s1"]["key1"] = "changed"
+	if s.Get("s1", "key1") != "v1" {
+		t.Errorf("Snapshot should not share values with the store. Got %v", s.Get("s1", "key1"))
+	}
+	if _, records := s.TxLog(); records[0]["value"].(map[string]interface{})["key1"] != "v1" {
+		t.Errorf("Snapshot should not share values with the tx log. Got %v", records)
+	}
+}
+
+func TestInMemoryKVStore_PropertiesAreIndependentAcrossSourceIds(t *testing.T) {
+	s := NewInMemoryKVStore()
+	s.Set("s1", "key1", "v1")
+	s.Set("s2", "key1", "v2")
+
+	if s.Get("s1", "key1") != "v1" || s.Get("s2", "key1") != "v2" {
+		t.Errorf("Values should be independent. Got %v", s.Snapshot())
+	}
+	if s.Get("s3", "key1") != nil || s.Get("s1", "key5") != nil {
+		t.Error("Missing keys should return nil")
+	}
+}
+
+func TestInMemoryKVStore_DeleteIsTheSameAsSettingNil(t *testing.T) {
+	s1 := NewInMemoryKVStore()
+	s1.Set("s1", "key1", "v1")
+	s1.Set("s2", "key1", "vB")
+	s1.Set("s1", "key1", "v2")
+	s1.Set("s1", "key1", nil)
+	s1.Set("s2", "key2", "vC")
+
+	s2 := NewInMemoryKVStore()
+	s2.Set("s1", "key1", "v1")
+	s2.Set("s2", "key1", "vB")
+	s2.Set("s1", "key1", "v2")
+	s2.Del("s1", "key1")
+	s2.Del("s1", "key1")
+	s2.Del("s3", "key1")
+	s2.Set("s2", "key2", "vC")
+
+	if s1.Get("s1", "key1") != nil {
+		t.Error("Deleted key should return nil")
+	}
+	delete(s2.snapshot, "s3")
+	if !reflect.DeepEqual(s1.Snapshot(), s2.Snapshot()) {
+		t.Errorf("Snapshots should be equal.\n%v\n%v", s1.Snapshot(), s2.Snapshot())
+	}
+}
+
+func TestInMemoryKVStore_UpdatesAreRecordedInTxLog(t *testing.T) {
+	s := NewInMemoryKVStore()
+	s.Set("s1", "key1", "v1")
+	s.Set("s1", "key1", "v2")
+	s.Set("s1", "key1", nil)
+
+	checkpoint, records := s.TxLog()
+	if checkpoint != 3 || len(records) != 3 {
+		t.Fatalf("3 records should be in tx-log. Got %d: %v", checkpoint, records)
+	}
+
+	values := []map[string]interface{}{{"key1": "v1"}, {"key1": "v2"}, {}}
+	for i, record := range records {
+		if record["eventName"] != EventStateUpdated || record["sourceId"] != "s1" ||
+			record["version"] != int64(i+1) || !reflect.DeepEqual(record["value"], values[i]) {
+			t.Errorf("Record #%d is incorrect. Got %v", i, record)
+		}
+		if _, ok := record["timestamp"].(int64); !ok {
+			t.Errorf("Record #%d should have a timestamp. Got %v", i, record)
+		}
+	}
+}
+
+func TestInMemoryKVStore_RestoreFromTxLog(t *testing.T) {
+	s1 := NewInMemoryKVStore()
+	s1.Set("s1", "key1", "v1")
+	s1.Set("s2", "key1", "vB")
+	s1.Set("s1", "key1", "v2")
+	s1.Del("s1", "key1")
+	s1.Set("s2", "key2", "vC")
+
+	_, records := s1.TxLog()
+	s2 := NewInMemoryKVStore()
+	s2.Restore(records)
+
+	if !reflect.DeepEqual(s1.Snapshot(), s2.Snapshot()) {
+		t.Errorf("Snapshots should be equal.\n%v\n%v", s1.Snapshot(), s2.Snapshot())
+	}
+
+	// older versions are never applied
+	s2.Restore(records[:1])
+	if s2.Get("s1", "key1") != nil {
+		t.Errorf("Older version should not be restored. Got %v", s2.Snapshot())
+	}
+}
+
+func TestInMemoryKVStore_RestoreCopiesValues(t *testing.T) {
+	value := map[string]interface{}{"key1": "v1"}
+	s := NewInMemoryKVStore()
+	s.Restore([]client.Event{{"sourceId": "s1", "version": int64(1), "value": value}})
+
+	value["key1"] = "changed"
+	if s.Get("s1", "key1") != "v1" {
+		t.Errorf("Restored values should not be shared with the record. Got %v", s.Get("s1", "key1"))
+	}
+}
+
+func TestInMemoryKVStore_UpdateMayUseTheStore(t *testing.T) {
+	s := NewInMemoryKVStore()
+	s.Set("s1", "limit", 2)
+
+	done := make(chan struct{})
+	go func() {
+		s.Update("s2", func(values map[string]interface{}) map[string]interface{} {
+			values["limit"] = s.Get("s1", "limit")
+			return values
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Update should not hold the lock while calling f")
+	}
+	if s.Get("s2", "limit") != 2 {
+		t.Errorf("Value computed by f should be set. Got %v", s.Snapshot())
+	}
+}
+
+func TestInMemoryKVStore_ConcurrentUpdatesAreNotLost(t *testing.T) {
+	s := NewInMemoryKVStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Update("s1", func(values map[string]interface{}) map[string]interface{} {
+				count, _ := values["count"].(int)
+				values["count"] = count + 1
+				return values
+			})
+		}()
+	}
+	wg.Wait()
+
+	if s.Get("s1", "count") != 50 {
+		t.Errorf("Concurrent updates should not be lost. Got %v", s.Get("s1", "count"))
+	}
+}
+
+func TestInMemoryKVStore_CheckpointTxLog(t *testing.T) {
+	s := NewInMemoryKVStore()
+	s.Set("s1", "key1", "v1")
+	s.Set("s1", "key1", "v2")
+	checkpoint, _ := s.TxLog()
+	s.Set("s1", "key1", "v3")
+
+	s.CheckpointTxLog(checkpoint)
+
+	last, records := s.TxLog()
+	if last != 3 || len(records) != 1 || records[0]["version"] != int64(3) {
+		t.Errorf("Only records after checkpoint should be left. Got %d: %v", last, records)
+	}
+}
+
+func TestInMemoryKVStore_AsMoebiusState(t *testing.T) {
+	counter := moebius.StatefulEnrich(func(state interface{}, e client.Event) (interface{}, client.Event) {
+		store := state.(KV)
+		sid := e["sourceId"].(string)
+		count, _ := store.Get(sid, "count").(int)
+		store.Set(sid, "count", count+1)
+		e["count"] = count + 1
+		return store, e
+	})
+
+	store := NewInMemoryKVStore()
+	_, processed := moebius.Moebius(counter)(store, []client.Event{
+		{"sourceId": "a"}, {"sourceId": "b"}, {"sourceId": "a"},
+	})
+
+	if processed[2]["count"] != 2 || store.Get("a", "count") != 2 || store.Get("b", "count") != 1 {
+		t.Errorf("State should be kept per sourceId. Got %v, %v", processed, store.Snapshot())
+	}
+}
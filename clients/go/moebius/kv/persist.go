@@ -0,0 +1,139 @@
+package kv
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// On-disk format of a store snapshot.
+type snapshotData struct {
+	Checkpoint int64                             `json:"checkpoint"`
+	Versions   map[string]int64                  `json:"versions"`
+	Snapshot   map[string]map[string]interface{} `json:"snapshot"`
+}
+
+// WriteSnapshot writes the current state of the store as JSON to w.
+// It returns the checkpoint of the last transaction included in the snapshot,
+// so that the tx-log can be flushed up to it with CheckpointTxLog.
+// Note that numeric values are restored as float64.
+func (s *InMemoryKVStore) WriteSnapshot(w io.Writer) (int64, error) {
+	s.RLock()
+	data := snapshotData{
+		Checkpoint: s.checkpoint,
+		Versions:   s.versions,
+		Snapshot:   s.snapshot,
+	}
+	err := json.NewEncoder(w).Encode(data)
+	s.RUnlock()
+
+	return data.Checkpoint, err
+}
+
+// ReadSnapshot replaces the state of the store with the snapshot read from r.
+// Pending transaction log records are discarded.
+func (s *InMemoryKVStore) ReadSnapshot(r io.Reader) error {
+	var data snapshotData
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+	if data.Versions == nil {
+		data.Versions = make(map[string]int64)
+	}
+	if data.Snapshot == nil {
+		data.Snapshot = make(map[string]map[string]interface{})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.versions = data.Versions
+	s.snapshot = data.Snapshot
+	s.checkpoint = data.Checkpoint
+	s.txLog = nil
+	return nil
+}
+
+// SaveSnapshot atomically writes the snapshot of the store to the file at path.
+// It returns the checkpoint of the last transaction included in the snapshot.
+func (s *InMemoryKVStore) SaveSnapshot(path string) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	checkpoint, err := s.WriteSnapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return checkpoint, os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot creates a new store out of the snapshot file at path.
+func LoadSnapshot(path string) (*InMemoryKVStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := NewInMemoryKVStore()
+	if err := s.ReadSnapshot(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WriteTxLog writes transaction log records to w as newline delimited JSON.
+func WriteTxLog(w io.Writer, records []client.Event) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTxLog reads newline delimited JSON transaction log records from r.
+func ReadTxLog(r io.Reader) ([]client.Event, error) {
+	var records []client.Event
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record client.Event
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Replay restores the state of the store from transaction log records read from r.
+// Records which are not EventStateUpdated events are ignored, so that
+// a stream of mixed events can be replayed as well.
+func Replay(store TxLog, r io.Reader) error {
+	records, err := ReadTxLog(r)
+	txRecords := records[:0]
+	for _, record := range records {
+		if record["eventName"] == EventStateUpdated {
+			txRecords = append(txRecords, record)
+		}
+	}
+	store.Restore(txRecords)
+	return err
+}
@@ -0,0 +1,79 @@
+package kv
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInMemoryKVStore_SaveAndLoadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s1 := NewInMemoryKVStore()
+	s1.Set("s1", "key1", "v1")
+	s1.Set("s1", "key2", true)
+	s1.Set("s2", "key1", "vB")
+
+	checkpoint, err := s1.SaveSnapshot(path)
+	if err != nil || checkpoint != 3 {
+		t.Fatalf("Snapshot should be saved at checkpoint 3. Got %d, %v", checkpoint, err)
+	}
+
+	s2, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("Snapshot should be loaded. Got %v", err)
+	}
+	if !reflect.DeepEqual(s1.Snapshot(), s2.Snapshot()) {
+		t.Errorf("Snapshots should be equal.\n%v\n%v", s1.Snapshot(), s2.Snapshot())
+	}
+
+	// versions and checkpoints continue after restart
+	s2.Set("s1", "key1", "v2")
+	last, records := s2.TxLog()
+	if last != 4 || len(records) != 1 || records[0]["version"] != int64(3) {
+		t.Errorf("Versions should continue after restore. Got %d: %v", last, records)
+	}
+}
+
+func TestLoadSnapshot_MissingFile(t *testing.T) {
+	if _, err := LoadSnapshot(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Error should be returned for missing snapshot")
+	}
+}
+
+func TestReplay_RestoresStateFromWrittenTxLog(t *testing.T) {
+	s1 := NewInMemoryKVStore()
+	s1.Set("s1", "key1", "v1")
+	s1.Set("s2", "key1", "vB")
+	s1.Set("s1", "key1", "v2")
+	_, records := s1.TxLog()
+
+	var buf bytes.Buffer
+	if err := WriteTxLog(&buf, records); err != nil {
+		t.Fatalf("Tx-log should be written. Got %v", err)
+	}
+	buf.WriteString(`{"eventName": "user.logged", "sourceId": "s1", "version": 100}` + "\n")
+
+	s2 := NewInMemoryKVStore()
+	if err := Replay(s2, &buf); err != nil {
+		t.Fatalf("Tx-log should be replayed. Got %v", err)
+	}
+	if !reflect.DeepEqual(s1.Snapshot(), s2.Snapshot()) {
+		t.Errorf("Snapshots should be equal.\n%v\n%v", s1.Snapshot(), s2.Snapshot())
+	}
+}
+
+func TestReplay_MalformedTxLog(t *testing.T) {
+	input := `{"eventName": "kvstore.state.updated", "sourceId": "s1", "version": 1, "value": {"k": "v"}}
+{"eventName": broken`
+
+	s := NewInMemoryKVStore()
+	if err := Replay(s, strings.NewReader(input)); err == nil {
+		t.Error("Error should be returned for malformed tx-log")
+	}
+	if s.Get("s1", "k") != "v" {
+		t.Errorf("Records read before the error should be restored. Got %v", s.Snapshot())
+	}
+}
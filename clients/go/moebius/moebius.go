@@ -0,0 +1,132 @@
+// Package moebius is a Go port of the moebius streaming processing
+// functions (enrichment, correlation and filtering) working with Samsara
+// client events. Functions are composed into pipelines which are applied
+// to a state and a list of events, cycling newly generated events
+// through the same pipeline.
+package moebius
+
+import (
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Fn is a normalized streaming function.
+// It processes a single event with the given state and returns the new state
+// and a list of events: the first one is the processed event (nil when the event
+// has been filtered out), additional ones are correlated events which will be
+// put back in the cycle to follow the same process.
+// If you wish to expand the event (produce 2 or more events and discard
+// the original one) return a list of events in which the first element is nil.
+type Fn func(state interface{}, event client.Event) (interface{}, []client.Event)
+
+// Enrich turns a function enriching a single event into a Fn.
+// If f returns nil the event is left unchanged.
+func Enrich(f func(event client.Event) client.Event) Fn {
+	return StatefulEnrich(func(state interface{}, event client.Event) (interface{}, client.Event) {
+		return state, f(event)
+	})
+}
+
+// StatefulEnrich turns a function enriching a single event with the given state into a Fn.
+// If f returns nil event, both the event and the state are left unchanged.
+func StatefulEnrich(f func(state interface{}, event client.Event) (interface{}, client.Event)) Fn {
+	return func(state interface{}, event client.Event) (interface{}, []client.Event) {
+		newState, enriched := f(state, event)
+		if enriched == nil {
+			return state, []client.Event{event}
+		}
+		return newState, []client.Event{enriched}
+	}
+}
+
+// Correlate turns a function producing new events out of a given one into a Fn.
+// The given event must not be changed, it is always kept.
+// Returning no events means that nothing has been correlated.
+func Correlate(f func(event client.Event) []client.Event) Fn {
+	return StatefulCorrelate(func(state interface{}, event client.Event) (interface{}, []client.Event) {
+		return state, f(event)
+	})
+}
+
+// StatefulCorrelate turns a function producing new events out of a given one
+// and the state into a Fn. The new state is propagated even if no events are generated.
+func StatefulCorrelate(f func(state interface{}, event client.Event) (interface{}, []client.Event)) Fn {
+	return func(state interface{}, event client.Event) (interface{}, []client.Event) {
+		newState, correlated := f(state, event)
+		return newState, append([]client.Event{event}, correlated...)
+	}
+}
+
+// Filter turns a predicate into a Fn which keeps only events the predicate is true for.
+func Filter(pred func(event client.Event) bool) Fn {
+	return StatefulFilter(func(state interface{}, event client.Event) bool {
+		return pred(event)
+	})
+}
+
+// StatefulFilter turns a predicate over the state and event into a Fn
+// which keeps only events the predicate is true for. The state is never changed.
+func StatefulFilter(pred func(state interface{}, event client.Event) bool) Fn {
+	return func(state interface{}, event client.Event) (interface{}, []client.Event) {
+		if pred(state, event) {
+			return state, []client.Event{event}
+		}
+		return state, []client.Event{nil}
+	}
+}
+
+// Pipeline composes streaming functions into a single one
+// which applies them in the given order (from left to right).
+// Events generated along the way are returned after the processed event,
+// the most recently generated first.
+// Pipelines are functions themselves, so they can be composed as well.
+func Pipeline(fs ...Fn) Fn {
+	return func(state interface{}, event client.Event) (interface{}, []client.Event) {
+		events := []client.Event{event}
+		for _, f := range fs {
+			if events[0] == nil {
+				break
+			}
+			newState, result := f(state, events[0])
+			state = newState
+			if len(result) == 0 {
+				result = []client.Event{nil}
+			}
+			events = append(append(make([]client.Event, 0, len(result)+len(events)-1), result...), events[1:]...)
+		}
+		return state, events
+	}
+}
+
+// Moebius composes given streaming functions into a pipeline and returns
+// a function which applies it to a state and a list of events.
+// Every event generated by the pipeline is cycled through the same pipeline
+// before any following event, and only processed events are returned.
+func Moebius(fs ...Fn) func(state interface{}, events []client.Event) (interface{}, []client.Event) {
+	p := Pipeline(fs...)
+	return func(state interface{}, events []client.Event) (interface{}, []client.Event) {
+		// to-process events are kept in reversed order, so that head is at the end
+		stack := make([]client.Event, 0, len(events))
+		for i := len(events) - 1; i >= 0; i-- {
+			stack = append(stack, events[i])
+		}
+
+		processed := make([]client.Event, 0, len(events))
+		for len(stack) > 0 {
+			event := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			var result []client.Event
+			state, result = p(state, event)
+			if len(result) == 0 {
+				continue
+			}
+			if result[0] != nil {
+				processed = append(processed, result[0])
+			}
+			for i := len(result) - 1; i > 0; i-- {
+				stack = append(stack, result[i])
+			}
+		}
+		return state, processed
+	}
+}
@@ -0,0 +1,229 @@
+package moebius
+
+import (
+	"reflect"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Returns a copy of event with the given property set.
+func assoc(e client.Event, k string, v interface{}) client.Event {
+	result := client.Event{k: v}
+	for key, value := range e {
+		if key != k {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+var (
+	ba2 = Enrich(func(e client.Event) client.Event { return assoc(e, "b", e["a"].(int)*2) })
+	wr1 = Enrich(func(e client.Event) client.Event { return assoc(e, "w", 1) })
+	wr2 = Enrich(func(e client.Event) client.Event { return assoc(e, "w", 2) })
+
+	nob4 = Filter(func(e client.Event) bool { return e["b"] != 4 })
+
+	cor = Correlate(func(e client.Event) []client.Event {
+		if e["a"] == 2 {
+			return []client.Event{assoc(e, "a", 3), assoc(e, "a", 4)}
+		}
+		return nil
+	})
+	cor2 = Correlate(func(e client.Event) []client.Event {
+		if e["a"] == 3 {
+			return []client.Event{{"a": 5}, {"a": 6}}
+		}
+		return nil
+	})
+)
+
+func TestEnrich_NilResultKeepsEvent(t *testing.T) {
+	f := Enrich(func(e client.Event) client.Event { return nil })
+
+	state, events := f(1, client.Event{"a": 1})
+	if state != 1 || !reflect.DeepEqual(events, []client.Event{{"a": 1}}) {
+		t.Errorf("Event should be left unchanged. Got %v %v", state, events)
+	}
+}
+
+func TestStatefulEnrich(t *testing.T) {
+	f := StatefulEnrich(func(state interface{}, e client.Event) (interface{}, client.Event) {
+		if e["a"] == 1 {
+			return state.(int) + 1, assoc(e, "b", state)
+		}
+		return state.(int) + 100, nil
+	})
+
+	state, events := f(1, client.Event{"a": 1})
+	if state != 2 || !reflect.DeepEqual(events, []client.Event{{"a": 1, "b": 1}}) {
+		t.Errorf("Event should be enriched and state updated. Got %v %v", state, events)
+	}
+
+	state, events = f(1, client.Event{"a": 2})
+	if state != 1 || !reflect.DeepEqual(events, []client.Event{{"a": 2}}) {
+		t.Errorf("State and event should be unchanged when nothing is enriched. Got %v %v", state, events)
+	}
+}
+
+func TestStatefulCorrelate_PropagatesStateWithoutEvents(t *testing.T) {
+	f := StatefulCorrelate(func(state interface{}, e client.Event) (interface{}, []client.Event) {
+		return state.(int) + 1, nil
+	})
+
+	state, events := f(1, client.Event{"a": 1})
+	if state != 2 || !reflect.DeepEqual(events, []client.Event{{"a": 1}}) {
+		t.Errorf("State should be propagated and event kept. Got %v %v", state, events)
+	}
+}
+
+func TestStatefulFilter(t *testing.T) {
+	f := StatefulFilter(func(state interface{}, e client.Event) bool {
+		return e["a"] == state
+	})
+
+	if _, events := f(1, client.Event{"a": 1}); !reflect.DeepEqual(events, []client.Event{{"a": 1}}) {
+		t.Errorf("Event should be kept. Got %v", events)
+	}
+	if _, events := f(2, client.Event{"a": 1}); !reflect.DeepEqual(events, []client.Event{nil}) {
+		t.Errorf("Event should be filtered out. Got %v", events)
+	}
+}
+
+func TestMoebius(t *testing.T) {
+	sets := []struct {
+		fs     []Fn
+		events []client.Event
+		want   []client.Event
+	}{
+		{
+			[]Fn{Enrich(func(e client.Event) client.Event { return e })},
+			[]client.Event{{"a": 1}},
+			[]client.Event{{"a": 1}},
+		},
+		{
+			[]Fn{cor},
+			[]client.Event{{"a": 1}, {"a": 2}, {"a": 5}},
+			[]client.Event{{"a": 1}, {"a": 2}, {"a": 3}, {"a": 4}, {"a": 5}},
+		},
+		{
+			[]Fn{Filter(func(e client.Event) bool { return e["a"].(int)%2 == 0 })},
+			[]client.Event{{"a": 1}, {"a": 2}, {"a": 3}, {"a": 4}, {"a": 5}},
+			[]client.Event{{"a": 2}, {"a": 4}},
+		},
+		{
+			[]Fn{wr1, ba2, cor, cor2, wr2, nob4},
+			[]client.Event{{"a": 1}, {"a": 2}, {"a": 7}},
+			[]client.Event{
+				{"a": 1, "b": 2, "w": 2},
+				{"a": 3, "b": 6, "w": 2},
+				{"a": 5, "b": 10, "w": 2},
+				{"a": 6, "b": 12, "w": 2},
+				{"a": 4, "b": 8, "w": 2},
+				{"a": 7, "b": 14, "w": 2},
+			},
+		},
+		{
+			[]Fn{wr1, wr2},
+			[]client.Event{{"a": 1}, {"b": 4}, {"a": 2}},
+			[]client.Event{{"a": 1, "w": 2}, {"b": 4, "w": 2}, {"a": 2, "w": 2}},
+		},
+		{
+			[]Fn{wr2, wr1},
+			[]client.Event{{"a": 1}, {"b": 4}, {"a": 2}},
+			[]client.Event{{"a": 1, "w": 1}, {"b": 4, "w": 1}, {"a": 2, "w": 1}},
+		},
+		{
+			[]Fn{nob4, wr1},
+			[]client.Event{{"b": 4}},
+			[]client.Event{},
+		},
+	}
+
+	for i, set := range sets {
+		state, got := Moebius(set.fs...)(1, set.events)
+		if state != 1 {
+			t.Errorf("Set #%d. State should be unchanged. Got %v", i, state)
+		}
+		if !reflect.DeepEqual(got, set.want) {
+			t.Errorf("Set #%d. Incorrectly processed events.\nWant: %v\nGot: %v", i, set.want, got)
+		}
+	}
+}
+
+func TestPipeline_Composition(t *testing.T) {
+	e1 := Enrich(func(e client.Event) client.Event { return assoc(e, "e1", true) })
+	e2 := Enrich(func(e client.Event) client.Event { return assoc(e, "e2", true) })
+	c1 := Correlate(func(e client.Event) []client.Event {
+		if e["a"] != nil {
+			return []client.Event{{"c1": true}}
+		}
+		return nil
+	})
+	c2 := Correlate(func(e client.Event) []client.Event {
+		if e["a"] != nil {
+			return []client.Event{{"c2": true}}
+		}
+		return nil
+	})
+	f1 := Filter(func(e client.Event) bool { return e["a"] != 1 })
+
+	sets := []struct {
+		fn   Fn
+		want []client.Event
+	}{
+		{
+			Pipeline(Pipeline(e1), Pipeline(e2)),
+			[]client.Event{{"a": 1, "e1": true, "e2": true}},
+		},
+		{
+			Pipeline(Pipeline(e1, c1), Pipeline(e2, c2)),
+			[]client.Event{
+				{"a": 1, "e1": true, "e2": true},
+				{"e1": true, "e2": true, "c2": true},
+				{"e1": true, "e2": true, "c1": true},
+			},
+		},
+		{
+			Pipeline(Pipeline(e1, c1), Pipeline(e2, c2, f1)),
+			[]client.Event{
+				{"e1": true, "e2": true, "c2": true},
+				{"e1": true, "e2": true, "c1": true},
+			},
+		},
+		{
+			Pipeline(e1, Pipeline(e2, Pipeline(c1, Pipeline(c2, Pipeline(f1))))),
+			[]client.Event{
+				{"e1": true, "e2": true, "c2": true},
+				{"e1": true, "e2": true, "c1": true},
+			},
+		},
+	}
+
+	for i, set := range sets {
+		_, got := Moebius(set.fn)(nil, []client.Event{{"a": 1}})
+		if !reflect.DeepEqual(got, set.want) {
+			t.Errorf("Set #%d. Incorrectly processed events.\nWant: %v\nGot: %v", i, set.want, got)
+		}
+	}
+}
+
+func TestMoebius_ThreadsStateThroughAllEvents(t *testing.T) {
+	counter := StatefulEnrich(func(state interface{}, e client.Event) (interface{}, client.Event) {
+		n := state.(int) + 1
+		return n, assoc(e, "n", n)
+	})
+	expand := Correlate(func(e client.Event) []client.Event {
+		if e["a"] == 1 {
+			return []client.Event{{"a": 2}}
+		}
+		return nil
+	})
+
+	state, got := Moebius(counter, expand)(0, []client.Event{{"a": 1}, {"a": 3}})
+	want := []client.Event{{"a": 1, "n": 1}, {"a": 2, "n": 2}, {"a": 3, "n": 3}}
+	if state != 3 || !reflect.DeepEqual(got, want) {
+		t.Errorf("State should be threaded through all events. Got %v %v", state, got)
+	}
+}
@@ -0,0 +1,59 @@
+package moebius
+
+import (
+	"sync"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Recorder records events, typically it is a *client.Client.
+type Recorder interface {
+	RecordEvent(event client.Event) error
+}
+
+// Stream applies a pipeline to events keeping the state between calls.
+// It is safe for concurrent use.
+type Stream struct {
+	process func(state interface{}, events []client.Event) (interface{}, []client.Event)
+	state   interface{}
+	sync.Mutex
+}
+
+// NewStream creates a Stream with the given initial state applying given streaming functions.
+func NewStream(state interface{}, fs ...Fn) *Stream {
+	return &Stream{
+		process: Moebius(fs...),
+		state:   state,
+	}
+}
+
+// Process applies the pipeline to the given events and returns processed ones.
+func (s *Stream) Process(events ...client.Event) []client.Event {
+	s.Lock()
+	defer s.Unlock()
+
+	var processed []client.Event
+	s.state, processed = s.process(s.state, events)
+	return processed
+}
+
+// State returns the current state of the stream.
+func (s *Stream) State() interface{} {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.state
+}
+
+// RecordTo processes the given events and records the processed ones with r.
+// Processed events are recorded even if some of them fail validation,
+// the first error is returned.
+func (s *Stream) RecordTo(r Recorder, events ...client.Event) error {
+	var result error
+	for _, event := range s.Process(events...) {
+		if err := r.RecordEvent(event); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
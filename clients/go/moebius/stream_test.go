@@ -0,0 +1,64 @@
+package moebius
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+type recorderMock struct {
+	recorded []client.Event
+}
+
+func (r *recorderMock) RecordEvent(event client.Event) error {
+	if event["eventName"] == nil {
+		return errors.New("invalid event")
+	}
+	r.recorded = append(r.recorded, event)
+	return nil
+}
+
+func TestStream_KeepsStateBetweenCalls(t *testing.T) {
+	count := StatefulEnrich(func(state interface{}, e client.Event) (interface{}, client.Event) {
+		n := state.(int) + 1
+		return n, assoc(e, "count", n)
+	})
+	s := NewStream(0, count)
+
+	s.Process(client.Event{"a": 1}, client.Event{"a": 2})
+	got := s.Process(client.Event{"a": 3})
+
+	if !reflect.DeepEqual(got, []client.Event{{"a": 3, "count": 3}}) {
+		t.Errorf("State should be kept between calls. Got %v", got)
+	}
+	if s.State() != 3 {
+		t.Errorf("Stream state should be 3. Got %v", s.State())
+	}
+}
+
+func TestStream_RecordTo(t *testing.T) {
+	s := NewStream(nil,
+		Filter(func(e client.Event) bool { return e["eventName"] != "cache.hit" }),
+		Correlate(func(e client.Event) []client.Event {
+			if e["eventName"] == "user.login.failed" {
+				return []client.Event{{"alert": true}}
+			}
+			return nil
+		}))
+	r := &recorderMock{}
+
+	err := s.RecordTo(r,
+		client.Event{"eventName": "cache.hit"},
+		client.Event{"eventName": "user.login.failed"},
+		client.Event{"eventName": "user.logged"})
+
+	if err == nil {
+		t.Error("Error of invalid processed event should be returned")
+	}
+	want := []client.Event{{"eventName": "user.login.failed"}, {"eventName": "user.logged"}}
+	if !reflect.DeepEqual(r.recorded, want) {
+		t.Errorf("Valid processed events should be recorded.\nWant: %v\nGot: %v", want, r.recorded)
+	}
+}
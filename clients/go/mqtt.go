@@ -0,0 +1,363 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPingreq    = 12
+	mqttPingresp   = 13
+	mqttDisconnect = 14
+)
+
+// Default port of the Ingestion API MQTT input.
+const mqttDefaultPort = "10010"
+
+// Errors of the MQTT session.
+var (
+	errMqttMalformed    = errors.New("mqtt: malformed packet")
+	errMqttRefused      = errors.New("mqtt: connection refused by server")
+	errMqttNotConnected = errors.New("mqtt: connection lost")
+)
+
+// MqttPublisher publishes events to Ingestion API MQTT input,
+// as JSON arrays to the configured topic.
+// It connects lazily and reconnects on the next Post after the connection is lost.
+type MqttPublisher struct {
+	config Config
+	conn   *mqttConn
+	sync.Mutex
+}
+
+// NewMqttPublisher creates a new MQTT publisher. Url of the config is
+// the address of the broker, e.g. "tcp://samsara-ingestion.local:10010".
+func NewMqttPublisher(config Config) *MqttPublisher {
+	return &MqttPublisher{config: config}
+}
+
+// Post publishes events in a single message.
+// With QoS 1 it waits until the message is acknowledged by the broker.
+func (p *MqttPublisher) Post(data []Event) bool {
+	var payload bytes.Buffer
+	if err := EncodeEvents(&payload, FormatJSONArray, data); err != nil {
+		return false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conn == nil || p.conn.isClosed() {
+		conn, err := dialMqtt(p.config)
+		if err != nil {
+			return false
+		}
+		p.conn = conn
+	}
+
+	if err := p.conn.publish(p.config.MqttTopic, p.config.MqttQoS, payload.Bytes()); err != nil {
+		p.conn.close()
+		return false
+	}
+	return true
+}
+
+// Close disconnects from the broker.
+func (p *MqttPublisher) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	p.conn.writePacket(mqttDisconnect << 4)
+	err := p.conn.close()
+	p.conn = nil
+	return err
+}
+
+// MQTT session over a single network connection.
+type mqttConn struct {
+	conn      net.Conn
+	timeout   time.Duration
+	keepAlive time.Duration
+	nextId    uint16
+	acks      map[uint16]chan struct{}
+	pong      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	writeLock sync.Mutex
+	sync.Mutex
+}
+
+// Connects to the broker and starts the session.
+func dialMqtt(config Config) (*mqttConn, error) {
+	addr, err := mqttAddress(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(config.SendTimeout) * time.Millisecond
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mqttConn{
+		conn:      conn,
+		timeout:   timeout,
+		keepAlive: time.Duration(config.MqttKeepAlive) * time.Second,
+		acks:      make(map[uint16]chan struct{}),
+		pong:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	r := bufio.NewReader(conn)
+	if err := c.connect(r, config); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go c.reading(r)
+	if c.keepAlive > 0 {
+		go c.pinging()
+	}
+	return c, nil
+}
+
+// Returns host:port of the broker from its url.
+func mqttAddress(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), mqttDefaultPort), nil
+	}
+	return u.Host, nil
+}
+
+// Sends CONNECT packet and waits for CONNACK.
+func (c *mqttConn) connect(r *bufio.Reader, config Config) error {
+	var body bytes.Buffer
+	writeMqttString(&body, "MQTT")
+	body.WriteByte(4)    // protocol level 3.1.1
+	body.WriteByte(0x02) // clean session
+	binary.Write(&body, binary.BigEndian, config.MqttKeepAlive)
+	clientId := config.MqttClientId
+	if clientId == "" {
+		clientId = "samsara-" + newId()[:12]
+	}
+	writeMqttString(&body, clientId)
+
+	if err := c.writePacket(mqttConnect<<4, body.Bytes()); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	header, ack, err := readMqttPacket(r)
+	c.conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return err
+	}
+	if header>>4 != mqttConnack || len(ack) != 2 {
+		return errMqttMalformed
+	}
+	if ack[1] != 0 {
+		return errMqttRefused
+	}
+	return nil
+}
+
+// Sends PUBLISH packet and waits for PUBACK if QoS is 1.
+func (c *mqttConn) publish(topic string, qos byte, payload []byte) error {
+	var head bytes.Buffer
+	writeMqttString(&head, topic)
+
+	var ack chan struct{}
+	if qos > 0 {
+		ack = make(chan struct{})
+		c.Lock()
+		c.nextId++
+		if c.nextId == 0 {
+			c.nextId = 1
+		}
+		id := c.nextId
+		c.acks[id] = ack
+		c.Unlock()
+		defer func() {
+			c.Lock()
+			delete(c.acks, id)
+			c.Unlock()
+		}()
+		binary.Write(&head, binary.BigEndian, id)
+	}
+
+	if err := c.writePacket(mqttPublish<<4|qos<<1, head.Bytes(), payload); err != nil {
+		return err
+	}
+	if ack == nil {
+		return nil
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-c.done:
+		return errMqttNotConnected
+	case <-time.After(c.timeout):
+		return errMqttNotConnected
+	}
+}
+
+// Reads packets sent by the broker until the connection is closed.
+func (c *mqttConn) reading(r *bufio.Reader) {
+	defer c.close()
+	for {
+		header, body, err := readMqttPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case mqttPuback:
+			if len(body) != 2 {
+				return
+			}
+			id := binary.BigEndian.Uint16(body)
+			c.Lock()
+			if ack, ok := c.acks[id]; ok {
+				close(ack)
+				delete(c.acks, id)
+			}
+			c.Unlock()
+		case mqttPingresp:
+			select {
+			case c.pong <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Keeps the session alive with PINGREQ packets.
+// Closes the connection if the broker doesn't respond in time.
+func (c *mqttConn) pinging() {
+	ticker := time.NewTicker(c.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.writePacket(mqttPingreq << 4); err != nil {
+			c.close()
+			return
+		}
+		select {
+		case <-c.pong:
+		case <-c.done:
+			return
+		case <-time.After(c.timeout):
+			c.close()
+			return
+		}
+	}
+}
+
+// Writes the packet with the given fixed header byte and body parts.
+func (c *mqttConn) writePacket(header byte, body ...[]byte) error {
+	length := 0
+	for _, part := range body {
+		length += len(part)
+	}
+	packet := append(net.Buffers{encodeMqttHeader(header, length)}, body...)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := packet.WriteTo(c.conn)
+	return err
+}
+
+// Tells whether the connection has been closed.
+func (c *mqttConn) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Closes the connection.
+func (c *mqttConn) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// Encodes the fixed header with the remaining length.
+func encodeMqttHeader(header byte, length int) []byte {
+	packet := make([]byte, 0, 5)
+	packet = append(packet, header)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+	return packet
+}
+
+// Reads a packet. Returns its fixed header byte and the rest of the packet.
+func readMqttPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errMqttMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// Writes length-prefixed UTF-8 string.
+func writeMqttString(w *bytes.Buffer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	w.WriteString(s)
+}
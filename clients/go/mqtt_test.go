@@ -0,0 +1,274 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Message published to the test broker.
+type brokerMessage struct {
+	topic  string
+	qos    byte
+	events []Event
+}
+
+// In-process stand-in of an MQTT broker accepting events.
+type testBroker struct {
+	listener   net.Listener
+	messages   chan brokerMessage
+	pings      chan struct{}
+	returnCode byte
+	noAck      bool
+	connects   int
+	conns      []net.Conn
+	sync.Mutex
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{
+		listener: listener,
+		messages: make(chan brokerMessage, 100),
+		pings:    make(chan struct{}, 100),
+	}
+	go b.serve()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.Lock()
+		b.conns = append(b.conns, conn)
+		b.Unlock()
+		go b.handle(conn)
+	}
+}
+
+func (b *testBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readMqttPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case mqttConnect:
+			b.Lock()
+			b.connects++
+			code := b.returnCode
+			b.Unlock()
+			conn.Write(encodeMqttPacketForTest(mqttConnack<<4, []byte{0, code}))
+		case mqttPublish:
+			qos := header >> 1 & 0x03
+			topicLen := int(binary.BigEndian.Uint16(body))
+			msg := brokerMessage{topic: string(body[2 : 2+topicLen]), qos: qos}
+			rest := body[2+topicLen:]
+			if qos > 0 {
+				id := rest[:2]
+				rest = rest[2:]
+				b.Lock()
+				noAck := b.noAck
+				b.Unlock()
+				if !noAck {
+					conn.Write(encodeMqttPacketForTest(mqttPuback<<4, id))
+				}
+			}
+			json.Unmarshal(rest, &msg.events)
+			b.messages <- msg
+		case mqttPingreq:
+			b.pings <- struct{}{}
+			conn.Write(encodeMqttPacketForTest(mqttPingresp<<4, nil))
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func (b *testBroker) dropConnections() {
+	b.Lock()
+	defer b.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *testBroker) close() {
+	b.listener.Close()
+	b.dropConnections()
+}
+
+func encodeMqttPacketForTest(header byte, body []byte) []byte {
+	return append(encodeMqttHeader(header, len(body)), body...)
+}
+
+func newMqttTestConfig(url string) Config {
+	config := NewConfig()
+	config.Url = url
+	config.Transport = "mqtt"
+	config.SendTimeout = 500
+	return config
+}
+
+func TestMqttPacket_RemainingLength(t *testing.T) {
+	for i, length := range []int{0, 1, 127, 128, 16383, 16384, 2097152} {
+		body := bytes.Repeat([]byte{'x'}, length)
+		packet := encodeMqttPacketForTest(mqttPublish<<4, body)
+
+		header, got, err := readMqttPacket(bufio.NewReader(bytes.NewReader(packet)))
+		if err != nil || header != mqttPublish<<4 || !bytes.Equal(got, body) {
+			t.Errorf("Set #%d. Packet of length %d should be decoded. Got %d, %v", i, length, len(got), err)
+		}
+	}
+}
+
+func TestMqttAddress(t *testing.T) {
+	sets := []struct {
+		url  string
+		want string
+	}{
+		{"tcp://samsara.local:1883", "samsara.local:1883"},
+		{"mqtt://samsara.local", "samsara.local:10010"},
+		{"tcp://127.0.0.1:10010/", "127.0.0.1:10010"},
+	}
+
+	for i, set := range sets {
+		if got, err := mqttAddress(set.url); err != nil || got != set.want {
+			t.Errorf("Set #%d. Want: %s Got: %s, %v", i, set.want, got, err)
+		}
+	}
+}
+
+func TestMqttPublisher_Post(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+
+	for _, qos := range []byte{0, 1} {
+		config := newMqttTestConfig(broker.url())
+		config.MqttQoS = qos
+		publisher := NewMqttPublisher(config)
+
+		events := []Event{{"sourceId": "dev1", "eventName": "boot", "timestamp": int64(1)}}
+		if !publisher.Post(events) {
+			t.Fatalf("QoS %d. Events should be published", qos)
+		}
+		msg := <-broker.messages
+		if msg.topic != "samsara/events" || msg.qos != qos || len(msg.events) != 1 || msg.events[0]["eventName"] != "boot" {
+			t.Errorf("QoS %d. Incorrect message. Got %+v", qos, msg)
+		}
+		publisher.Close()
+	}
+}
+
+func TestMqttPublisher_Post_Reconnects(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := NewMqttPublisher(newMqttTestConfig(broker.url()))
+	defer publisher.Close()
+
+	publisher.Post([]Event{{"eventName": "a"}})
+	<-broker.messages
+	broker.dropConnections()
+
+	deadline := time.Now().Add(time.Second)
+	for !publisher.conn.isClosed() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !publisher.Post([]Event{{"eventName": "b"}}) {
+		t.Fatal("Publisher should reconnect")
+	}
+	if msg := <-broker.messages; msg.events[0]["eventName"] != "b" {
+		t.Errorf("Incorrect message. Got %+v", msg)
+	}
+	broker.Lock()
+	defer broker.Unlock()
+	if broker.connects != 2 {
+		t.Errorf("Publisher should connect twice. Got %d", broker.connects)
+	}
+}
+
+func TestMqttPublisher_Post_Failures(t *testing.T) {
+	sets := []struct {
+		returnCode byte
+		noAck      bool
+	}{
+		{returnCode: 5}, // not authorized
+		{noAck: true},
+	}
+
+	for i, set := range sets {
+		broker := newTestBroker(t)
+		broker.Lock()
+		broker.returnCode = set.returnCode
+		broker.noAck = set.noAck
+		broker.Unlock()
+		publisher := NewMqttPublisher(newMqttTestConfig(broker.url()))
+
+		if publisher.Post([]Event{{"eventName": "a"}}) {
+			t.Errorf("Set #%d. Post should fail", i)
+		}
+		publisher.Close()
+		broker.close()
+	}
+
+	publisher := NewMqttPublisher(newMqttTestConfig("tcp://127.0.0.1:1"))
+	if publisher.Post([]Event{{"eventName": "a"}}) {
+		t.Error("Post should fail without broker")
+	}
+}
+
+func TestMqttPublisher_KeepAlive(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	config := newMqttTestConfig(broker.url())
+	config.MqttKeepAlive = 1
+	publisher := NewMqttPublisher(config)
+	defer publisher.Close()
+
+	publisher.Post([]Event{{"eventName": "a"}})
+	select {
+	case <-broker.pings:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publisher should ping the broker")
+	}
+	if publisher.conn.isClosed() {
+		t.Error("Connection should be kept alive")
+	}
+}
+
+func TestClient_PublishEvents_OverMqtt(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	config := newMqttTestConfig(broker.url())
+	config.StartPublishingThread = false
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := client.PublishEvents([]Event{{"eventName": "a", "sourceId": "dev1"}})
+	if !ok || err != nil {
+		t.Fatalf("Events should be published. Got %t, %v", ok, err)
+	}
+	if msg := <-broker.messages; len(msg.events) != 1 || msg.events[0]["sourceId"] != "dev1" {
+		t.Errorf("Incorrect message. Got %+v", msg)
+	}
+}
@@ -1,9 +1,8 @@
 package client
 
 import (
-	"bytes"
-	"compress/gzip"
-	"encoding/json"
+	"bufio"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,6 +15,9 @@ const PUBLISHED_TIMESTAMP_HEADER = "X-Samsara-publishedTimestamp"
 // API_PATH is Samsara Ingestion API endpoint.
 const API_PATH = "/v1/events"
 
+// Size of the buffer between compressor and request body.
+const publishBufferSize = 32 * 1024
+
 // IPublisher interface for publishing data.
 // Used mainly for test purpopses.
 type IPublisher interface {
@@ -25,32 +27,55 @@ type IPublisher interface {
 // Publisher is a physical connector that Publishes messages to Samsara Ingestion API.
 type Publisher struct {
 	config Config
+	clock  *clockSkew
+}
+
+// NewPublisher creates a publisher sending events to the Ingestion API at config.Url.
+func NewPublisher(config Config) *Publisher {
+	return &Publisher{config: config, clock: newClockSkew(config)}
+}
+
+// Creates publisher for the configured transport.
+func newPublisher(config Config, clock *clockSkew) IPublisher {
+	switch config.Transport {
+	case "mqtt":
+		return NewMqttPublisher(config)
+	case "file":
+		return NewFilePublisher(config)
+	case "stdout":
+		return NewStdoutPublisher()
+	}
+	return &Publisher{config: config, clock: clock}
 }
 
 // Post sends message to Ingestion API.
+// Events are streamed through the compressor straight into the request body,
+// adjusted by the estimated clock skew if configured.
 func (p *Publisher) Post(data []Event) bool {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
+	codec, ok := configuredCodec(p.config)
+	if !ok {
 		return false
 	}
-
-	var buffer *bytes.Buffer
-	if p.config.Compression == "gzip" {
-		buffer = gzipWrap(jsonData)
-	} else {
-		buffer = noneWrap(jsonData)
-	}
-
-	req, _ := http.NewRequest("POST", strings.Trim(p.config.Url, "/")+API_PATH, buffer)
-	p.setHeaders(req)
-
 	client := &http.Client{
 		Timeout: time.Duration(p.config.SendTimeout) * time.Millisecond,
 	}
+	p.clock.probe(client, p.config.Url)
+
+	offset, adjust := p.clock.offset()
+	body, w := io.Pipe()
+	defer body.Close()
+	go p.encode(w, codec, data, offset, adjust)
+
+	req, _ := http.NewRequest("POST", strings.Trim(p.config.Url, "/")+API_PATH, body)
+	p.setHeaders(req, codec, p.clock.timestamp(offset))
+
+	sentTs := Timestamp()
 	resp, err := client.Do(req)
 	if err != nil {
 		return false
 	}
+	resp.Body.Close()
+	p.clock.observe(sentTs, Timestamp(), resp)
 
 	if resp.StatusCode == 202 {
 		return true
@@ -58,30 +83,40 @@ func (p *Publisher) Post(data []Event) bool {
 	return false
 }
 
-// Helper method to generate HTTP request headers for Ingestion API.
-func (p *Publisher) setHeaders(req *http.Request) {
-	var compression string
-	if p.config.Compression == "gzip" {
-		compression = "gzip"
-	} else {
-		compression = "identity"
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Encoding", compression)
-	req.Header.Set(PUBLISHED_TIMESTAMP_HEADER, strconv.FormatInt(Timestamp(), 10))
-}
+// Encodes events into the pipe, applying the clock offset if adjusted.
+// An encoding or compression error aborts the request.
+func (p *Publisher) encode(w *io.PipeWriter, codec Codec, data []Event, offset int64, adjust bool) {
+	buffered := bufio.NewWriterSize(w, publishBufferSize)
 
-// Gzip wrapper for data.
-func gzipWrap(data []byte) *bytes.Buffer {
-	var buffer bytes.Buffer
-	gz := gzip.NewWriter(&buffer)
-	defer gz.Close()
-	gz.Write(data)
-	return &buffer
+	err := func() error {
+		compressed, err := codec.NewWriter(buffered)
+		if err != nil {
+			return err
+		}
+		enc := NewEventEncoder(compressed, FormatJSONArray)
+		for _, event := range data {
+			if adjust {
+				event = p.clock.apply(offset, event)
+			}
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		if err := compressed.Close(); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}()
+	w.CloseWithError(err)
 }
 
-// None wrapper for data.
-func noneWrap(data []byte) *bytes.Buffer {
-	return bytes.NewBuffer(data)
+// Helper method to generate HTTP request headers for Ingestion API.
+func (p *Publisher) setHeaders(req *http.Request, codec Codec, publishedTs int64) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", codec.ContentEncoding())
+	req.Header.Set(PUBLISHED_TIMESTAMP_HEADER, strconv.FormatInt(publishedTs, 10))
 }
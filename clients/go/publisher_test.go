@@ -25,7 +25,7 @@ func TestPublisher_ApiPath(t *testing.T) {
 	config := NewConfig()
 	config.Url = mockServer.URL
 
-	publisher := Publisher{config}
+	publisher := Publisher{config: config}
 	publisher.Post([]Event{{"sourceId": "foo", "eventName": "baz", "timestamp": int64(1479988864057)}})
 }
 
@@ -51,7 +51,7 @@ func TestPublisher_Post_HeadersCheck(t *testing.T) {
 	config.Url = mockServer.URL
 	config.Compression = "none"
 
-	publisher := Publisher{config}
+	publisher := Publisher{config: config}
 	publisher.Post([]Event{{"sourceId": "foo", "eventName": "baz", "timestamp": int64(1479988864057)}})
 }
 
@@ -121,13 +121,13 @@ func TestPublisher_Post_DataTransmissionCheck(t *testing.T) {
 		config.Url = mockServer.URL
 		config.Compression = set.compression
 
-		publisher := Publisher{config}
+		publisher := Publisher{config: config}
 		publisher.Post(set.data)
 	}
 }
 
 func TestPublisher_Post_MalformedData(t *testing.T) {
-	publisher := Publisher{NewConfig()}
+	publisher := Publisher{config: NewConfig()}
 	success := publisher.Post([]Event{{"sourceId": "foo", "timestamp": math.NaN()}})
 	if success != false {
 		t.Error("Post method should return false if data marshalling failed")
@@ -155,7 +155,7 @@ func TestPublisher_Post_SendTimeout(t *testing.T) {
 		config.Url = mockServer.URL
 		config.SendTimeout = uint32(set.timeout)
 
-		publisher := Publisher{config}
+		publisher := Publisher{config: config}
 		success := publisher.Post([]Event{{"sourceId": "foo"}})
 		if success != set.want {
 			t.Errorf("Set #%d. Post method should return %t", i, set.want)
@@ -244,7 +244,7 @@ func TestPublisher_Post_ResponseStatus(t *testing.T) {
 		config := NewConfig()
 		config.Url = mockServer.URL
 
-		publisher := Publisher{config}
+		publisher := Publisher{config: config}
 		success := publisher.Post([]Event{{"sourceId": "foo"}})
 		if success != set.want {
 			t.Errorf("Set #%d. Post method should return %t", i, set.want)
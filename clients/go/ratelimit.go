@@ -0,0 +1,251 @@
+package client
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+)
+
+// RateLimitedEvent is the name of the event summarizing events dropped by rate limits.
+const RateLimitedEvent = "samsara.client.ratelimited"
+
+// Numeric facets of the rate limit summary injected by the client.
+var rateLimitFacets = []string{"dropped", "sinceTs"}
+
+// Max number of distinct sourceIds and event names counted in a summary.
+const rateLimitedMaxKeys = 1000
+
+// Number of top sourceIds and event names reported in a summary.
+const rateLimitedTopKeys = 10
+
+// RateLimit is a token bucket limit for events.
+type RateLimit struct {
+	// Glob of event names the limit applies to (see MatchGlob).
+	// Empty value applies the limit to all events.
+	EventName string
+
+	// Should the limit apply to each sourceId separately?
+	// Otherwise it applies to all matching events together.
+	PerSource bool
+
+	// Number of events allowed per second.
+	Rate float64
+
+	// Max number of events allowed in a burst.
+	Burst int
+}
+
+// Token bucket state.
+type bucket struct {
+	tokens   float64
+	updateTs int64
+}
+
+// Token bucket of a single sourceId.
+type sourceBucket struct {
+	sourceId string
+	bucket
+}
+
+// Refills bucket at the given time and tells whether a token is available.
+func (b *bucket) refill(limit RateLimit, now int64) bool {
+	b.tokens += float64(now-b.updateTs) * limit.Rate / 1000
+	if b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+	b.updateTs = now
+	return b.tokens >= 1
+}
+
+// Rate limiter drops events exceeding configured limits
+// and summarizes them periodically.
+// Per-source buckets of each limit are kept in LRU order,
+// the least recently used at the back.
+type rateLimiter struct {
+	limits     []RateLimit
+	global     []*bucket
+	perSource  []map[string]*list.Element
+	lru        []*list.List
+	capacity   int
+	interval   int64
+	sourceId   string
+	now        func() int64
+	lastReport int64
+	total      uint64
+	dropped    uint64
+	bySource   map[string]uint64
+	byName     map[string]uint64
+	sync.Mutex
+}
+
+// Creates new rate limiter. Returns nil if there are no limits.
+func newRateLimiter(config Config) *rateLimiter {
+	if len(config.RateLimits) == 0 {
+		return nil
+	}
+	r := &rateLimiter{
+		limits:    config.RateLimits,
+		global:    make([]*bucket, len(config.RateLimits)),
+		perSource: make([]map[string]*list.Element, len(config.RateLimits)),
+		lru:       make([]*list.List, len(config.RateLimits)),
+		capacity:  config.RateLimitMaxSources,
+		interval:  int64(config.RateLimitReportInterval),
+		sourceId:  clientSourceId(config),
+		now:       Timestamp,
+		bySource:  make(map[string]uint64),
+		byName:    make(map[string]uint64),
+	}
+	r.lastReport = r.now()
+	for i := range r.limits {
+		r.perSource[i] = make(map[string]*list.Element)
+		r.lru[i] = list.New()
+	}
+	return r
+}
+
+// Tells whether event is within all matching limits and takes tokens if so.
+// Summaries of dropped events are always allowed.
+func (r *rateLimiter) allow(e Event) bool {
+	if r == nil {
+		return true
+	}
+	name, _ := e["eventName"].(string)
+	if name == RateLimitedEvent {
+		return true
+	}
+	sid, _ := e["sourceId"].(string)
+
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	var matched []*bucket
+	for i, limit := range r.limits {
+		if limit.EventName != "" && !MatchGlob(limit.EventName, name) {
+			continue
+		}
+		b := r.bucketFor(i, sid, now)
+		if !b.refill(limit, now) {
+			r.drop(sid, name)
+			return false
+		}
+		matched = append(matched, b)
+	}
+	for _, b := range matched {
+		b.tokens--
+	}
+	return true
+}
+
+// Gets bucket of i-th limit for the given sourceId.
+// The least recently used bucket is evicted if there are too many sources.
+func (r *rateLimiter) bucketFor(i int, sid string, now int64) *bucket {
+	if !r.limits[i].PerSource {
+		if r.global[i] == nil {
+			r.global[i] = &bucket{tokens: float64(r.limits[i].Burst), updateTs: now}
+		}
+		return r.global[i]
+	}
+	if elem, ok := r.perSource[i][sid]; ok {
+		r.lru[i].MoveToFront(elem)
+		return &elem.Value.(*sourceBucket).bucket
+	}
+	if r.lru[i].Len() >= r.capacity {
+		oldest := r.lru[i].Remove(r.lru[i].Back()).(*sourceBucket)
+		delete(r.perSource[i], oldest.sourceId)
+	}
+	b := &sourceBucket{sourceId: sid, bucket: bucket{tokens: float64(r.limits[i].Burst), updateTs: now}}
+	r.perSource[i][sid] = r.lru[i].PushFront(b)
+	return &b.bucket
+}
+
+// Counts dropped event.
+func (r *rateLimiter) drop(sid, name string) {
+	r.total++
+	r.dropped++
+	if _, ok := r.bySource[sid]; ok || len(r.bySource) < rateLimitedMaxKeys {
+		r.bySource[sid]++
+	}
+	if _, ok := r.byName[name]; ok || len(r.byName) < rateLimitedMaxKeys {
+		r.byName[name]++
+	}
+}
+
+// Total number of dropped events.
+func (r *rateLimiter) droppedCount() uint64 {
+	if r == nil {
+		return 0
+	}
+	r.Lock()
+	defer r.Unlock()
+	return r.total
+}
+
+// Returns the summary event of events dropped since the last one,
+// if the report interval has elapsed and anything has been dropped.
+// Idle per-source buckets are forgotten at the same time.
+func (r *rateLimiter) report() Event {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	if now-r.lastReport < r.interval {
+		return nil
+	}
+
+	for i, limit := range r.limits {
+		for sid, elem := range r.perSource[i] {
+			b := elem.Value.(*sourceBucket)
+			if b.refill(limit, now); b.tokens >= float64(limit.Burst) {
+				r.lru[i].Remove(elem)
+				delete(r.perSource[i], sid)
+			}
+		}
+	}
+
+	if r.dropped == 0 {
+		r.lastReport = now
+		return nil
+	}
+	summary := Event{
+		"eventName":  RateLimitedEvent,
+		"timestamp":  now,
+		"dropped":    r.dropped,
+		"sinceTs":    r.lastReport,
+		"sources":    topCounts(r.bySource),
+		"eventNames": topCounts(r.byName),
+		"sourceId":   r.sourceId,
+	}
+
+	r.lastReport = now
+	r.dropped = 0
+	r.bySource = make(map[string]uint64)
+	r.byName = make(map[string]uint64)
+	return summary
+}
+
+// Returns the highest counts.
+func topCounts(counts map[string]uint64) map[string]interface{} {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > rateLimitedTopKeys {
+		keys = keys[:rateLimitedTopKeys]
+	}
+
+	result := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		result[k] = counts[k]
+	}
+	return result
+}
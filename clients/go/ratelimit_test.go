@@ -0,0 +1,209 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func newTestRateLimiter(limits []RateLimit, now *int64) *rateLimiter {
+	config := NewConfig()
+	config.SourceId = "agent"
+	config.RateLimits = limits
+	config.RateLimitReportInterval = 1000
+	r := newRateLimiter(config)
+	r.now = func() int64 { return *now }
+	r.lastReport = *now
+	return r
+}
+
+func limitEvent(sid, name string) Event {
+	return Event{"eventName": name, "sourceId": sid, "timestamp": int64(1)}
+}
+
+func TestRateLimiter_NoLimitsAllowsEverything(t *testing.T) {
+	r := newRateLimiter(NewConfig())
+	for i := 0; i < 100; i++ {
+		if !r.allow(limitEvent("dev1", "foo")) {
+			t.Fatal("Events should be allowed without limits")
+		}
+	}
+	if r.report() != nil || r.droppedCount() != 0 {
+		t.Error("Nothing should be reported")
+	}
+}
+
+func TestRateLimiter_GlobalLimit(t *testing.T) {
+	now := int64(0)
+	r := newTestRateLimiter([]RateLimit{{Rate: 10, Burst: 3}}, &now)
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if r.allow(limitEvent(fmt.Sprintf("dev%d", i), "foo")) {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Only burst of events should be allowed. Got %d", allowed)
+	}
+
+	now = 100 // 1 token refilled
+	if !r.allow(limitEvent("dev1", "foo")) {
+		t.Error("Event should be allowed after refill")
+	}
+	if r.allow(limitEvent("dev1", "foo")) {
+		t.Error("Event should be dropped when tokens are exhausted")
+	}
+	if r.droppedCount() != 3 {
+		t.Errorf("3 events should be counted as dropped. Got %d", r.droppedCount())
+	}
+}
+
+func TestRateLimiter_PerSourceAndEventNameLimits(t *testing.T) {
+	now := int64(0)
+	r := newTestRateLimiter([]RateLimit{
+		{PerSource: true, Rate: 1, Burst: 2},
+		{EventName: "ui.mouse.*", Rate: 1, Burst: 1},
+	}, &now)
+
+	sets := []struct {
+		event Event
+		want  bool
+	}{
+		{limitEvent("dev1", "user.logged"), true},
+		{limitEvent("dev1", "user.logged"), true},
+		{limitEvent("dev1", "user.logged"), false},
+		{limitEvent("dev2", "ui.mouse.moved"), true},
+		{limitEvent("dev3", "ui.mouse.moved"), false},
+		{limitEvent("dev3", "user.logged"), true},
+	}
+
+	for i, set := range sets {
+		if got := r.allow(set.event); got != set.want {
+			t.Errorf("Set #%d. Event %+v should be allowed: %t", i, set.event, set.want)
+		}
+	}
+}
+
+func TestRateLimiter_RejectedEventDoesNotTakeTokens(t *testing.T) {
+	now := int64(0)
+	r := newTestRateLimiter([]RateLimit{
+		{Rate: 1, Burst: 2},
+		{EventName: "noisy", Rate: 1, Burst: 1},
+	}, &now)
+
+	r.allow(limitEvent("dev1", "noisy"))
+	r.allow(limitEvent("dev1", "noisy"))
+
+	if !r.allow(limitEvent("dev1", "quiet")) {
+		t.Error("Rejected event should not take tokens of other limits")
+	}
+}
+
+func TestRateLimiter_Report(t *testing.T) {
+	now := int64(0)
+	r := newTestRateLimiter([]RateLimit{{PerSource: true, Rate: 1, Burst: 1}}, &now)
+
+	r.allow(limitEvent("dev1", "loop"))
+	r.allow(limitEvent("dev1", "loop"))
+	r.allow(limitEvent("dev1", "loop"))
+	r.allow(limitEvent("dev2", "other"))
+	r.allow(limitEvent("dev2", "other"))
+
+	if r.report() != nil {
+		t.Error("Summary should not be reported before the interval elapses")
+	}
+
+	now = 1000
+	want := Event{
+		"eventName":  RateLimitedEvent,
+		"sourceId":   "agent",
+		"timestamp":  int64(1000),
+		"sinceTs":    int64(0),
+		"dropped":    uint64(3),
+		"sources":    map[string]interface{}{"dev1": uint64(2), "dev2": uint64(1)},
+		"eventNames": map[string]interface{}{"loop": uint64(2), "other": uint64(1)},
+	}
+	if got := r.report(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect summary.\nWant: %+v\nGot: %+v", want, got)
+	}
+	if len(r.perSource[0]) != 0 {
+		t.Errorf("Idle buckets should be forgotten. Got %+v", r.perSource[0])
+	}
+
+	now = 2000
+	if got := r.report(); got != nil {
+		t.Errorf("Nothing should be reported when nothing was dropped. Got %+v", got)
+	}
+	if r.droppedCount() != 3 {
+		t.Errorf("Total dropped count should be kept. Got %d", r.droppedCount())
+	}
+}
+
+func TestRateLimiter_EvictsLeastRecentlyUsedSources(t *testing.T) {
+	now := int64(0)
+	config := NewConfig()
+	config.RateLimits = []RateLimit{{PerSource: true, Rate: 1, Burst: 1}}
+	config.RateLimitReportInterval = 1000
+	config.RateLimitMaxSources = 2
+	r := newRateLimiter(config)
+	r.now = func() int64 { return now }
+
+	r.allow(limitEvent("dev1", "a"))
+	r.allow(limitEvent("dev2", "a"))
+	r.allow(limitEvent("dev1", "a"))
+	r.allow(limitEvent("dev3", "a"))
+
+	if len(r.perSource[0]) != 2 || r.lru[0].Len() != 2 {
+		t.Fatalf("Buckets should be bounded. Got %d", len(r.perSource[0]))
+	}
+	if _, ok := r.perSource[0]["dev2"]; ok {
+		t.Error("Least recently used source should be evicted")
+	}
+	if r.allow(limitEvent("dev1", "a")) || r.allow(limitEvent("dev3", "a")) {
+		t.Error("Buckets of recent sources should be kept")
+	}
+}
+
+func TestTopCounts(t *testing.T) {
+	counts := map[string]uint64{}
+	for i := 0; i < 20; i++ {
+		counts[fmt.Sprintf("dev%02d", i)] = uint64(i)
+	}
+
+	top := topCounts(counts)
+	if len(top) != rateLimitedTopKeys {
+		t.Errorf("Only top %d should be reported. Got %+v", rateLimitedTopKeys, top)
+	}
+	if top["dev19"] != uint64(19) || top["dev10"] != uint64(10) || top["dev09"] != nil {
+		t.Errorf("Highest counts should be reported. Got %+v", top)
+	}
+}
+
+func TestClient_RecordEvent_RateLimitsAndSummarizes(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	config.RateLimits = []RateLimit{{PerSource: true, Rate: 0.001, Burst: 2}}
+	client, _ := NewClient(config)
+	now := Timestamp()
+	client.limiter.now = func() int64 { return now }
+
+	for i := 0; i < 5; i++ {
+		client.RecordEvent(Event{"eventName": "device.loop"})
+	}
+	now += 60000
+	client.RecordEvent(Event{"eventName": "device.loop"})
+
+	out := client.queue.Flush()
+	if len(out) != 3 {
+		t.Fatalf("2 allowed events and the summary should be recorded. Got %+v", out)
+	}
+	if out[2]["eventName"] != RateLimitedEvent || out[2]["dropped"] != uint64(4) {
+		t.Errorf("Summary of dropped events should be recorded. Got %+v", out[2])
+	}
+	if client.Stats().RateLimited != 4 {
+		t.Errorf("Dropped events should be counted. Got %+v", client.Stats())
+	}
+}
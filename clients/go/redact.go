@@ -0,0 +1,325 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedactAction is an action applied to sensitive data found in an event.
+type RedactAction string
+
+const (
+	// RedactDrop removes the whole field out of event.
+	RedactDrop RedactAction = "drop"
+	// RedactMask replaces sensitive data with RedactedMask.
+	RedactMask RedactAction = "mask"
+	// RedactHash replaces sensitive data with its keyed HMAC-SHA256 hex digest,
+	// so that redacted values stay joinable.
+	RedactHash RedactAction = "hash"
+)
+
+// RedactedMask is a placeholder for masked data.
+const RedactedMask = "[REDACTED]"
+
+// Detector finds sensitive fragments in string values.
+type Detector interface {
+	// Find returns [start, end) byte offsets of all sensitive fragments in s.
+	Find(s string) [][]int
+}
+
+// Detector based on regular expression with an optional verification of a found fragment.
+type regexpDetector struct {
+	re     *regexp.Regexp
+	verify func(string) bool
+}
+
+// Find returns [start, end) byte offsets of all sensitive fragments in s.
+func (d *regexpDetector) Find(s string) [][]int {
+	found := d.re.FindAllStringIndex(s, -1)
+	if d.verify == nil {
+		return found
+	}
+	result := found[:0]
+	for _, loc := range found {
+		if d.verify(s[loc[0]:loc[1]]) {
+			result = append(result, loc)
+		}
+	}
+	return result
+}
+
+// RegexpDetector creates a Detector which treats every match of re as sensitive.
+func RegexpDetector(re *regexp.Regexp) Detector {
+	return &regexpDetector{re: re}
+}
+
+// Built-in detectors for common PII patterns.
+var (
+	// EmailDetector finds e-mail addresses.
+	EmailDetector = RegexpDetector(regexp.MustCompile(
+		`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`))
+
+	// IPDetector finds IPv4 and IPv6 addresses.
+	IPDetector = RegexpDetector(regexp.MustCompile(
+		`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b` +
+			`|(?i:\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b` +
+			`|\b(?:[0-9a-f]{1,4}:){1,7}:(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6})?\b` +
+			`|::(?:[0-9a-f]{1,4}:){0,6}[0-9a-f]{1,4}\b)`))
+
+	// CreditCardDetector finds payment card numbers (13-19 digits,
+	// optionally separated by spaces or dashes) passing the Luhn check.
+	CreditCardDetector Detector = &regexpDetector{
+		re:     regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		verify: luhnValid,
+	}
+
+	// TokenDetector finds secrets such as bearer tokens, JWTs and well-known API keys.
+	TokenDetector = RegexpDetector(regexp.MustCompile(
+		`(?i:bearer\s+[A-Za-z0-9\-._~+/]+=*)` +
+			`|\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+` +
+			`|\bAKIA[0-9A-Z]{16}\b` +
+			`|\bgh[pousr]_[A-Za-z0-9]{36,}\b` +
+			`|\bxox[abprs]-[A-Za-z0-9\-]{10,}`))
+)
+
+// RedactionRule describes which data in event is sensitive and what to do with it.
+type RedactionRule struct {
+	// Field name or glob (see MatchGlob), e.g.: "email", "user.*", "*Token".
+	// Nested fields are matched both by their own name and by dotted path.
+	// Empty value matches any field.
+	Field string
+
+	// Detector of sensitive fragments in field values.
+	// If nil, the whole value of a matched field is considered sensitive.
+	Detector Detector
+
+	// What to do with sensitive data.
+	Action RedactAction
+}
+
+// PIIRules returns rules that apply given action to common PII
+// (e-mails, IP addresses, card numbers and tokens) found in any field.
+func PIIRules(action RedactAction) []RedactionRule {
+	return []RedactionRule{
+		{Detector: EmailDetector, Action: action},
+		{Detector: IPDetector, Action: action},
+		{Detector: CreditCardDetector, Action: action},
+		{Detector: TokenDetector, Action: action},
+	}
+}
+
+// Redactor scrubs sensitive data out of events.
+type redactor struct {
+	rules []RedactionRule
+	key   []byte
+	// Redactor with the rules naming fields, applied to numbers injected by the client.
+	named *redactor
+}
+
+// Creates new redactor. Returns nil if there is nothing to redact.
+func newRedactor(rules []RedactionRule, key string) *redactor {
+	if len(rules) == 0 {
+		return nil
+	}
+	named := &redactor{key: []byte(key)}
+	for _, rule := range rules {
+		if rule.Field != "" {
+			named.rules = append(named.rules, rule)
+		}
+	}
+	return &redactor{rules: rules, key: []byte(key), named: named}
+}
+
+// Applies redaction rules to event in place.
+// Core fields `eventName` and `timestamp` are never redacted,
+// `sourceId` is hashed as a whole if any rule changes it, so that
+// sources stay distinct in sessions, sampling and rate limits. Numbers the client
+// injected into the event in the given facets, e.g. `duration` of a span,
+// are redacted only by rules naming the field.
+func (r *redactor) redact(e Event, injected ...string) {
+	if r == nil {
+		return
+	}
+	for field, value := range e {
+		if field == "eventName" || field == "timestamp" {
+			continue
+		}
+		rules := r
+		if isNumber(value) && contains(injected, field) {
+			rules = r.named
+		}
+		newValue, keep := rules.redactValue(field, field, value)
+		switch {
+		case field == "sourceId":
+			if !keep || newValue != value {
+				e[field] = r.hash(fmt.Sprint(value))
+			}
+		case keep:
+			e[field] = newValue
+		default:
+			delete(e, field)
+		}
+	}
+}
+
+// Redacts a single value at given path.
+// Returns the new value and whether it should be kept at all.
+// Nested maps and slices are copied, so that user data is never modified.
+func (r *redactor) redactValue(name, path string, value interface{}) (interface{}, bool) {
+	for _, rule := range r.rules {
+		if rule.Detector != nil || !rule.matches(name, path) {
+			continue
+		}
+		switch rule.Action {
+		case RedactDrop:
+			return nil, false
+		case RedactMask:
+			return RedactedMask, true
+		case RedactHash:
+			return r.hash(fmt.Sprint(value)), true
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return r.redactMap(path, v), true
+	case Event:
+		return Event(r.redactMap(path, v)), true
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if newItem, keep := r.redactValue(name, path, item); keep {
+				result = append(result, newItem)
+			}
+		}
+		return result, true
+	}
+
+	for _, rule := range r.rules {
+		if rule.Detector == nil || !rule.matches(name, path) {
+			continue
+		}
+		str, ok := scalarString(value, rule.Field != "")
+		if !ok {
+			continue
+		}
+		found := rule.Detector.Find(str)
+		if len(found) == 0 {
+			continue
+		}
+		if rule.Action == RedactDrop {
+			return nil, false
+		}
+		value = r.replace(str, found, rule.Action)
+	}
+	return value, true
+}
+
+// Checks whether rule applies to a field with given name and dotted path.
+func (rule RedactionRule) matches(name, path string) bool {
+	return rule.Field == "" || MatchGlob(rule.Field, name) || MatchGlob(rule.Field, path)
+}
+
+// Redacts all entries of a nested map.
+func (r *redactor) redactMap(path string, m map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if newValue, keep := r.redactValue(k, path+"."+k, v); keep {
+			result[k] = newValue
+		}
+	}
+	return result
+}
+
+// Replaces found fragments of s according to action.
+func (r *redactor) replace(s string, found [][]int, action RedactAction) string {
+	var result strings.Builder
+	last := 0
+	for _, loc := range found {
+		result.WriteString(s[last:loc[0]])
+		if action == RedactHash {
+			result.WriteString(r.hash(s[loc[0]:loc[1]]))
+		} else {
+			result.WriteString(RedactedMask)
+		}
+		last = loc[1]
+	}
+	result.WriteString(s[last:])
+	return result.String()
+}
+
+// Keyed HMAC-SHA256 hash of data.
+func (r *redactor) hash(data string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Gets string representation of scalar values that detectors can inspect.
+// Numbers are inspected only if numeric is set, i.e. by rules of explicitly
+// named fields, as about every tenth long number passes the Luhn check.
+func scalarString(value interface{}, numeric bool) (string, bool) {
+	if v, ok := value.(string); ok {
+		return v, true
+	}
+	if !numeric {
+		return "", false
+	}
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// Tells whether the value is a number.
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int, int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+// Tells whether the list contains the string.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks digits of s (ignoring separators) against Luhn algorithm.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
@@ -0,0 +1,301 @@
+// Package replay publishes events captured to files back to Samsara,
+// e.g. segments written by the file transport during an ingestion outage.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Extensions of files picked up from directories.
+var extensions = []string{".ndjson", ".ndjson.gz", ".json", ".json.gz"}
+
+// Options of a replay.
+type Options struct {
+	// Publisher the events are published through.
+	Publisher client.IPublisher
+
+	// Number of events published in a single request.
+	BatchSize int
+
+	// Max average number of published events per second, 0 for unlimited.
+	Rate float64
+
+	// Number of retries of a failed batch before the replay is stopped.
+	Retries int
+
+	// Delay between retries.
+	RetryDelay time.Duration
+
+	// File the progress is saved to after each batch, so that
+	// a stopped replay resumes where it left off. Empty to disable.
+	CheckpointFile string
+
+	// Called with events that are rejected because they are malformed
+	// or fail the validation. Event is nil for malformed ones.
+	OnReject func(file string, event client.Event, err error)
+}
+
+// Summary of a replay.
+type Summary struct {
+	// Number of replayed files.
+	Files int
+
+	// Number of published events.
+	Accepted int
+
+	// Number of malformed or invalid events.
+	Rejected int
+
+	// Number of events in the batch that couldn't be published.
+	Failed int
+
+	// Number of events replayed by a previous run according to the checkpoint.
+	Skipped int
+}
+
+// NewOptions creates options with default values publishing through the publisher.
+func NewOptions(publisher client.IPublisher) Options {
+	return Options{
+		Publisher:  publisher,
+		BatchSize:  500,
+		Retries:    3,
+		RetryDelay: time.Second,
+	}
+}
+
+// Replay publishes events from the files, in the given order.
+// Directories are expanded to the event files they contain, sorted by name.
+// Files can be NDJSON or JSON arrays, optionally gzipped.
+// The replay is stopped when a batch can't be published after retries,
+// running it again with the same checkpoint file continues with that batch.
+func Replay(paths []string, options Options) (Summary, error) {
+	var summary Summary
+	if options.BatchSize <= 0 {
+		return summary, fmt.Errorf("batch size should be positive")
+	}
+	files, err := expand(paths)
+	if err != nil {
+		return summary, err
+	}
+	progress, err := loadCheckpoint(options.CheckpointFile)
+	if err != nil {
+		return summary, err
+	}
+
+	r := &replayer{options: options, progress: progress, summary: &summary, start: time.Now()}
+	for _, file := range files {
+		if err := r.replayFile(file); err != nil {
+			return summary, err
+		}
+		summary.Files++
+	}
+	return summary, nil
+}
+
+// State of a running replay.
+type replayer struct {
+	options  Options
+	progress map[string]int
+	summary  *Summary
+	start    time.Time
+	sent     int
+}
+
+// Replays a single file. Progress in a file is the number of read records,
+// valid or not, so the file must not change between runs other than by
+// compression, e.g. of segments rotated by the file transport.
+func (r *replayer) replayFile(file string) error {
+	key := checkpointKey(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	in, err := decompress(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	done := r.progress[key]
+	dec := client.NewEventDecoder(in)
+	var batch []client.Event
+	read := 0
+	for {
+		event, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(client.EventDecodeError); err != nil && !ok {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		read++
+		if read <= done {
+			r.summary.Skipped++
+			continue
+		}
+		if err == nil {
+			err = event.Validate()
+		}
+		if err != nil {
+			r.reject(file, event, err)
+			continue
+		}
+
+		batch = append(batch, event)
+		if len(batch) == r.options.BatchSize {
+			if err := r.publish(key, batch, read); err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			batch = nil
+		}
+	}
+	if read < done {
+		return fmt.Errorf("%s: file is shorter than its checkpoint", file)
+	}
+	if read > done {
+		if err := r.publish(key, batch, read); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// Counts the rejected event and reports it.
+func (r *replayer) reject(file string, event client.Event, err error) {
+	r.summary.Rejected++
+	if r.options.OnReject != nil {
+		r.options.OnReject(file, event, err)
+	}
+}
+
+// Publishes the batch, retrying on failure, and saves the progress.
+func (r *replayer) publish(key string, batch []client.Event, read int) error {
+	if len(batch) > 0 {
+		r.pace()
+		ok := r.options.Publisher.Post(batch)
+		for i := 0; !ok && i < r.options.Retries; i++ {
+			time.Sleep(r.options.RetryDelay)
+			ok = r.options.Publisher.Post(batch)
+		}
+		if !ok {
+			r.summary.Failed += len(batch)
+			return fmt.Errorf("publishing of %d events failed", len(batch))
+		}
+		r.summary.Accepted += len(batch)
+		r.sent += len(batch)
+	}
+	r.progress[key] = read
+	return saveCheckpoint(r.options.CheckpointFile, r.progress)
+}
+
+// Waits until the events sent so far are within the rate.
+func (r *replayer) pace() {
+	if r.options.Rate <= 0 {
+		return
+	}
+	due := r.start.Add(time.Duration(float64(r.sent) / r.options.Rate * float64(time.Second)))
+	time.Sleep(time.Until(due))
+}
+
+// Returns a reader decompressing gzipped content, detected by its magic bytes.
+func decompress(f io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(f)
+	magic, _ := buffered.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return client.Decompress("gzip", buffered)
+	}
+	return buffered, nil
+}
+
+// Returns the key of the file's progress in the checkpoint: its base name
+// without the compression suffix, so that it survives compression of the file.
+func checkpointKey(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".gz")
+}
+
+// Expands directories to event files they contain.
+func expand(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() && hasEventExtension(entry.Name()) {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	return files, nil
+}
+
+// Tells whether the name has an extension of event files.
+func hasEventExtension(name string) bool {
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Loads progress of files from the checkpoint, if there is one.
+func loadCheckpoint(path string) (map[string]int, error) {
+	progress := make(map[string]int)
+	if path == "" {
+		return progress, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return progress, nil
+}
+
+// Saves progress of files to the checkpoint. The file is replaced atomically,
+// so that an interrupted replay doesn't leave it corrupted.
+func saveCheckpoint(path string, progress map[string]int) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,227 @@
+package replay
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Publisher recording posted batches, failing the given number of posts after `failAfter` successful ones.
+type testPublisher struct {
+	batches   [][]client.Event
+	posts     int
+	failAfter int
+	failures  int
+}
+
+func (p *testPublisher) Post(data []client.Event) bool {
+	p.posts++
+	if p.posts > p.failAfter && p.failures > 0 {
+		p.failures--
+		return false
+	}
+	p.batches = append(p.batches, data)
+	return true
+}
+
+// Returns names of published events.
+func (p *testPublisher) names() []string {
+	var names []string
+	for _, batch := range p.batches {
+		for _, event := range batch {
+			names = append(names, event["eventName"].(string))
+		}
+	}
+	return names
+}
+
+// Returns NDJSON line of a valid event.
+func line(name string) string {
+	return fmt.Sprintf("{\"eventName\":%q,\"sourceId\":\"s\",\"timestamp\":1}\n", name)
+}
+
+func writeFile(t *testing.T, path, content string, gzipped bool) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !gzipped {
+		f.WriteString(content)
+		return
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(content))
+	gz.Close()
+}
+
+func newTestOptions(publisher client.IPublisher) Options {
+	options := NewOptions(publisher)
+	options.BatchSize = 2
+	options.RetryDelay = time.Millisecond
+	return options
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "b.ndjson.gz"), line("c")+"\n"+line("d"), true)
+	writeFile(t, filepath.Join(dir, "a.ndjson"), line("a")+"{\"eventName\":\"bad\"}\n"+line("b")+"{\"broken\n", false)
+	writeFile(t, filepath.Join(dir, "ignored.txt"), line("x"), false)
+	array := filepath.Join(t.TempDir(), "c.json")
+	writeFile(t, array, "["+strings.Replace(strings.TrimSpace(line("e")+line("f")+"1\n"+line("g")), "\n", ",", -1)+"]", false)
+
+	var rejected []string
+	publisher := &testPublisher{}
+	options := newTestOptions(publisher)
+	options.OnReject = func(file string, event client.Event, err error) {
+		rejected = append(rejected, fmt.Sprintf("%s %v", filepath.Base(file), event != nil))
+	}
+	summary, err := Replay([]string{dir, array}, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Summary{Files: 3, Accepted: 7, Rejected: 3}); summary != want {
+		t.Errorf("Incorrect summary.\nWant: %+v\nGot: %+v", want, summary)
+	}
+	if want := []string{"a", "b", "c", "d", "e", "f", "g"}; !reflect.DeepEqual(publisher.names(), want) {
+		t.Errorf("Events should be published in order.\nWant: %v\nGot: %v", want, publisher.names())
+	}
+	if len(publisher.batches) != 4 {
+		t.Errorf("Events should be published in batches per file. Got %d", len(publisher.batches))
+	}
+	if want := []string{"a.ndjson true", "a.ndjson false", "c.json false"}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("Invalid and malformed events should be rejected.\nWant: %v\nGot: %v", want, rejected)
+	}
+}
+
+func TestReplay_RetriesFailedBatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.ndjson")
+	writeFile(t, file, line("a")+line("b")+line("c"), false)
+
+	publisher := &testPublisher{failures: 3}
+	summary, err := Replay([]string{file}, newTestOptions(publisher))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Accepted != 3 || summary.Failed != 0 || publisher.posts != 5 {
+		t.Errorf("Failed batch should be retried. Got %+v after %d posts", summary, publisher.posts)
+	}
+}
+
+func TestReplay_ResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.ndjson"), line("a")+line("b")+line("c"), false)
+	writeFile(t, filepath.Join(dir, "b.ndjson"), line("d")+line("e"), false)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	publisher := &testPublisher{failAfter: 2, failures: 4}
+	options := newTestOptions(publisher)
+	options.CheckpointFile = checkpoint
+	summary, err := Replay([]string{dir}, options)
+
+	if err == nil {
+		t.Error("Replay should stop when a batch fails after retries")
+	}
+	if want := (Summary{Files: 1, Accepted: 3, Failed: 2}); summary != want {
+		t.Errorf("Incorrect summary of the failed replay.\nWant: %+v\nGot: %+v", want, summary)
+	}
+
+	publisher = &testPublisher{}
+	options.Publisher = publisher
+	summary, err = Replay([]string{dir}, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Summary{Files: 2, Accepted: 2, Skipped: 3}); summary != want {
+		t.Errorf("Incorrect summary of the resumed replay.\nWant: %+v\nGot: %+v", want, summary)
+	}
+	if want := []string{"d", "e"}; !reflect.DeepEqual(publisher.names(), want) {
+		t.Errorf("Only events after the checkpoint should be published. Got %v", publisher.names())
+	}
+
+	summary, _ = Replay([]string{dir}, options)
+	if want := (Summary{Files: 2, Skipped: 5}); summary != want {
+		t.Errorf("Completed files should be skipped.\nWant: %+v\nGot: %+v", want, summary)
+	}
+	if _, err := os.Stat(checkpoint + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Temporary checkpoint should be renamed")
+	}
+}
+
+func TestReplay_CheckpointSurvivesCompression(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.ndjson")
+	writeFile(t, file, line("a")+line("b")+line("c"), false)
+
+	publisher := &testPublisher{failAfter: 1, failures: 4}
+	options := newTestOptions(publisher)
+	options.CheckpointFile = filepath.Join(t.TempDir(), "checkpoint.json")
+	Replay([]string{dir}, options)
+
+	os.Remove(file)
+	writeFile(t, file+".gz", line("a")+line("b")+line("c"), true)
+	publisher = &testPublisher{}
+	options.Publisher = publisher
+	summary, err := Replay([]string{dir}, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Summary{Files: 1, Accepted: 1, Skipped: 2}); summary != want {
+		t.Errorf("Progress should be kept after the file is compressed.\nWant: %+v\nGot: %+v", want, summary)
+	}
+}
+
+func TestReplay_Rate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.ndjson")
+	writeFile(t, file, strings.Repeat(line("a"), 6), false)
+
+	options := newTestOptions(&testPublisher{})
+	options.Rate = 40
+	start := time.Now()
+	Replay([]string{file}, options)
+
+	// the last batch of 2 is sent after 4 events at 40/s
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Events should be published at the rate. Took %v", elapsed)
+	}
+}
+
+func TestReplay_Errors(t *testing.T) {
+	dir := t.TempDir()
+	truncated := filepath.Join(dir, "truncated.json")
+	writeFile(t, truncated, "["+strings.TrimSpace(line("a")), false)
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	ioutil.WriteFile(checkpoint, []byte("{"), 0644)
+
+	var tests = []struct {
+		paths      []string
+		batchSize  int
+		checkpoint string
+	}{
+		{[]string{filepath.Join(dir, "missing.ndjson")}, 2, ""},
+		{[]string{truncated}, 2, ""},
+		{[]string{truncated}, 0, ""},
+		{[]string{truncated}, 2, checkpoint},
+	}
+
+	for i, test := range tests {
+		options := newTestOptions(&testPublisher{})
+		options.BatchSize = test.batchSize
+		options.CheckpointFile = test.checkpoint
+		if _, err := Replay(test.paths, options); err == nil {
+			t.Errorf("Set #%d. Replay should fail", i)
+		}
+	}
+}
@@ -5,22 +5,43 @@ import (
 	"sync/atomic"
 )
 
+// Delivery states of buffered elements.
+const (
+	pending = iota
+	inFlight
+	acked
+)
+
+// Delivery state of the element at a position.
+// It is stale if the position differs, i.e. the element has been overwritten.
+type delivery struct {
+	pos   int64
+	state int
+}
+
 // RingBuffer is a thread-safe ring-buffer data queue tailored for Samsara Client.
+// Elements can be consumed by several consumers at once, each taking
+// a disjoint chunk of them. Consumed elements are deleted once all elements
+// before them are consumed too, so the buffer is never compacted past
+// an element that is still in flight.
 type RingBuffer struct {
-	size   int64
-	low    int64
-	high   int64
-	buffer []Event
+	size       int64
+	low        int64
+	high       int64
+	buffer     []Event
+	deliveries []delivery
+	acks       *sync.Cond
 	sync.Mutex
 }
 
 // NewRingBuffer creates new ring buffer with given capacity.
 func NewRingBuffer(capacity int64) *RingBuffer {
 	return &RingBuffer{
-		size:   capacity,
-		low:    -1,
-		high:   -1,
-		buffer: make([]Event, capacity),
+		size:       capacity,
+		low:        -1,
+		high:       -1,
+		buffer:     make([]Event, capacity),
+		deliveries: make([]delivery, capacity),
 	}
 }
 
@@ -30,8 +51,11 @@ func (r *RingBuffer) Size() int64 {
 }
 
 // Count gets current number of items in buffer.
+// Items taken by consumers are counted until they are consumed.
 func (r *RingBuffer) Count() int64 {
-	return atomic.LoadInt64(&r.high) - atomic.LoadInt64(&r.low)
+	r.Lock()
+	defer r.Unlock()
+	return r.high - r.low
 }
 
 // IsEmpty answers whether buffer is empty.
@@ -51,63 +75,139 @@ func (r *RingBuffer) Push(event Event) {
 
 	if r.Size() != 0 {
 		r.high++
-		if r.Count() > r.Size() {
+		if r.high-r.low > r.Size() {
 			r.low++
 		}
 		r.buffer[r.calculatePosition(r.high)] = event
+		r.deliveries[r.calculatePosition(r.high)] = delivery{pos: r.high, state: pending}
+		r.compact()
 	}
 }
 
 // Flush extracts all existing elements out of buffer and return them in FIFO order.
 // Accepts optional function that processes data and returns success of the processing.
 // Elements are deleted based on the result of consumer function and deleted always if no consumer provided.
+// Elements taken by other consumers at the moment are not included.
 func (r *RingBuffer) Flush(consumerFn ...func([]Event) bool) []Event {
-	data, atMark := r.takeSnapshot()
+	data, positions := r.take(-1, nil)
 	success := true
 	if len(consumerFn) > 0 {
 		success = consumerFn[0](data)
 	}
-	if success {
-		r.deleteData(atMark)
-	}
+	r.ack(positions, success)
 	return data
 }
 
+// Counts elements which are neither taken by a consumer nor consumed.
+func (r *RingBuffer) pendingCount() int64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.countState(pending)
+}
+
+// Waits until no element is taken by a consumer.
+// Returns the number of elements which are still not consumed.
+func (r *RingBuffer) settle() int64 {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.acks == nil {
+		r.acks = sync.NewCond(&r.Mutex)
+	}
+	for r.countState(inFlight) > 0 {
+		r.acks.Wait()
+	}
+	return r.countState(pending)
+}
+
+// Counts elements in the delivery state. The buffer has to be locked.
+func (r *RingBuffer) countState(state int) int64 {
+	var count int64
+	for i := r.low + 1; i <= r.high; i++ {
+		if r.deliveries[r.calculatePosition(i)].state == state {
+			count++
+		}
+	}
+	return count
+}
+
 // Helper-method for calculating position in a circle.
 func (r *RingBuffer) calculatePosition(pointer int64) int64 {
 	return pointer % r.Size()
 }
 
-// Make a snapshot of the current buffer at a given moment in FIFO order.
-func (r *RingBuffer) takeSnapshot() ([]Event, int64) {
+// Takes up to limit pending elements (all if limit is negative) in FIFO order
+// and marks them in flight. Returns them with their positions to acknowledge.
+// If key is given, elements are skipped while an earlier one with
+// the same key is in flight, so that elements with the same key
+// are consumed in order.
+func (r *RingBuffer) take(limit int64, key func(Event) string) ([]Event, []int64) {
 	r.Lock()
 	defer r.Unlock()
 
-	high := r.high
-	low := r.low
+	var blocked map[string]bool
+	if key != nil {
+		blocked = make(map[string]bool)
+		for i := r.low + 1; i <= r.high; i++ {
+			if r.deliveries[r.calculatePosition(i)].state == inFlight {
+				blocked[key(r.buffer[r.calculatePosition(i)])] = true
+			}
+		}
+	}
 
 	result := make([]Event, 0)
-	for i := low; i < high; i++ {
-		result = append(result, r.buffer[r.calculatePosition(i+1)])
+	var positions []int64
+	for i := r.low + 1; i <= r.high && int64(len(result)) != limit; i++ {
+		slot := r.calculatePosition(i)
+		if r.deliveries[slot].state != pending {
+			continue
+		}
+		event := r.buffer[slot]
+		if key != nil {
+			k := key(event)
+			if blocked[k] {
+				continue
+			}
+		}
+		r.deliveries[slot].state = inFlight
+		result = append(result, event)
+		positions = append(positions, i)
 	}
 
-	return result, high
+	return result, positions
 }
 
-// Removes chunk of elements that present in a snapshot out of buffer.
-// Detects if the last consumed element has been overridden by new pushes.
-func (r *RingBuffer) deleteData(mark int64) {
+// Acknowledges elements taken at the positions. Consumed elements
+// are deleted, unsuccessful ones are returned to be taken again.
+// Positions of elements overridden by new pushes meanwhile are ignored.
+func (r *RingBuffer) ack(positions []int64, success bool) {
 	r.Lock()
 	defer r.Unlock()
 
-	r.low = max(r.low, mark)
-	r.high = max(r.high, mark)
+	state := pending
+	if success {
+		state = acked
+	}
+	for _, pos := range positions {
+		d := &r.deliveries[r.calculatePosition(pos)]
+		if pos > r.low && d.pos == pos && d.state == inFlight {
+			d.state = state
+		}
+	}
+	r.compact()
+	if r.acks != nil {
+		r.acks.Broadcast()
+	}
 }
 
-// Helper. Get max of 2 int64 elements.
-func max(a, b int64) int64 {
-	if a > b {
-		return a
+// Deletes consumed elements at the beginning of the buffer.
+func (r *RingBuffer) compact() {
+	for r.low < r.high {
+		slot := r.calculatePosition(r.low + 1)
+		if r.deliveries[slot].state != acked {
+			return
+		}
+		r.buffer[slot] = nil
+		r.low++
 	}
-	return b
 }
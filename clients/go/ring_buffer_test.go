@@ -1128,3 +1128,90 @@ func TestRingBuffer_Flush_CA_True_ElementsWereTotallyRewrittenAfterFlush_EvenCap
 		t.Errorf("Final flush. Expected %v, Got %v", expected, result)
 	}
 }
+
+func TestRingBuffer_Take_TakesDisjointChunks(t *testing.T) {
+	rb := NewRingBuffer(5)
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		rb.Push(Event{"1": v})
+	}
+
+	first, firstPositions := rb.take(2, nil)
+	second, secondPositions := rb.take(2, nil)
+	if !reflect.DeepEqual(first, []Event{{"1": "a"}, {"1": "b"}}) || !reflect.DeepEqual(second, []Event{{"1": "c"}, {"1": "d"}}) {
+		t.Errorf("Chunks should be disjoint in FIFO order. Got %v and %v", first, second)
+	}
+
+	rb.ack(secondPositions, true)
+	if rb.Count() != 5 || rb.pendingCount() != 1 {
+		t.Errorf("Consumed elements should be kept while earlier ones are in flight. Got count %d, pending %d", rb.Count(), rb.pendingCount())
+	}
+
+	rb.ack(firstPositions, false)
+	result := rb.Flush()
+	expected := []Event{{"1": "a"}, {"1": "b"}, {"1": "e"}}
+	if !reflect.DeepEqual(expected, result) || !rb.IsEmpty() {
+		t.Errorf("Unsuccessful chunk should be taken again. Expected %v, Got %v", expected, result)
+	}
+}
+
+func TestRingBuffer_Ack_OutOfOrder(t *testing.T) {
+	rb := NewRingBuffer(4)
+	for _, v := range []string{"a", "b", "c", "d"} {
+		rb.Push(Event{"1": v})
+	}
+
+	_, first := rb.take(1, nil)
+	_, second := rb.take(2, nil)
+	rb.ack(second, true)
+	rb.ack(first, true)
+
+	if rb.Count() != 1 {
+		t.Errorf("Consumed elements should be deleted. Got count %d", rb.Count())
+	}
+	rb.Push(Event{"1": "e"})
+	rb.Push(Event{"1": "f"})
+	result := rb.Flush()
+	expected := []Event{{"1": "d"}, {"1": "e"}, {"1": "f"}}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected %v, Got %v", expected, result)
+	}
+}
+
+func TestRingBuffer_Ack_IgnoresOverwrittenElements(t *testing.T) {
+	rb := NewRingBuffer(2)
+	rb.Push(Event{"1": "a"})
+	rb.Push(Event{"1": "b"})
+
+	_, positions := rb.take(-1, nil)
+	rb.Push(Event{"1": "c"})
+	rb.ack(positions, false)
+
+	result := rb.Flush()
+	expected := []Event{{"1": "b"}, {"1": "c"}}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected %v, Got %v", expected, result)
+	}
+}
+
+func TestRingBuffer_Take_PreservesOrderOfKey(t *testing.T) {
+	rb := NewRingBuffer(5)
+	rb.Push(Event{"k": "x", "1": "a"})
+	rb.Push(Event{"k": "y", "1": "b"})
+	rb.Push(Event{"k": "x", "1": "c"})
+	rb.Push(Event{"k": "z", "1": "d"})
+	key := func(e Event) string { return e["k"].(string) }
+
+	_, first := rb.take(1, key)
+	result, _ := rb.take(-1, key)
+	expected := []Event{{"k": "y", "1": "b"}, {"k": "z", "1": "d"}}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Elements with key in flight should be skipped. Expected %v, Got %v", expected, result)
+	}
+
+	rb.ack(first, true)
+	result, _ = rb.take(-1, key)
+	expected = []Event{{"k": "x", "1": "c"}}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Skipped elements should be taken once acknowledged. Expected %v, Got %v", expected, result)
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// SamplingMode defines how events matched by a SamplingRule are sampled.
+type SamplingMode string
+
+const (
+	// SampleAlways keeps all events.
+	SampleAlways SamplingMode = "always"
+	// SampleNever drops all events.
+	SampleNever SamplingMode = "never"
+	// SampleRandom keeps each event with the probability of the rule's Rate.
+	SampleRandom SamplingMode = "random"
+	// SampleBySource keeps all or none of the events of a sourceId,
+	// deterministically chosen by hash of the sourceId, so that
+	// the Rate fraction of sources is kept.
+	SampleBySource SamplingMode = "source"
+)
+
+// SampleRateField is a facet attached to sampled events, so that
+// downstream counts can be scaled by 1/sampleRate.
+const SampleRateField = "sampleRate"
+
+// SamplingRule defines sampling of events with matching eventName.
+type SamplingRule struct {
+	// Glob of event names (see MatchGlob), e.g.: "ui.mouse.*".
+	EventName string
+
+	// How events should be sampled.
+	Mode SamplingMode
+
+	// Fraction of events to keep in range [0, 1].
+	// Used only by SampleRandom and SampleBySource modes.
+	Rate float64
+}
+
+// Sampler decides which events are kept.
+type sampler struct {
+	rules  []SamplingRule
+	random func() float64
+}
+
+// Creates new sampler. Returns nil if there is nothing to sample.
+func newSampler(rules []SamplingRule) *sampler {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &sampler{rules: rules, random: rand.Float64}
+}
+
+// Tells whether event should be kept according to the first matching rule.
+// Events without matching rule are always kept.
+// Kept events sampled at rate less than 1 get SampleRateField facet
+// (multiplied with an existing one, if the event has already been sampled).
+func (s *sampler) sample(e Event) bool {
+	if s == nil {
+		return true
+	}
+	name, _ := e["eventName"].(string)
+	for _, rule := range s.rules {
+		if !MatchGlob(rule.EventName, name) {
+			continue
+		}
+		switch rule.Mode {
+		case SampleAlways:
+			return true
+		case SampleNever:
+			return false
+		case SampleRandom:
+			if s.random() >= rule.Rate {
+				return false
+			}
+		case SampleBySource:
+			sid, _ := e["sourceId"].(string)
+			if sourceFraction(sid) >= rule.Rate {
+				return false
+			}
+		}
+		if rule.Rate < 1 {
+			rate := rule.Rate
+			if previous, ok := e[SampleRateField].(float64); ok {
+				rate *= previous
+			}
+			e[SampleRateField] = rate
+		}
+		return true
+	}
+	return true
+}
+
+// Deterministically maps sourceId to a number in range [0, 1).
+func sourceFraction(sourceId string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(sourceId))
+	// FNV is poorly distributed for similar ids, so mix the bits (murmur3 finalizer).
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return float64(x>>11) / float64(uint64(1)<<53)
+}
+
+// Checks whether rate is a valid fraction.
+func validRate(rate float64) bool {
+	return !math.IsNaN(rate) && rate >= 0 && rate <= 1
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSampler_NoRulesKeepsEverything(t *testing.T) {
+	var s *sampler = newSampler(nil)
+	event := Event{"eventName": "foo"}
+
+	if !s.sample(event) {
+		t.Error("Event should be kept when there are no sampling rules")
+	}
+	if _, ok := event[SampleRateField]; ok {
+		t.Errorf("Event should not get sampleRate facet. Got %+v", event)
+	}
+}
+
+func TestSampler_AppliesFirstMatchingRule(t *testing.T) {
+	s := newSampler([]SamplingRule{
+		{EventName: "cache.hit", Mode: SampleNever},
+		{EventName: "cache.*", Mode: SampleAlways},
+		{EventName: "ui.**", Mode: SampleNever},
+	})
+
+	sets := []struct {
+		eventName string
+		want      bool
+	}{
+		{"cache.hit", false},
+		{"cache.miss", true},
+		{"ui.mouse.moved", false},
+		{"user.logged", true},
+	}
+
+	for i, set := range sets {
+		if got := s.sample(Event{"eventName": set.eventName}); got != set.want {
+			t.Errorf("Set #%d. Event %q should be kept: %t", i, set.eventName, set.want)
+		}
+	}
+}
+
+func TestSampler_Random(t *testing.T) {
+	s := newSampler([]SamplingRule{{EventName: "ui.mouse.moved", Mode: SampleRandom, Rate: 0.25}})
+
+	sets := []struct {
+		random float64
+		want   bool
+	}{
+		{0, true},
+		{0.2, true},
+		{0.25, false},
+		{0.9, false},
+	}
+
+	for i, set := range sets {
+		s.random = func() float64 { return set.random }
+		event := Event{"eventName": "ui.mouse.moved"}
+		if got := s.sample(event); got != set.want {
+			t.Errorf("Set #%d. Event should be kept: %t", i, set.want)
+		}
+		if set.want && event[SampleRateField] != 0.25 {
+			t.Errorf("Set #%d. Kept event should have sampleRate facet. Got %+v", i, event)
+		}
+	}
+}
+
+func TestSampler_BySourceIsDeterministic(t *testing.T) {
+	s := newSampler([]SamplingRule{{EventName: "**", Mode: SampleBySource, Rate: 0.5}})
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		sid := fmt.Sprintf("device-%d", i)
+		first := s.sample(Event{"eventName": "a", "sourceId": sid})
+		second := s.sample(Event{"eventName": "b", "sourceId": sid})
+		if first != second {
+			t.Fatalf("Events of the same source %q should be sampled equally", sid)
+		}
+		if first {
+			kept++
+		}
+	}
+
+	if kept < 400 || kept > 600 {
+		t.Errorf("About half of the sources should be kept. Got %d of 1000", kept)
+	}
+}
+
+func TestSampler_FullRateDoesNotAddFacetAndExistingRateIsMultiplied(t *testing.T) {
+	s := newSampler([]SamplingRule{
+		{EventName: "full", Mode: SampleRandom, Rate: 1},
+		{EventName: "half", Mode: SampleRandom, Rate: 0.5},
+	})
+	s.random = func() float64 { return 0 }
+
+	full := Event{"eventName": "full"}
+	s.sample(full)
+	if _, ok := full[SampleRateField]; ok {
+		t.Errorf("Event sampled at rate 1 should not get sampleRate facet. Got %+v", full)
+	}
+
+	half := Event{"eventName": "half", SampleRateField: 0.5}
+	s.sample(half)
+	if half[SampleRateField] != 0.25 {
+		t.Errorf("Existing sampleRate should be multiplied. Got %+v", half)
+	}
+}
+
+func TestClient_RecordEvent_DropsSampledOutEvents(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "secret"
+	config.StartPublishingThread = false
+	config.Sampling = []SamplingRule{{EventName: "ui.mouse.*", Mode: SampleNever}}
+	client, _ := NewClient(config)
+
+	if err := client.RecordEvent(Event{"eventName": "ui.mouse.moved"}); err != nil {
+		t.Errorf("Sampled out event should not raise an error. Got %+v", err)
+	}
+	client.RecordEvent(Event{"eventName": "user.logged"})
+
+	out := client.queue.Flush()
+	if len(out) != 1 || out[0]["eventName"] != "user.logged" {
+		t.Errorf("Only kept events should be in a queue. Got %+v", out)
+	}
+}
+
+func TestClient_PublishEvents_DoesNotPublishSampledOutEvents(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "secret"
+	config.Sampling = []SamplingRule{{EventName: "cache.hit", Mode: SampleNever}}
+	client, _ := NewClient(config)
+	client.publisher = &PublisherMock{
+		fakePost: func(events []Event) bool {
+			if len(events) != 1 || events[0]["eventName"] != "cache.miss" {
+				t.Errorf("Only kept events should be published. Got %+v", events)
+			}
+			return true
+		},
+	}
+
+	client.PublishEvents([]Event{{"eventName": "cache.hit"}, {"eventName": "cache.miss"}})
+}
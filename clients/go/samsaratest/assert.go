@@ -0,0 +1,223 @@
+package samsaratest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/ingestion"
+)
+
+// Predicate tells whether a facet value is expected.
+// Missing facets are passed as nil.
+type Predicate func(value interface{}) bool
+
+// Present is a predicate of facets which are present.
+func Present(value interface{}) bool {
+	return value != nil
+}
+
+// Matcher matches events by eventName and facets, e.g.
+//
+//	samsaratest.Matcher{
+//		EventName: "user.item.*",
+//		Facets: map[string]interface{}{
+//			"page": "orders",
+//			"item": samsaratest.Predicate(func(v interface{}) bool { return strings.HasPrefix(v.(string), "sku-") }),
+//		},
+//	}
+type Matcher struct {
+	// Glob matched against eventName, empty matches any.
+	// See client.MatchGlob for allowed globs.
+	EventName string
+
+	// Expected values of facets, or Predicates they should satisfy.
+	// Numbers are compared by value, e.g. 1 equals int64(1).
+	Facets map[string]interface{}
+}
+
+// Match tells whether the event matches.
+func (m Matcher) Match(event client.Event) bool {
+	return len(m.mismatches(event)) == 0
+}
+
+// String describes the matcher.
+func (m Matcher) String() string {
+	var parts []string
+	if m.EventName != "" {
+		parts = append(parts, fmt.Sprintf("eventName=%q", m.EventName))
+	}
+	for _, name := range sortedKeys(m.Facets) {
+		if _, ok := m.Facets[name].(Predicate); ok {
+			parts = append(parts, name+"=<predicate>")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, format(m.Facets[name])))
+		}
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// Returns differences between the event and the matcher.
+func (m Matcher) mismatches(event client.Event) []string {
+	var diffs []string
+	if name, _ := event["eventName"].(string); m.EventName != "" && !client.MatchGlob(m.EventName, name) {
+		diffs = append(diffs, fmt.Sprintf("eventName: %q doesn't match %q", name, m.EventName))
+	}
+	for _, name := range sortedKeys(m.Facets) {
+		got, present := event[name]
+		switch want := m.Facets[name].(type) {
+		case Predicate:
+			if !want(got) {
+				diffs = append(diffs, fmt.Sprintf("%s: %s doesn't satisfy the predicate", name, describe(got, present)))
+			}
+		default:
+			if !present || !equal(want, got) {
+				diffs = append(diffs, fmt.Sprintf("%s: want %s, got %s", name, format(want), describe(got, present)))
+			}
+		}
+	}
+	return diffs
+}
+
+// Filter returns events matching the matcher.
+func Filter(events []client.Event, m Matcher) []client.Event {
+	var matching []client.Event
+	for _, event := range events {
+		if m.Match(event) {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+// WaitForEvents waits until the server accepts at least n events and
+// returns them. The test fails if it doesn't happen within the timeout.
+func (s *Server) WaitForEvents(t testing.TB, n int, timeout time.Duration) []client.Event {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		s.Lock()
+		events := append([]client.Event(nil), s.events...)
+		changed := s.changed()
+		s.Unlock()
+
+		if len(events) >= n {
+			return events
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("Want %d events within %v, got %d:%s", n, timeout, len(events), list(events, nil))
+			return events
+		}
+	}
+}
+
+// ExpectEvent returns the first accepted event matching the matcher.
+// The test fails if there's none, listing the differences of the events.
+func (s *Server) ExpectEvent(t testing.TB, m Matcher) client.Event {
+	t.Helper()
+	events := s.Events()
+	if matching := Filter(events, m); len(matching) > 0 {
+		return matching[0]
+	}
+	t.Fatalf("No event matches %v, got %d events:%s", m, len(events), list(events, &m))
+	return nil
+}
+
+// ExpectNoEvent fails the test if an accepted event matches the matcher.
+func (s *Server) ExpectNoEvent(t testing.TB, m Matcher) {
+	t.Helper()
+	if matching := Filter(s.Events(), m); len(matching) > 0 {
+		t.Errorf("Want no event matching %v, got %d:%s", m, len(matching), list(matching, nil))
+	}
+}
+
+// DecodeRequest decodes events POSTed to a hand-rolled Ingestion API,
+// decompressing compressed requests.
+func DecodeRequest(r *http.Request) ([]client.Event, error) {
+	body, err := ingestion.Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	return ingestion.DecodeEvents(body)
+}
+
+// Returns a channel closed when a request is captured.
+// The server has to be locked.
+func (s *Server) changed() chan struct{} {
+	if s.notify == nil {
+		s.notify = make(chan struct{})
+	}
+	return s.notify
+}
+
+// Lists the events, one per line, with their differences from the matcher.
+func list(events []client.Event, m *Matcher) string {
+	var out strings.Builder
+	for i, event := range events {
+		fmt.Fprintf(&out, "\n  #%d %s", i, format(event))
+		if m != nil {
+			for _, diff := range m.mismatches(event) {
+				fmt.Fprintf(&out, "\n      %s", diff)
+			}
+		}
+	}
+	return out.String()
+}
+
+// Tells whether values are equal, comparing numbers by value.
+func equal(want, got interface{}) bool {
+	if w, ok := number(want); ok {
+		g, ok := number(got)
+		return ok && w == g
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// Converts a number to float64.
+func number(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// Describes a facet value, which may be missing.
+func describe(value interface{}, present bool) string {
+	if !present {
+		return "missing"
+	}
+	return format(value)
+}
+
+// Formats the value as JSON.
+func format(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+	return string(data)
+}
+
+// Returns sorted keys of the map.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
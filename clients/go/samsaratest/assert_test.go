@@ -0,0 +1,188 @@
+package samsaratest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Test recording failures of helpers.
+type recorder struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	runtime.Goexit()
+}
+
+// Runs the helper in a goroutine, so that Fatalf can stop it.
+func run(helper func(t testing.TB)) *recorder {
+	r := &recorder{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		helper(r)
+	}()
+	wg.Wait()
+	return r
+}
+
+func publish(t *testing.T, s *Server, events ...client.Event) {
+	config := client.NewConfig()
+	config.Url = s.URL
+	for _, event := range events {
+		event["timestamp"] = client.Timestamp()
+	}
+	if !client.NewPublisher(config).Post(events) {
+		t.Error("Events should be published")
+	}
+}
+
+func TestMatcher_Match(t *testing.T) {
+	event := client.Event{"eventName": "user.item.added", "sourceId": "s", "count": int64(2), "ratio": 0.5, "tags": []interface{}{"a"}}
+	var tests = []struct {
+		matcher Matcher
+		want    bool
+	}{
+		{Matcher{}, true},
+		{Matcher{EventName: "user.item.*"}, true},
+		{Matcher{EventName: "user.*"}, false},
+		{Matcher{EventName: "user.**"}, true},
+		{Matcher{Facets: map[string]interface{}{"count": 2, "ratio": float32(0.5), "sourceId": "s"}}, true},
+		{Matcher{Facets: map[string]interface{}{"tags": []interface{}{"a"}}}, true},
+		{Matcher{Facets: map[string]interface{}{"count": "2"}}, false},
+		{Matcher{Facets: map[string]interface{}{"missing": nil}}, false},
+		{Matcher{Facets: map[string]interface{}{"count": Predicate(Present)}}, true},
+		{Matcher{Facets: map[string]interface{}{"missing": Predicate(Present)}}, false},
+		{Matcher{Facets: map[string]interface{}{"count": Predicate(func(v interface{}) bool { return v.(int64) > 1 })}}, true},
+	}
+
+	for i, test := range tests {
+		if got := test.matcher.Match(event); got != test.want {
+			t.Errorf("Set #%d. Matching %v: want %v, got %v", i, test.matcher, test.want, got)
+		}
+	}
+}
+
+func TestServer_WaitForEvents(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		publish(t, s, client.Event{"eventName": "a", "sourceId": "s"})
+		publish(t, s, client.Event{"eventName": "b", "sourceId": "s"})
+	}()
+	var events []client.Event
+	r := run(func(t testing.TB) { events = s.WaitForEvents(t, 2, 5*time.Second) })
+	if len(r.failures) > 0 || len(events) != 2 || events[1]["eventName"] != "b" {
+		t.Errorf("Events should be awaited. Got %v %v", events, r.failures)
+	}
+
+	start := time.Now()
+	r = run(func(t testing.TB) { s.WaitForEvents(t, 3, 50*time.Millisecond) })
+	if !r.fatal || time.Since(start) > time.Second {
+		t.Fatal("Waiting should fail after the timeout")
+	}
+	if !strings.Contains(r.failures[0], "Want 3 events within 50ms, got 2:\n  #0 {") {
+		t.Errorf("Failure should list received events. Got %s", r.failures[0])
+	}
+}
+
+func TestServer_ExpectEvent(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	publish(t, s,
+		client.Event{"eventName": "user.item.added", "sourceId": "s", "item": "sku-1"},
+		client.Event{"eventName": "user.item.removed", "sourceId": "s", "item": "sku-2"},
+	)
+
+	var event client.Event
+	r := run(func(t testing.TB) {
+		event = s.ExpectEvent(t, Matcher{EventName: "user.item.*", Facets: map[string]interface{}{"item": "sku-2"}})
+	})
+	if len(r.failures) > 0 || event["eventName"] != "user.item.removed" {
+		t.Errorf("Matching event should be returned. Got %v %v", event, r.failures)
+	}
+
+	r = run(func(t testing.TB) {
+		s.ExpectEvent(t, Matcher{EventName: "user.item.added", Facets: map[string]interface{}{"item": "sku-2", "page": "orders"}})
+	})
+	if !r.fatal {
+		t.Fatal("Expectation should fail")
+	}
+	for _, want := range []string{
+		`No event matches {eventName="user.item.added", item="sku-2", page="orders"}, got 2 events:`,
+		"\n      item: want \"sku-2\", got \"sku-1\"\n      page: want \"orders\", got missing\n  #1 {",
+		"\n      eventName: \"user.item.removed\" doesn't match \"user.item.added\"",
+	} {
+		if !strings.Contains(r.failures[0], want) {
+			t.Errorf("Failure should contain %q. Got:\n%s", want, r.failures[0])
+		}
+	}
+
+	r = run(func(t testing.TB) { s.ExpectNoEvent(t, Matcher{EventName: "user.item.removed"}) })
+	if len(r.failures) != 1 || r.fatal {
+		t.Errorf("Unexpected event should fail the test. Got %v", r.failures)
+	}
+	r = run(func(t testing.TB) { s.ExpectNoEvent(t, Matcher{EventName: "user.logged.in"}) })
+	if len(r.failures) != 0 {
+		t.Errorf("No event should match. Got %v", r.failures)
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	var decoded [][]client.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, err := DecodeRequest(r)
+		if err != nil {
+			t.Error(err)
+		}
+		decoded = append(decoded, events)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	for _, compression := range []string{"gzip", "none"} {
+		config := client.NewConfig()
+		config.Url = server.URL
+		config.Compression = compression
+		client.NewPublisher(config).Post([]client.Event{{"eventName": compression, "count": 1}})
+	}
+
+	want := [][]client.Event{{{"eventName": "gzip", "count": int64(1)}}, {{"eventName": "none", "count": int64(1)}}}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Want: %v\nGot: %v", want, decoded)
+	}
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write([]byte(`[{"eventName":"a"}`))
+	gz.Close()
+	req := httptest.NewRequest("POST", client.API_PATH, &body)
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, err := DecodeRequest(req); err == nil {
+		t.Error("Malformed request should not be decoded")
+	}
+}
@@ -0,0 +1,238 @@
+package samsaratest
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/ingestion"
+)
+
+// Fault is a misbehaviour injected into a request.
+type Fault int
+
+const (
+	// FaultNone serves the request normally.
+	FaultNone Fault = iota
+
+	// FaultLatency delays the request by Faults.Latency,
+	// then serves it normally.
+	FaultLatency
+
+	// FaultError responds with Faults.Status without accepting events.
+	FaultError
+
+	// FaultUnavailable responds 503, e.g. `{"status": "offline"}` to api-status.
+	FaultUnavailable
+
+	// FaultReset resets the connection without a response.
+	FaultReset
+
+	// FaultPartialRead reads a half of the request body, or its first
+	// chunk if the length is unknown, and resets the connection.
+	FaultPartialRead
+
+	// FaultSlowBody reads the request body slowly, a chunk every
+	// Faults.Latency, then serves the request normally.
+	FaultSlowBody
+)
+
+// Names of faults.
+var faultNames = []string{"none", "latency", "error", "unavailable", "reset", "partial-read", "slow-body"}
+
+// Size of chunks of the request body read by FaultSlowBody and FaultPartialRead.
+const slowBodyChunk = 1024
+
+// String returns the name of the fault, e.g. "partial-read".
+func (f Fault) String() string {
+	if f < 0 || int(f) >= len(faultNames) {
+		return "unknown"
+	}
+	return faultNames[f]
+}
+
+// ParseFault returns the fault of the given name.
+func ParseFault(name string) (Fault, bool) {
+	for i, faultName := range faultNames {
+		if strings.EqualFold(name, faultName) {
+			return Fault(i), true
+		}
+	}
+	return FaultNone, false
+}
+
+// Faults configures faults injected into requests of a path.
+// Faults are taken from the Script for successive requests, and
+// then drawn randomly by their probabilities, e.g. flapping api-status:
+//
+//	server.SetFaults(client.STATUS_PATH, samsaratest.Faults{
+//		Random: map[samsaratest.Fault]float64{samsaratest.FaultUnavailable: 0.5},
+//	})
+type Faults struct {
+	// Faults of successive requests.
+	Script []Fault
+
+	// Repeat the script instead of drawing random faults after it.
+	Loop bool
+
+	// Probabilities of faults of requests beyond the script.
+	Random map[Fault]float64
+
+	// Delay of FaultLatency and FaultSlowBody.
+	// default = 1s
+	Latency time.Duration
+
+	// Status code of FaultError.
+	// default = 500
+	Status int
+
+	// Seed of random faults, to make runs reproducible.
+	// default = 0 (seeded by current time)
+	Seed int64
+}
+
+// State of faults injected into a path.
+type injector struct {
+	faults   Faults
+	requests int
+	random   *rand.Rand
+}
+
+// SetFaults sets faults injected into requests of the path,
+// e.g. client.API_PATH. Zero Faults stop injecting them.
+func (s *Server) SetFaults(path string, faults Faults) {
+	if faults.Latency <= 0 {
+		faults.Latency = time.Second
+	}
+	if faults.Status == 0 {
+		faults.Status = http.StatusInternalServerError
+	}
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.faults == nil {
+		s.faults = make(map[string]*injector)
+	}
+	s.faults[path] = &injector{faults: faults, random: rand.New(rand.NewSource(seed))}
+}
+
+// Returns the fault of the next request of the path.
+func (s *Server) nextFault(path string) (Fault, Faults) {
+	s.Lock()
+	defer s.Unlock()
+	inj := s.faults[path]
+	if inj == nil {
+		return FaultNone, Faults{}
+	}
+	return inj.next(), inj.faults
+}
+
+// Returns the fault of the next request.
+func (inj *injector) next() Fault {
+	n := inj.requests
+	inj.requests++
+	if script := inj.faults.Script; len(script) > 0 && (n < len(script) || inj.faults.Loop) {
+		return script[n%len(script)]
+	}
+
+	// in order of faults, so that seeded runs are reproducible
+	var faults []int
+	for fault := range inj.faults.Random {
+		faults = append(faults, int(fault))
+	}
+	sort.Ints(faults)
+	draw := inj.random.Float64()
+	for _, fault := range faults {
+		draw -= inj.faults.Random[Fault(fault)]
+		if draw < 0 {
+			return Fault(fault)
+		}
+	}
+	return FaultNone
+}
+
+// Injects the fault into the request. Returns false if the request
+// has been responded to, or the connection reset.
+func injectFault(fault Fault, faults Faults, w http.ResponseWriter, r *http.Request) (int, bool) {
+	switch fault {
+	case FaultLatency:
+		sleep(r, faults.Latency)
+	case FaultSlowBody:
+		r.Body = &slowReader{r: r.Body, request: r, delay: faults.Latency}
+	case FaultError:
+		ingestion.Reply(w, faults.Status, ingestion.ErrorBody("Injected fault"))
+		return faults.Status, false
+	case FaultUnavailable:
+		if r.URL.Path == client.STATUS_PATH {
+			ingestion.Reply(w, http.StatusServiceUnavailable, map[string]string{"status": "offline"})
+		} else {
+			ingestion.Reply(w, http.StatusServiceUnavailable, ingestion.ErrorBody("Service unavailable"))
+		}
+		return http.StatusServiceUnavailable, false
+	case FaultPartialRead:
+		partial := r.ContentLength / 2
+		if partial < 0 {
+			partial = slowBodyChunk
+		}
+		io.CopyN(ioutil.Discard, r.Body, partial)
+		reset(w)
+		return 0, false
+	case FaultReset:
+		reset(w)
+		return 0, false
+	}
+	return 0, true
+}
+
+// Sleeps unless the request is cancelled.
+func sleep(r *http.Request, delay time.Duration) {
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+	}
+}
+
+// Resets the connection of the request.
+func reset(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// Reader reading a chunk every delay.
+type slowReader struct {
+	r       io.ReadCloser
+	request *http.Request
+	delay   time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	sleep(s.request, s.delay)
+	if len(p) > slowBodyChunk {
+		p = p[:slowBodyChunk]
+	}
+	return s.r.Read(p)
+}
+
+func (s *slowReader) Close() error {
+	return s.r.Close()
+}
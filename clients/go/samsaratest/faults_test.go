@@ -0,0 +1,126 @@
+package samsaratest
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+func newPublisher(s *Server, timeout uint32) *client.Publisher {
+	config := client.NewConfig()
+	config.Url = s.URL
+	config.SendTimeout = timeout
+	return client.NewPublisher(config)
+}
+
+func TestServer_Faults_Script(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetFaults(client.API_PATH, Faults{
+		Script:  []Fault{FaultError, FaultUnavailable, FaultReset, FaultPartialRead, FaultLatency, FaultSlowBody, FaultNone},
+		Latency: 10 * time.Millisecond,
+	})
+
+	publisher := newPublisher(s, 5000)
+	events := []client.Event{{"eventName": "a", "sourceId": "s", "timestamp": int64(1), "padding": strings.Repeat("x", 4096)}}
+	var posted []bool
+	for i := 0; i < 8; i++ {
+		posted = append(posted, publisher.Post(events))
+	}
+
+	want := []bool{false, false, false, false, true, true, true, true}
+	if !reflect.DeepEqual(posted, want) {
+		t.Errorf("Want: %v\nGot: %v", want, posted)
+	}
+	var statuses []int
+	var faults []Fault
+	for _, req := range s.Requests() {
+		statuses = append(statuses, req.Status)
+		faults = append(faults, req.Fault)
+	}
+	if want := []int{500, 503, 0, 0, 202, 202, 202, 202}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Want statuses: %v\nGot: %v", want, statuses)
+	}
+	if want := []Fault{FaultError, FaultUnavailable, FaultReset, FaultPartialRead, FaultLatency, FaultSlowBody, FaultNone, FaultNone}; !reflect.DeepEqual(faults, want) {
+		t.Errorf("Want faults: %v\nGot: %v", want, faults)
+	}
+	if len(s.Events()) != 4 {
+		t.Errorf("Only events of successful requests should be accepted. Got %d", len(s.Events()))
+	}
+}
+
+func TestServer_Faults_LatencyTimesOutClient(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetFaults(client.API_PATH, Faults{Script: []Fault{FaultLatency}, Latency: time.Second})
+
+	start := time.Now()
+	if newPublisher(s, 50).Post([]client.Event{{"eventName": "a", "sourceId": "s", "timestamp": int64(1)}}) {
+		t.Error("Post should time out")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Post should time out before the latency. Got %v", elapsed)
+	}
+}
+
+func TestServer_Faults_FlappingStatus(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetFaults(client.STATUS_PATH, Faults{Script: []Fault{FaultNone, FaultUnavailable}, Loop: true})
+
+	var statuses []int
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(s.URL + client.STATUS_PATH)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	if want := []int{200, 503, 200, 503}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Want: %v\nGot: %v", want, statuses)
+	}
+}
+
+func TestInjector_Random(t *testing.T) {
+	draw := func(seed int64) []Fault {
+		s := &Server{}
+		s.SetFaults("/", Faults{Script: []Fault{FaultLatency}, Random: map[Fault]float64{FaultReset: 0.2, FaultError: 0.3}, Seed: seed})
+		inj := s.faults["/"]
+		var faults []Fault
+		for i := 0; i < 1000; i++ {
+			faults = append(faults, inj.next())
+		}
+		return faults
+	}
+
+	faults := draw(42)
+	counts := map[Fault]int{}
+	for _, fault := range faults[1:] {
+		counts[fault]++
+	}
+	if faults[0] != FaultLatency {
+		t.Errorf("Script should be applied first. Got %v", faults[0])
+	}
+	if counts[FaultReset] < 150 || counts[FaultReset] > 250 || counts[FaultError] < 250 || counts[FaultError] > 350 {
+		t.Errorf("Faults should be drawn by their probabilities. Got %v", counts)
+	}
+	if !reflect.DeepEqual(faults, draw(42)) {
+		t.Error("Faults should be reproducible with the same seed")
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	for fault := FaultNone; fault <= FaultSlowBody; fault++ {
+		if parsed, ok := ParseFault(fault.String()); !ok || parsed != fault {
+			t.Errorf("Fault %v should be parsed. Got %v", fault, parsed)
+		}
+	}
+	if _, ok := ParseFault("meltdown"); ok {
+		t.Error("Unknown fault should not be parsed")
+	}
+}
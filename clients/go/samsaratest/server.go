@@ -0,0 +1,176 @@
+// Package samsaratest provides a fake Ingestion API for tests of code
+// publishing events to Samsara. The fake implements ingestion-api-spec.yaml
+// and captures received requests and events for assertions, e.g.
+//
+//	server := samsaratest.NewServer()
+//	defer server.Close()
+//	config.Url = server.URL
+//	...
+//	events := server.Events()
+package samsaratest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/ingestion"
+)
+
+// Request is a request received by the fake Ingestion API.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+
+	// Decompressed body of the request.
+	Body []byte
+
+	// Status code of the response, 0 if the connection was reset.
+	Status int
+
+	// Fault injected into the request.
+	Fault Fault
+
+	// Accepted events, enriched with `receivedAt` and `publishedAt`.
+	// Nil if the request was rejected.
+	Events []client.Event
+}
+
+// Server is a fake Ingestion API listening on a local address. It serves
+// requests by ingestion.Handler, except that PUT /v1/api-status isn't
+// restricted to the admin port. It can also be used as http.Handler
+// of another server.
+type Server struct {
+	*httptest.Server
+
+	requests []Request
+	events   []client.Event
+	ignore   bool
+	status   ingestion.Handler
+	faults   map[string]*injector
+	notify   chan struct{}
+	sync.Mutex
+}
+
+// NewServer starts a fake Ingestion API. Its address is in server.URL.
+// The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Events returns events accepted so far, in the order of receipt.
+func (s *Server) Events() []client.Event {
+	s.Lock()
+	defer s.Unlock()
+	return append([]client.Event(nil), s.events...)
+}
+
+// Requests returns requests received so far, including rejected ones.
+func (s *Server) Requests() []Request {
+	s.Lock()
+	defer s.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset forgets captured requests and events.
+func (s *Server) Reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.requests = nil
+	s.events = nil
+}
+
+// SetCapture sets whether requests and events are captured, which is
+// the default. Long-running servers should disable it.
+func (s *Server) SetCapture(capture bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.ignore = !capture
+}
+
+// SetOnline sets the status reported by GET /v1/api-status,
+// like PUT /v1/api-status does. Events are accepted either way.
+func (s *Server) SetOnline(online bool) {
+	s.status.SetOnline(online)
+}
+
+// ServeHTTP serves the Ingestion API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone()}
+	fault, faults := s.nextFault(r.URL.Path)
+	req.Fault = fault
+	defer s.capture(&req)
+
+	var ok bool
+	if req.Status, ok = injectFault(fault, faults, w, r); !ok {
+		return
+	}
+
+	// the decompressed body is captured and handed over to the handler,
+	// which rejects the body as received if it can't be decompressed
+	raw, _ := ioutil.ReadAll(r.Body)
+	if body, err := decompress(r, raw); err == nil {
+		req.Body = body
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.Header.Del("Content-Encoding")
+	} else {
+		req.Body = raw
+		r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	switch {
+	case r.URL.Path == client.API_PATH && r.Method == "POST":
+		// the backend is called while the request is served
+		backend := ingestion.BackendFunc(func(events []client.Event) error {
+			req.Events = events
+			return nil
+		})
+		ingestion.NewHandler(backend).ServeHTTP(recorder, r)
+	case r.URL.Path == client.STATUS_PATH && r.Method == "PUT":
+		s.status.AdminHandler().ServeHTTP(recorder, r)
+	default:
+		s.status.ServeHTTP(recorder, r)
+	}
+	req.Status = recorder.status
+}
+
+// Captures the request and its accepted events.
+func (s *Server) capture(req *Request) {
+	s.Lock()
+	defer s.Unlock()
+	if !s.ignore {
+		s.requests = append(s.requests, *req)
+		s.events = append(s.events, req.Events...)
+	}
+	if s.notify != nil {
+		close(s.notify)
+		s.notify = nil
+	}
+}
+
+// Decompresses the request body according to its Content-Encoding.
+func decompress(r *http.Request, raw []byte) ([]byte, error) {
+	body, err := client.Decompress(r.Header.Get("Content-Encoding"), bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(body)
+}
+
+// ResponseWriter remembering the status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
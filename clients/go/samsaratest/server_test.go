@@ -0,0 +1,183 @@
+package samsaratest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	client "github.com/samsara/samsara/clients/go"
+	"github.com/samsara/samsara/clients/go/ingestion"
+)
+
+func post(t *testing.T, s *Server, header http.Header, body []byte) (int, string) {
+	req, _ := http.NewRequest("POST", s.URL+client.API_PATH, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header.Set(name, values[0])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp.StatusCode, strings.TrimSpace(buf.String())
+}
+
+func TestServer_ReceivesEventsPublishedByClient(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	for _, compression := range []string{"gzip", "none"} {
+		config := client.NewConfig()
+		config.Url = s.URL
+		config.Compression = compression
+		config.StartPublishingThread = false
+		c, err := client.NewClient(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := c.PublishEvents([]client.Event{{"eventName": "test." + compression, "sourceId": "s", "count": 2, "ratio": 0.5}})
+		if !ok || err != nil {
+			t.Errorf("Events compressed by %s should be accepted. Got %v, %v", compression, ok, err)
+		}
+	}
+
+	events := s.Events()
+	if len(events) != 2 || events[0]["eventName"] != "test.gzip" || events[1]["eventName"] != "test.none" {
+		t.Fatalf("Published events should be captured. Got %v", events)
+	}
+	for i, event := range events {
+		if event["count"] != int64(2) || event["ratio"] != 0.5 {
+			t.Errorf("Set #%d. Numbers should be decoded as int64 or float64. Got %#v", i, event)
+		}
+		if _, ok := event["receivedAt"].(int64); !ok {
+			t.Errorf("Set #%d. receivedAt should be injected. Got %#v", i, event)
+		}
+		if _, ok := event["publishedAt"].(int64); !ok {
+			t.Errorf("Set #%d. publishedAt should be injected. Got %#v", i, event)
+		}
+	}
+
+	requests := s.Requests()
+	if len(requests) != 2 || requests[0].Header.Get("Content-Encoding") != "gzip" || requests[0].Body[0] != '[' {
+		t.Errorf("Requests should be captured with decompressed bodies. Got %+v", requests)
+	}
+
+	s.Reset()
+	if len(s.Events()) != 0 || len(s.Requests()) != 0 {
+		t.Error("Captured requests should be forgotten")
+	}
+}
+
+func TestServer_PostEvents(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`[{"eventName":"a","sourceId":"s","timestamp":1}]`))
+	gz.Close()
+
+	published := http.Header{client.PUBLISHED_TIMESTAMP_HEADER: {"2"}}
+	var tests = []struct {
+		header http.Header
+		body   string
+		status int
+		reply  string
+	}{
+		{published, `[{"eventName":"a","sourceId":"s","timestamp":1}]`, 202, ``},
+		{http.Header{}, `[]`, 202, `{"status":"OK","warning":"` + ingestion.MissingHeaderWarning + `"}`},
+		{http.Header{"Content-Encoding": {"gzip"}, client.PUBLISHED_TIMESTAMP_HEADER: {"2"}}, compressed.String(), 202, ``},
+		{published, `[{"eventName":"a","sourceId":"s","timestamp":1},{"eventName":1,"timestamp":1.5}]`, 400,
+			`["OK",{"eventName":"should be string","sourceId":"missing required key","timestamp":"should be integer"}]`},
+		{published, `{"eventName":"a","sourceId":"s","timestamp":1}`, 400, `{"message":"Malformed JSON in request body","status":"ERROR"}`},
+		{published, `[{"eventName":"a","sourceId":"s","timestamp":1}] []`, 400, `{"message":"Malformed JSON in request body","status":"ERROR"}`},
+		{published, `[null]`, 400, `{"message":"Malformed JSON in request body","status":"ERROR"}`},
+		{http.Header{client.PUBLISHED_TIMESTAMP_HEADER: {"now"}}, `[]`, 400,
+			`{"message":"X-Samsara-publishedTimestamp must be a valid timestamp.","status":"ERROR"}`},
+		{http.Header{"Content-Type": {"text/plain"}}, `[]`, 400,
+			`{"message":"Invalid format, content-type must be application/json","status":"ERROR"}`},
+		{http.Header{"Content-Encoding": {"br"}}, `[]`, 400, `{"message":"Unsupported content encoding br","status":"ERROR"}`},
+	}
+
+	for i, test := range tests {
+		status, reply := post(t, s, test.header, []byte(test.body))
+		if status != test.status || reply != test.reply {
+			t.Errorf("Set #%d.\nWant: %d %s\nGot: %d %s", i, test.status, test.reply, status, reply)
+		}
+	}
+
+	events := s.Events()
+	if len(events) != 2 {
+		t.Fatalf("Only accepted events should be captured. Got %v", events)
+	}
+	if events[0]["publishedAt"] != int64(2) || events[0]["timestamp"] != int64(1) {
+		t.Errorf("publishedAt should come from the header. Got %#v", events[0])
+	}
+	if requests := s.Requests(); len(requests) != len(tests) || requests[3].Status != 400 || requests[3].Events != nil {
+		t.Errorf("Rejected requests should be captured. Got %+v", requests)
+	}
+}
+
+func TestServer_PostEvents_KeepsEnrichedFields(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	post(t, s, http.Header{client.PUBLISHED_TIMESTAMP_HEADER: {"2"}},
+		[]byte(`[{"eventName":"a","sourceId":"s","timestamp":1,"receivedAt":3,"publishedAt":4}]`))
+	if event := s.Events()[0]; event["receivedAt"] != int64(3) || event["publishedAt"] != int64(4) {
+		t.Errorf("Fields sent by the client should not be overridden. Got %#v", event)
+	}
+}
+
+func TestServer_ApiStatus(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	var tests = []struct {
+		method string
+		body   string
+		status int
+		reply  string
+	}{
+		{"GET", ``, 200, `{"status":"online"}`},
+		{"PUT", `{"status":"offline"}`, 200, ``},
+		{"GET", ``, 503, `{"status":"offline"}`},
+		{"PUT", `{"status":"sleeping"}`, 400, ``},
+		{"PUT", `{"status":"online"}`, 200, ``},
+		{"GET", ``, 200, `{"status":"online"}`},
+		{"DELETE", ``, 404, `{"message":"Not found","status":"ERROR"}`},
+	}
+
+	for i, test := range tests {
+		req, _ := http.NewRequest(test.method, s.URL+client.STATUS_PATH, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reply bytes.Buffer
+		reply.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status || strings.TrimSpace(reply.String()) != test.reply {
+			t.Errorf("Set #%d.\nWant: %d %s\nGot: %d %s", i, test.status, test.reply, resp.StatusCode, reply.String())
+		}
+	}
+
+	s.SetOnline(false)
+	resp, err := http.Get(s.URL + client.STATUS_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status map[string]string
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || status["status"] != "offline" {
+		t.Errorf("Status should be set offline. Got %d %v", resp.StatusCode, status)
+	}
+}
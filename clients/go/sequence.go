@@ -0,0 +1,209 @@
+package client
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Facets injected into events numbered by the client.
+const (
+	SeqField         = "seq"
+	IncarnationField = "incarnation"
+)
+
+// Sequencer numbers events of each sourceId with a monotonic `seq`
+// starting from 1, within the incarnation of the client.
+type sequencer struct {
+	incarnation string
+	last        map[string]int64
+	sync.Mutex
+}
+
+// Creates new sequencer with a random incarnation id.
+// Returns nil if events should not be numbered.
+func newSequencer(config Config) *sequencer {
+	if !config.SequenceEvents {
+		return nil
+	}
+	return &sequencer{
+		incarnation: newId(),
+		last:        make(map[string]int64),
+	}
+}
+
+// Injects `seq` and `incarnation` facets into the event, unless `seq` is already set.
+func (s *sequencer) number(e Event) {
+	if s == nil {
+		return
+	}
+	if _, ok := e[SeqField]; ok {
+		return
+	}
+	sid, _ := e["sourceId"].(string)
+
+	s.Lock()
+	defer s.Unlock()
+	s.last[sid]++
+	e[SeqField] = s.last[sid]
+	e[IncarnationField] = s.incarnation
+}
+
+// SequenceStatus tells how an event fits into the sequence of its source.
+type SequenceStatus int
+
+// Sequence statuses.
+const (
+	// Event has no sequence number.
+	SeqUnnumbered SequenceStatus = iota
+	// Event is the next one expected.
+	SeqInOrder
+	// Event is ahead of the expected one, the events in between are missing.
+	SeqAhead
+	// Event is one of the missing ones, received out of order.
+	SeqLate
+	// Event with the same sequence number has already been received.
+	SeqDuplicate
+)
+
+// SequenceGap is a range of missing sequence numbers of a source incarnation.
+type SequenceGap struct {
+	SourceId    string
+	Incarnation string
+	// First and last missing sequence number.
+	From, To int64
+}
+
+// Sequence of a source incarnation.
+type sequence struct {
+	next    int64
+	missing []SequenceGap
+}
+
+// SequenceChecker validates sequences of received events.
+// It detects events missing, duplicated or received out of order.
+type SequenceChecker struct {
+	sequences map[[2]string]*sequence
+	sync.Mutex
+}
+
+// NewSequenceChecker creates an empty SequenceChecker.
+func NewSequenceChecker() *SequenceChecker {
+	return &SequenceChecker{sequences: make(map[[2]string]*sequence)}
+}
+
+// Check records the event and tells how it fits into the sequence.
+func (c *SequenceChecker) Check(e Event) SequenceStatus {
+	seq, ok := toSeq(e[SeqField])
+	if !ok {
+		return SeqUnnumbered
+	}
+	sid, _ := e["sourceId"].(string)
+	incarnation, _ := e[IncarnationField].(string)
+
+	c.Lock()
+	defer c.Unlock()
+
+	key := [2]string{sid, incarnation}
+	s, ok := c.sequences[key]
+	if !ok {
+		s = &sequence{next: 1}
+		c.sequences[key] = s
+	}
+
+	switch {
+	case seq == s.next:
+		s.next++
+		return SeqInOrder
+	case seq > s.next:
+		s.missing = append(s.missing, SequenceGap{sid, incarnation, s.next, seq - 1})
+		s.next = seq + 1
+		return SeqAhead
+	}
+
+	for i, gap := range s.missing {
+		if seq < gap.From || seq > gap.To {
+			continue
+		}
+		var split []SequenceGap
+		if seq > gap.From {
+			split = append(split, SequenceGap{sid, incarnation, gap.From, seq - 1})
+		}
+		if seq < gap.To {
+			split = append(split, SequenceGap{sid, incarnation, seq + 1, gap.To})
+		}
+		s.missing = append(s.missing[:i], append(split, s.missing[i+1:]...)...)
+		return SeqLate
+	}
+	return SeqDuplicate
+}
+
+// Missing returns ranges of sequence numbers not received yet,
+// ordered by sourceId, incarnation and sequence number.
+func (c *SequenceChecker) Missing() []SequenceGap {
+	c.Lock()
+	defer c.Unlock()
+
+	var gaps []SequenceGap
+	for _, s := range c.sequences {
+		gaps = append(gaps, s.missing...)
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].SourceId != gaps[j].SourceId {
+			return gaps[i].SourceId < gaps[j].SourceId
+		}
+		if gaps[i].Incarnation != gaps[j].Incarnation {
+			return gaps[i].Incarnation < gaps[j].Incarnation
+		}
+		return gaps[i].From < gaps[j].From
+	})
+	return gaps
+}
+
+// SortBySequence sorts events of each source incarnation by sequence number.
+// Events are grouped by sourceId and incarnation in order of their first appearance,
+// unnumbered events are kept at the end.
+func SortBySequence(events []Event) {
+	groups := make(map[[2]string]int)
+	group := func(e Event) int {
+		if _, ok := toSeq(e[SeqField]); !ok {
+			return len(events)
+		}
+		sid, _ := e["sourceId"].(string)
+		incarnation, _ := e[IncarnationField].(string)
+		key := [2]string{sid, incarnation}
+		if _, ok := groups[key]; !ok {
+			groups[key] = len(groups)
+		}
+		return groups[key]
+	}
+	for _, e := range events {
+		group(e)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		gi, gj := group(events[i]), group(events[j])
+		if gi != gj {
+			return gi < gj
+		}
+		si, _ := toSeq(events[i][SeqField])
+		sj, _ := toSeq(events[j][SeqField])
+		return si < sj
+	})
+}
+
+// Converts sequence number as recorded or decoded from JSON.
+func toSeq(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func seqEvent(sid, incarnation string, seq interface{}) Event {
+	return Event{"sourceId": sid, "incarnation": incarnation, "seq": seq}
+}
+
+func TestSequencer_DisabledByDefault(t *testing.T) {
+	s := newSequencer(NewConfig())
+	event := Event{"sourceId": "dev1"}
+	s.number(event)
+
+	if _, ok := event["seq"]; ok {
+		t.Errorf("Event should not be numbered. Got %+v", event)
+	}
+}
+
+func TestSequencer_NumbersEventsPerSource(t *testing.T) {
+	config := NewConfig()
+	config.SequenceEvents = true
+	s := newSequencer(config)
+
+	events := []Event{
+		{"sourceId": "dev1"},
+		{"sourceId": "dev2"},
+		{"sourceId": "dev1"},
+		{"sourceId": "dev1", "seq": int64(42)},
+		{"sourceId": "dev1"},
+	}
+	for _, e := range events {
+		s.number(e)
+	}
+
+	want := []int64{1, 1, 2, 42, 3}
+	for i, e := range events {
+		if e["seq"] != want[i] {
+			t.Errorf("Set #%d. Want seq: %d Got: %+v", i, want[i], e)
+		}
+	}
+	if events[0]["incarnation"] == nil || events[0]["incarnation"] != events[1]["incarnation"] {
+		t.Errorf("Events should share the incarnation. Got %+v", events)
+	}
+	if _, ok := events[3]["incarnation"]; ok {
+		t.Errorf("Explicitly numbered event should be kept. Got %+v", events[3])
+	}
+	if newSequencer(config).incarnation == s.incarnation {
+		t.Error("Each incarnation should have a unique id")
+	}
+}
+
+func TestSequenceChecker_Check(t *testing.T) {
+	c := NewSequenceChecker()
+
+	sets := []struct {
+		event Event
+		want  SequenceStatus
+	}{
+		{seqEvent("dev1", "a", int64(1)), SeqInOrder},
+		{seqEvent("dev1", "a", int64(2)), SeqInOrder},
+		{seqEvent("dev1", "a", int64(6)), SeqAhead},
+		{seqEvent("dev1", "a", int64(4)), SeqLate},
+		{seqEvent("dev1", "a", int64(4)), SeqDuplicate},
+		{seqEvent("dev1", "a", int64(2)), SeqDuplicate},
+		{seqEvent("dev1", "b", int64(1)), SeqInOrder},
+		{seqEvent("dev2", "a", 1.0), SeqInOrder},
+		{seqEvent("dev2", "a", json.Number("3")), SeqAhead},
+		{seqEvent("dev2", "a", 1.5), SeqUnnumbered},
+		{Event{"sourceId": "dev2"}, SeqUnnumbered},
+	}
+
+	for i, set := range sets {
+		if got := c.Check(set.event); got != set.want {
+			t.Errorf("Set #%d. Want: %d Got: %d", i, set.want, got)
+		}
+	}
+
+	want := []SequenceGap{
+		{"dev1", "a", 3, 3},
+		{"dev1", "a", 5, 5},
+		{"dev2", "a", 2, 2},
+	}
+	if got := c.Missing(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect gaps.\nWant: %+v\nGot: %+v", want, got)
+	}
+}
+
+func TestSortBySequence(t *testing.T) {
+	events := []Event{
+		seqEvent("dev1", "a", int64(2)),
+		{"sourceId": "dev1", "eventName": "unnumbered"},
+		seqEvent("dev2", "a", int64(2)),
+		seqEvent("dev1", "a", int64(1)),
+		seqEvent("dev2", "a", int64(1)),
+		seqEvent("dev1", "b", int64(1)),
+	}
+	SortBySequence(events)
+
+	want := []Event{
+		seqEvent("dev1", "a", int64(1)),
+		seqEvent("dev1", "a", int64(2)),
+		seqEvent("dev2", "a", int64(1)),
+		seqEvent("dev2", "a", int64(2)),
+		seqEvent("dev1", "b", int64(1)),
+		{"sourceId": "dev1", "eventName": "unnumbered"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Incorrect order.\nWant: %+v\nGot: %+v", want, events)
+	}
+}
+
+func TestClient_RecordEvent_NumbersKeptEvents(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	config.SequenceEvents = true
+	config.Sampling = []SamplingRule{{EventName: "debug", Mode: SampleNever}}
+	client, _ := NewClient(config)
+
+	client.RecordEvent(Event{"eventName": "a"})
+	client.RecordEvent(Event{"eventName": "debug"})
+	client.RecordEvent(Event{"eventName": "b"})
+
+	out := client.queue.Flush()
+	checker := NewSequenceChecker()
+	for i, e := range out {
+		if status := checker.Check(e); status != SeqInOrder {
+			t.Errorf("Set #%d. Kept events should be numbered in order. Got %+v", i, e)
+		}
+	}
+	if len(out) != 2 || out[1]["seq"] != int64(2) {
+		t.Errorf("Sampled out events should not be numbered. Got %+v", out)
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Names of events emitted on session boundaries.
+const (
+	SessionStartedEvent = "session.started"
+	SessionStoppedEvent = "session.stopped"
+)
+
+// Session of a single sourceId.
+type session struct {
+	sourceId string
+	id       string
+	lastTs   int64
+}
+
+// Sessionizer assigns session ids to events of each sourceId,
+// rotating them after the inactivity gap.
+// Sessions are kept in LRU order, the least recently active at the back.
+type sessionizer struct {
+	gap      int64
+	capacity int
+	emit     bool
+	sessions map[string]*list.Element
+	lru      *list.List
+	sync.Mutex
+}
+
+// Creates new sessionizer. Returns nil if sessionization is disabled.
+func newSessionizer(config Config) *sessionizer {
+	if config.SessionInactivityGap == 0 {
+		return nil
+	}
+	return &sessionizer{
+		gap:      int64(config.SessionInactivityGap),
+		capacity: config.SessionMaxSources,
+		emit:     config.EmitSessionEvents,
+		sessions: make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Injects `sessionId` facet into the event, unless it is already set.
+// Returns session boundary events to be recorded before the event, if enabled.
+func (s *sessionizer) sessionize(e Event) []Event {
+	if s == nil {
+		return nil
+	}
+	if _, ok := e["sessionId"]; ok {
+		return nil
+	}
+
+	sid, _ := e["sourceId"].(string)
+	ts, _ := e["timestamp"].(int64)
+
+	s.Lock()
+	defer s.Unlock()
+
+	var boundaries []Event
+	elem, ok := s.sessions[sid]
+	if ok {
+		current := elem.Value.(*session)
+		if ts-current.lastTs > s.gap {
+			boundaries = append(boundaries, s.stopped(current))
+			s.lru.Remove(elem)
+			delete(s.sessions, sid)
+			ok = false
+		}
+	}
+
+	// expire inactive and evict exceeding sessions
+	for back := s.lru.Back(); back != nil; back = s.lru.Back() {
+		oldest := back.Value.(*session)
+		if ts-oldest.lastTs <= s.gap && (ok || s.lru.Len() < s.capacity) {
+			break
+		}
+		boundaries = append(boundaries, s.stopped(oldest))
+		s.lru.Remove(back)
+		delete(s.sessions, oldest.sourceId)
+	}
+
+	if ok {
+		current := elem.Value.(*session)
+		if ts > current.lastTs {
+			current.lastTs = ts
+		}
+		s.lru.MoveToFront(elem)
+		e["sessionId"] = current.id
+	} else {
+		current := &session{sourceId: sid, id: newId(), lastTs: ts}
+		s.sessions[sid] = s.lru.PushFront(current)
+		boundaries = append(boundaries, s.boundary(SessionStartedEvent, current, ts))
+		e["sessionId"] = current.id
+	}
+
+	if !s.emit {
+		return nil
+	}
+	return boundaries
+}
+
+// Creates `session.stopped` event for the given session.
+func (s *sessionizer) stopped(current *session) Event {
+	return s.boundary(SessionStoppedEvent, current, current.lastTs)
+}
+
+// Creates session boundary event.
+func (s *sessionizer) boundary(name string, current *session, ts int64) Event {
+	return Event{
+		"eventName": name,
+		"sourceId":  current.sourceId,
+		"timestamp": ts,
+		"sessionId": current.id,
+		"inferred":  true,
+	}
+}
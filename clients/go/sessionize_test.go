@@ -0,0 +1,156 @@
+package client
+
+import (
+	"testing"
+)
+
+func newTestSessionizer(gap uint32, maxSources int, emit bool) *sessionizer {
+	config := NewConfig()
+	config.SessionInactivityGap = gap
+	config.SessionMaxSources = maxSources
+	config.EmitSessionEvents = emit
+	return newSessionizer(config)
+}
+
+func sessionEvent(sid string, ts int64) Event {
+	return Event{"eventName": "web.page.viewed", "sourceId": sid, "timestamp": ts}
+}
+
+func TestSessionizer_DisabledByDefault(t *testing.T) {
+	s := newSessionizer(NewConfig())
+	event := sessionEvent("dev1", 1)
+
+	if boundaries := s.sessionize(event); boundaries != nil {
+		t.Errorf("No events should be generated. Got %+v", boundaries)
+	}
+	if _, ok := event["sessionId"]; ok {
+		t.Errorf("sessionId should not be injected. Got %+v", event)
+	}
+}
+
+func TestSessionizer_RotatesSessionAfterInactivityGap(t *testing.T) {
+	s := newTestSessionizer(20, 10, false)
+
+	events := []Event{
+		sessionEvent("dev1", 1),
+		sessionEvent("dev1", 10),
+		sessionEvent("dev1", 30),
+		sessionEvent("dev1", 51),
+		sessionEvent("dev2", 52),
+	}
+	for _, e := range events {
+		if boundaries := s.sessionize(e); boundaries != nil {
+			t.Errorf("Boundary events should not be emitted. Got %+v", boundaries)
+		}
+	}
+
+	if events[0]["sessionId"] == nil ||
+		events[0]["sessionId"] != events[1]["sessionId"] ||
+		events[1]["sessionId"] != events[2]["sessionId"] {
+		t.Errorf("Events within inactivity gap should share the session. Got %+v", events)
+	}
+	if events[3]["sessionId"] == events[2]["sessionId"] {
+		t.Errorf("Session should be rotated after inactivity gap. Got %+v", events)
+	}
+	if events[4]["sessionId"] == events[3]["sessionId"] {
+		t.Errorf("Sessions should be tracked per sourceId. Got %+v", events)
+	}
+}
+
+func TestSessionizer_PreservesExplicitSessionId(t *testing.T) {
+	s := newTestSessionizer(20, 10, true)
+	event := Event{"eventName": "foo", "sourceId": "dev1", "timestamp": int64(1), "sessionId": "mine"}
+
+	if boundaries := s.sessionize(event); boundaries != nil {
+		t.Errorf("No events should be generated. Got %+v", boundaries)
+	}
+	if event["sessionId"] != "mine" {
+		t.Errorf("Explicit sessionId should be preserved. Got %+v", event)
+	}
+}
+
+func TestSessionizer_EmitsBoundaryEvents(t *testing.T) {
+	s := newTestSessionizer(20, 10, true)
+
+	first := sessionEvent("dev1", 1)
+	boundaries := s.sessionize(first)
+	if len(boundaries) != 1 || boundaries[0]["eventName"] != SessionStartedEvent ||
+		boundaries[0]["sessionId"] != first["sessionId"] || boundaries[0]["timestamp"] != int64(1) {
+		t.Errorf("session.started should be emitted. Got %+v", boundaries)
+	}
+
+	if boundaries := s.sessionize(sessionEvent("dev1", 15)); len(boundaries) != 0 {
+		t.Errorf("No events should be emitted within session. Got %+v", boundaries)
+	}
+
+	next := sessionEvent("dev1", 100)
+	boundaries = s.sessionize(next)
+	if len(boundaries) != 2 {
+		t.Fatalf("session.stopped and session.started should be emitted. Got %+v", boundaries)
+	}
+	if boundaries[0]["eventName"] != SessionStoppedEvent || boundaries[0]["sessionId"] != first["sessionId"] ||
+		boundaries[0]["timestamp"] != int64(15) {
+		t.Errorf("Incorrect session.stopped event. Got %+v", boundaries[0])
+	}
+	if boundaries[1]["eventName"] != SessionStartedEvent || boundaries[1]["sessionId"] != next["sessionId"] {
+		t.Errorf("Incorrect session.started event. Got %+v", boundaries[1])
+	}
+}
+
+func TestSessionizer_KeepsBoundedMemory(t *testing.T) {
+	s := newTestSessionizer(1000, 2, true)
+
+	s.sessionize(sessionEvent("dev1", 1))
+	s.sessionize(sessionEvent("dev2", 2))
+	s.sessionize(sessionEvent("dev1", 3))
+	boundaries := s.sessionize(sessionEvent("dev3", 4))
+
+	if s.lru.Len() != 2 || len(s.sessions) != 2 {
+		t.Errorf("Only 2 sessions should be tracked. Got %d", s.lru.Len())
+	}
+	if _, ok := s.sessions["dev2"]; ok {
+		t.Error("Least recently active session should be evicted")
+	}
+	if len(boundaries) != 2 || boundaries[0]["eventName"] != SessionStoppedEvent || boundaries[0]["sourceId"] != "dev2" {
+		t.Errorf("Evicted session should be stopped. Got %+v", boundaries)
+	}
+}
+
+func TestSessionizer_ExpiresInactiveSessionsOfOtherSources(t *testing.T) {
+	s := newTestSessionizer(20, 10, true)
+
+	s.sessionize(sessionEvent("dev1", 1))
+	boundaries := s.sessionize(sessionEvent("dev2", 50))
+
+	if len(boundaries) != 2 || boundaries[0]["sourceId"] != "dev1" || boundaries[0]["eventName"] != SessionStoppedEvent {
+		t.Errorf("Inactive session of other source should be stopped. Got %+v", boundaries)
+	}
+	if s.lru.Len() != 1 {
+		t.Errorf("Only active session should be tracked. Got %d", s.lru.Len())
+	}
+}
+
+func TestClient_RecordEvent_RecordsSessionEventsBeforeTheEvent(t *testing.T) {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	config.SessionInactivityGap = 60000
+	config.EmitSessionEvents = true
+	config.Enrichers = []Enricher{AppEnricher("web", "1.0")}
+	client, _ := NewClient(config)
+
+	client.RecordEvent(Event{"eventName": "web.page.viewed"})
+	client.RecordEvent(Event{"eventName": "web.page.viewed"})
+
+	out := client.queue.Flush()
+	if len(out) != 3 {
+		t.Fatalf("session.started and 2 events should be recorded. Got %+v", out)
+	}
+	if out[0]["eventName"] != SessionStartedEvent || out[0]["appName"] != "web" {
+		t.Errorf("Enriched session.started should be recorded first. Got %+v", out[0])
+	}
+	if out[1]["sessionId"] != out[0]["sessionId"] || out[2]["sessionId"] != out[0]["sessionId"] {
+		t.Errorf("Events should share the session. Got %+v", out)
+	}
+}
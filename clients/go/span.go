@@ -0,0 +1,127 @@
+package client
+
+import (
+	"sync"
+)
+
+// Span status values recorded in the `.stopped` event.
+const (
+	SpanStatusOk     = "ok"
+	SpanStatusFailed = "failed"
+)
+
+// Span is an activity delimited by `<name>.started` and `<name>.stopped` events,
+// as expected by the session-boundaries module.
+type Span struct {
+	client  *Client
+	name    string
+	id      string
+	start   Event
+	started bool
+	stopped bool
+	sync.Mutex
+}
+
+// StartSpan records `<name>.started` event with the given facets and a generated id.
+// The returned Span records the matching `<name>.stopped` event when stopped.
+func (c *Client) StartSpan(name string, facets Event) (*Span, error) {
+	span := &Span{
+		client: c,
+		name:   name,
+		id:     newId(),
+	}
+
+	span.start = span.event(".started", span.id, facets)
+	started, err := c.record(span.start)
+	if err != nil {
+		return nil, err
+	}
+	span.started = started
+	return span, nil
+}
+
+// Id returns the identifier of the span, shared by its events as `spanId`.
+func (s *Span) Id() string {
+	return s.id
+}
+
+// Stop records `<name>.stopped` event with the given facets.
+func (s *Span) Stop(facets Event) error {
+	return s.stop(SpanStatusOk, facets, nil)
+}
+
+// Fail records `<name>.stopped` event with the given facets and the error message.
+func (s *Span) Fail(err error, facets Event) error {
+	return s.stop(SpanStatusFailed, facets, err)
+}
+
+// Records the `.stopped` event and optionally the merged `.done` one,
+// unless any of the merged events has been dropped, e.g. by sampling.
+func (s *Span) stop(status string, facets Event, cause error) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopped {
+		return SpanError{"Span '" + s.name + "' has already been stopped."}
+	}
+
+	stop := s.event(".stopped", newId(), facets)
+	stop["status"] = status
+	if cause != nil {
+		stop["error"] = cause.Error()
+	}
+	kept, err := s.client.record(stop)
+	if err != nil {
+		return err
+	}
+	s.stopped = true
+
+	if s.client.config.EmitSpanDone && s.started && kept {
+		done := mergeSessionEvents(s.name+".done", s.start, stop)
+		_, err := s.client.record(done, "startTs", "stopTs", "duration")
+		return err
+	}
+	return nil
+}
+
+// Creates a new span event with the given name suffix and id.
+func (s *Span) event(suffix, id string, facets Event) Event {
+	event := Event{}
+	for k, v := range facets {
+		event[k] = v
+	}
+	event["eventName"] = s.name + suffix
+	event["id"] = id
+	event["spanId"] = s.id
+	return event
+}
+
+// Merges the session events and injects the duration in milliseconds.
+// It produces the same fields as the session-boundaries module.
+// Facets the client injects into each event, e.g. `seq`, aren't merged,
+// so that the merged event gets its own.
+func mergeSessionEvents(name string, start, stop Event) Event {
+	merged := Event{}
+	for k, v := range start {
+		merged[k] = v
+	}
+	for k, v := range stop {
+		merged[k] = v
+	}
+	delete(merged, "id")
+	delete(merged, SeqField)
+	delete(merged, IncarnationField)
+	delete(merged, SampleRateField)
+
+	ts1, _ := start["timestamp"].(int64)
+	ts2, _ := stop["timestamp"].(int64)
+	merged["startTs"] = ts1
+	merged["stopTs"] = ts2
+	merged["startEventId"] = start["id"]
+	merged["stopEventId"] = stop["id"]
+	merged["timestamp"] = ts1
+	merged["duration"] = ts2 - ts1
+	merged["eventName"] = name
+	merged["inferred"] = true
+	return merged
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newSpanTestClient(emitDone bool) *Client {
+	config := NewConfig()
+	config.Url = "http://test.com"
+	config.SourceId = "dev1"
+	config.StartPublishingThread = false
+	config.EmitSpanDone = emitDone
+	client, _ := NewClient(config)
+	return client
+}
+
+func TestClient_StartSpan_RecordsStartedAndStoppedEvents(t *testing.T) {
+	client := newSpanTestClient(false)
+
+	span, err := client.StartSpan("game.play", Event{"level": 1})
+	if err != nil {
+		t.Fatalf("Span should be started. Got %v", err)
+	}
+	if err := span.Stop(Event{"points": 650}); err != nil {
+		t.Fatalf("Span should be stopped. Got %v", err)
+	}
+
+	out := client.queue.Flush()
+	if len(out) != 2 {
+		t.Fatalf("Started and stopped events should be recorded. Got %+v", out)
+	}
+	started, stopped := out[0], out[1]
+	if started["eventName"] != "game.play.started" || started["level"] != 1 ||
+		started["id"] != span.Id() || started["spanId"] != span.Id() {
+		t.Errorf("Incorrect started event. Got %+v", started)
+	}
+	if stopped["eventName"] != "game.play.stopped" || stopped["points"] != 650 ||
+		stopped["status"] != SpanStatusOk || stopped["spanId"] != span.Id() {
+		t.Errorf("Incorrect stopped event. Got %+v", stopped)
+	}
+	if stopped["id"] == started["id"] {
+		t.Errorf("Each event should have its own id. Got %+v", out)
+	}
+	if _, ok := stopped["level"]; ok {
+		t.Errorf("Started facets should not be copied to the stopped event. Got %+v", stopped)
+	}
+}
+
+func TestSpan_Fail_RecordsErrorAndCanNotBeStoppedTwice(t *testing.T) {
+	client := newSpanTestClient(false)
+
+	span, _ := client.StartSpan("download", nil)
+	span.Fail(errors.New("connection reset"), nil)
+
+	if err := span.Stop(nil); err == nil {
+		t.Error("Stopping span twice should raise an error")
+	}
+
+	out := client.queue.Flush()
+	if len(out) != 2 {
+		t.Fatalf("Only started and one stopped event should be recorded. Got %+v", out)
+	}
+	if out[1]["status"] != SpanStatusFailed || out[1]["error"] != "connection reset" {
+		t.Errorf("Failure should be recorded. Got %+v", out[1])
+	}
+}
+
+func TestClient_StartSpan_ValidatesEvent(t *testing.T) {
+	client := newSpanTestClient(false)
+
+	span, err := client.StartSpan("download", Event{"timestamp": "now"})
+	if err == nil || span != nil {
+		t.Errorf("Span with invalid event should not be started. Got %+v", span)
+	}
+}
+
+func TestSpan_Stop_EmitsDoneEventWhenConfigured(t *testing.T) {
+	client := newSpanTestClient(true)
+
+	span, _ := client.StartSpan("game.play", Event{"level": 1, "points": 1})
+	span.Stop(Event{"points": 10})
+
+	out := client.queue.Flush()
+	if len(out) != 3 {
+		t.Fatalf("Started, stopped and done events should be recorded. Got %+v", out)
+	}
+	done := out[2]
+	ts1, ts2 := out[0]["timestamp"].(int64), out[1]["timestamp"].(int64)
+	want := Event{
+		"eventName":    "game.play.done",
+		"sourceId":     "dev1",
+		"timestamp":    ts1,
+		"startTs":      ts1,
+		"stopTs":       ts2,
+		"duration":     ts2 - ts1,
+		"startEventId": out[0]["id"],
+		"stopEventId":  out[1]["id"],
+		"spanId":       span.Id(),
+		"status":       SpanStatusOk,
+		"inferred":     true,
+		"level":        1,
+		"points":       10,
+	}
+	if !reflect.DeepEqual(done, want) {
+		t.Errorf("Incorrect done event.\nWant: %+v\nGot: %+v", want, done)
+	}
+}
+
+func TestSpan_Stop_SkipsDoneEventWhenStartOrStopIsDropped(t *testing.T) {
+	var tests = []struct {
+		dropped string
+		want    []string
+	}{
+		{"game.play.started", []string{"game.play.stopped"}},
+		{"game.play.stopped", []string{"game.play.started"}},
+	}
+
+	for i, test := range tests {
+		config := NewConfig()
+		config.Url = "http://test.com"
+		config.SourceId = "dev1"
+		config.StartPublishingThread = false
+		config.EmitSpanDone = true
+		config.Sampling = []SamplingRule{{EventName: test.dropped, Mode: SampleNever}}
+		client, _ := NewClient(config)
+
+		span, _ := client.StartSpan("game.play", nil)
+		if err := span.Stop(nil); err != nil {
+			t.Errorf("Set #%d. Span should be stopped. Got %v", i, err)
+		}
+
+		var got []string
+		for _, e := range client.queue.Flush() {
+			got = append(got, e["eventName"].(string))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Set #%d. Want %v, Got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestMergeSessionEvents(t *testing.T) {
+	start := Event{"eventName": "game.play.started", "timestamp": int64(1), "sourceId": "dev1", "id": "a", "level": 1, "points": 650}
+	stop := Event{"eventName": "game.play.stopped", "timestamp": int64(10), "sourceId": "dev1", "id": "b", "points": 23456}
+
+	want := Event{
+		"eventName":    "game.play.done",
+		"timestamp":    int64(1),
+		"sourceId":     "dev1",
+		"inferred":     true,
+		"startTs":      int64(1),
+		"stopTs":       int64(10),
+		"duration":     int64(9),
+		"startEventId": "a",
+		"stopEventId":  "b",
+		"level":        1,
+		"points":       23456,
+	}
+	if got := mergeSessionEvents("game.play.done", start, stop); !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect merged event.\nWant: %+v\nGot: %+v", want, got)
+	}
+}
+
+func TestSpan_Stop_NumbersDoneEventInSequence(t *testing.T) {
+	client := newSpanTestClient(true)
+	client.config.SequenceEvents = true
+	client.sequencer = newSequencer(client.config)
+
+	span, _ := client.StartSpan("game.play", Event{})
+	span.Stop(Event{})
+
+	checker := NewSequenceChecker()
+	for i, event := range client.queue.Flush() {
+		if status := checker.Check(event); status != SeqInOrder {
+			t.Errorf("Event #%d should be in order. Got %v for %+v", i, status, event)
+		}
+	}
+}
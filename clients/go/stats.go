@@ -0,0 +1,44 @@
+package client
+
+import "sync/atomic"
+
+// Stats contains counters of the client activity.
+type Stats struct {
+	// Number of events in the buffer waiting to be published.
+	Buffered int64
+
+	// Number of published events.
+	Published uint64
+
+	// Number of events in failed posts. Failed buffered events
+	// are published again later, so they may be counted repeatedly.
+	Failed uint64
+
+	// Number of duplicate events dropped.
+	Duplicates uint64
+
+	// Number of events dropped by rate limits.
+	RateLimited uint64
+
+	// Number of metric summary events which couldn't be recorded.
+	// Their aggregates are kept for the next flush.
+	MetricErrors uint64
+
+	// Estimated offset of the local clock from the server clock
+	// in milliseconds, 0 if not estimated yet.
+	ClockOffset int64
+}
+
+// Stats returns current counters of the client activity.
+func (c *Client) Stats() Stats {
+	clockOffset, _ := c.clock.offset()
+	return Stats{
+		Buffered:     c.queue.Count(),
+		Published:    atomic.LoadUint64(&c.published),
+		Failed:       atomic.LoadUint64(&c.failed),
+		Duplicates:   c.deduplicator.droppedCount(),
+		RateLimited:  c.limiter.droppedCount(),
+		MetricErrors: atomic.LoadUint64(&c.metricErrors),
+		ClockOffset:  clockOffset,
+	}
+}
@@ -0,0 +1,123 @@
+package syslog
+
+import (
+	"strings"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Rule of converting matching messages into events.
+type Rule struct {
+	// Glob matched against app-name, empty matches any.
+	// See client.MatchGlob for allowed globs.
+	AppName string
+
+	// Glob matched against msgid, empty matches any.
+	MsgId string
+
+	// Should matching messages be dropped?
+	Drop bool
+
+	// Template of the event name, with placeholders {app}, {msgid},
+	// {host}, {facility} and {severity}.
+	// default = "" (see Mapper)
+	EventName string
+
+	// Facets added to the event, unless it already has them.
+	Facets map[string]interface{}
+}
+
+// Mapper converts syslog messages into events. The first rule matching
+// a message is applied. By default, the eventName is "<app-name>.<msgid>",
+// "<app-name>" without msgid, or "syslog" without app-name, and the sourceId
+// is the hostname, or the sender address if the message has none.
+// The timestamp comes from the header, or is the time of receipt if the
+// message has none. The other fields are added as
+// `severity`, `facility`, `appName`, `procId`, `msgId` and `message` facets.
+// Structured data parameters are added as `<SD-ID>.<name>` facets.
+type Mapper struct {
+	Rules []Rule
+}
+
+// Event converts the message received from the sender into an event.
+// Returns false if the message should be dropped.
+func (m Mapper) Event(msg *Message, sender string) (client.Event, bool) {
+	rule := m.match(msg)
+	if rule != nil && rule.Drop {
+		return nil, false
+	}
+
+	event := client.Event{
+		"severity": SeverityName(msg.Severity),
+		"facility": FacilityName(msg.Facility),
+		"message":  msg.Message,
+	}
+	for id, params := range msg.StructuredData {
+		for name, value := range params {
+			event[id+"."+name] = value
+		}
+	}
+	optional := map[string]string{"appName": msg.AppName, "procId": msg.ProcId, "msgId": msg.MsgId}
+	for name, value := range optional {
+		if value != "" {
+			event[name] = value
+		}
+	}
+
+	event["eventName"] = defaultEventName(msg)
+	if rule != nil && rule.EventName != "" {
+		event["eventName"] = expand(rule.EventName, msg)
+	}
+	if msg.Hostname != "" {
+		event["sourceId"] = msg.Hostname
+	} else if sender != "" {
+		event["sourceId"] = sender
+	}
+	if msg.Timestamp.IsZero() {
+		event["timestamp"] = client.Timestamp()
+	} else {
+		event["timestamp"] = msg.Timestamp.UnixNano() / 1000000
+	}
+
+	if rule != nil {
+		for name, value := range rule.Facets {
+			if _, ok := event[name]; !ok {
+				event[name] = value
+			}
+		}
+	}
+	return event, true
+}
+
+// Returns the first rule matching the message.
+func (m Mapper) match(msg *Message) *Rule {
+	for i, rule := range m.Rules {
+		if (rule.AppName == "" || client.MatchGlob(rule.AppName, msg.AppName)) &&
+			(rule.MsgId == "" || client.MatchGlob(rule.MsgId, msg.MsgId)) {
+			return &m.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Returns the default event name of the message.
+func defaultEventName(msg *Message) string {
+	switch {
+	case msg.AppName == "":
+		return "syslog"
+	case msg.MsgId == "":
+		return msg.AppName
+	}
+	return msg.AppName + "." + msg.MsgId
+}
+
+// Replaces placeholders in the template by fields of the message.
+func expand(template string, msg *Message) string {
+	return strings.NewReplacer(
+		"{app}", msg.AppName,
+		"{msgid}", msg.MsgId,
+		"{host}", msg.Hostname,
+		"{facility}", FacilityName(msg.Facility),
+		"{severity}", SeverityName(msg.Severity),
+	).Replace(template)
+}
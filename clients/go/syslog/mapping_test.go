@@ -0,0 +1,107 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+func TestMapper_Event_Samples(t *testing.T) {
+	samples := readSamples(t)
+	var tests = []struct {
+		sample int
+		want   client.Event
+	}{
+		{0, client.Event{
+			"eventName": "su.ID47", "sourceId": "mymachine.example.com", "timestamp": int64(1065910455003),
+			"severity": "crit", "facility": "auth", "appName": "su", "msgId": "ID47",
+			"message": "su root failed for lonvick on /dev/pts/8",
+		}},
+		{2, client.Event{
+			"eventName": "evntslog.ID47", "sourceId": "mymachine.example.com", "timestamp": int64(1065910455003),
+			"severity": "notice", "facility": "local4", "appName": "evntslog", "msgId": "ID47",
+			"message":                       "An application event log entry...",
+			"exampleSDID@32473.iut":         "3",
+			"exampleSDID@32473.eventSource": "Application",
+			"exampleSDID@32473.eventID":     "1011",
+			"examplePriority@32473.class":   "high",
+		}},
+		{7, client.Event{
+			"eventName": "syslog", "sourceId": "192.0.2.7", "timestamp": int64(0),
+			"severity": "notice", "facility": "user", "message": "Use the BFG!",
+		}},
+	}
+
+	for i, test := range tests {
+		msg, err := Parse(samples[test.sample])
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, ok := Mapper{}.Event(msg, "192.0.2.7")
+		if !ok {
+			t.Errorf("Set #%d. Event should not be dropped", i)
+		}
+		if err := event.Validate(); err != nil {
+			t.Errorf("Set #%d. Event should be valid. Got %v", i, err)
+		}
+		if test.want["timestamp"] == int64(0) {
+			// received time of messages without timestamp
+			event["timestamp"] = int64(0)
+		}
+		if !reflect.DeepEqual(event, test.want) {
+			t.Errorf("Set #%d.\nWant: %#v\nGot: %#v", i, test.want, event)
+		}
+	}
+}
+
+func TestMapper_Event_Rules(t *testing.T) {
+	mapper := Mapper{Rules: []Rule{
+		{AppName: "CRON", Drop: true},
+		{AppName: "sshd", EventName: "auth.{app}.{severity}", Facets: map[string]interface{}{"team": "infra", "message": "x"}},
+		{AppName: "fw*", MsgId: "BLOCK", EventName: "firewall.{msgid}@{host}"},
+		{MsgId: "ID*", EventName: "{facility}"},
+	}}
+
+	var tests = []struct {
+		msg     Message
+		dropped bool
+		name    string
+		facets  map[string]interface{}
+	}{
+		{Message{AppName: "CRON"}, true, "", nil},
+		{Message{AppName: "sshd", Severity: 6, Message: "hi"}, false, "auth.sshd.info", map[string]interface{}{"team": "infra", "message": "hi"}},
+		{Message{AppName: "fw01", MsgId: "BLOCK", Hostname: "h"}, false, "firewall.BLOCK@h", nil},
+		{Message{AppName: "fw01", MsgId: "ALLOW"}, false, "fw01.ALLOW", nil},
+		{Message{AppName: "app", MsgId: "ID47", Facility: 16}, false, "local0", nil},
+		{Message{AppName: "app"}, false, "app", nil},
+	}
+
+	for i, test := range tests {
+		event, ok := mapper.Event(&test.msg, "sender")
+		if ok == test.dropped {
+			t.Errorf("Set #%d. Want dropped %v, Got %v", i, test.dropped, !ok)
+			continue
+		}
+		if test.dropped {
+			continue
+		}
+		if event["eventName"] != test.name {
+			t.Errorf("Set #%d. Want eventName %q, Got %q", i, test.name, event["eventName"])
+		}
+		for name, value := range test.facets {
+			if event[name] != value {
+				t.Errorf("Set #%d. Want %s=%v, Got %v", i, name, value, event[name])
+			}
+		}
+	}
+}
+
+func TestMapper_Event_Timestamp(t *testing.T) {
+	ts := time.Date(2020, 5, 17, 10, 0, 0, 999999999, time.UTC)
+	event, _ := Mapper{}.Event(&Message{Timestamp: ts, Hostname: "h"}, "sender")
+	if event["timestamp"] != ts.UnixNano()/1000000 || event["sourceId"] != "h" {
+		t.Errorf("Incorrect event. Got %#v", event)
+	}
+}
@@ -0,0 +1,248 @@
+// Package syslog converts syslog messages into Samsara events.
+// Both RFC 5424 and legacy BSD (RFC 3164) messages are supported,
+// received over UDP or TCP.
+package syslog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of severities by their code.
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Names of facilities by their code.
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Priority of messages without one, as defined by RFC 3164.
+const defaultPriority = 13
+
+// Message is a parsed syslog message. Missing fields have zero values.
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcId    string
+	MsgId     string
+
+	// Parameters of structured data elements by their SD-ID.
+	StructuredData map[string]map[string]string
+
+	Message string
+}
+
+// ParseError is an error of a malformed syslog message.
+type ParseError struct {
+	Message string
+}
+
+// Error returns error message.
+func (e ParseError) Error() string {
+	return e.Message
+}
+
+// SeverityName returns the keyword of the severity, e.g. "err".
+func SeverityName(severity int) string {
+	if severity < 0 || severity >= len(severities) {
+		return strconv.Itoa(severity)
+	}
+	return severities[severity]
+}
+
+// FacilityName returns the keyword of the facility, e.g. "local0".
+func FacilityName(facility int) string {
+	if facility < 0 || facility >= len(facilities) {
+		return strconv.Itoa(facility)
+	}
+	return facilities[facility]
+}
+
+// Parse parses RFC 5424 or RFC 3164 message. Legacy messages lack the year
+// and the time zone, they are assumed to be local and at most a day ahead.
+func Parse(data []byte) (*Message, error) {
+	return parse(data, time.Now())
+}
+
+// Parses the message received at the given time.
+func parse(data []byte, now time.Time) (*Message, error) {
+	line := string(bytes.TrimRight(data, "\r\n\x00"))
+	if line == "" {
+		return nil, ParseError{"empty message"}
+	}
+	pri, rest, err := parsePriority(line)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Message{Facility: pri / 8, Severity: pri % 8}
+	if strings.HasPrefix(rest, "1 ") {
+		return m, m.parse5424(rest[2:])
+	}
+	m.parse3164(rest, now)
+	return m, nil
+}
+
+// Parses <PRI> at the beginning of the line.
+func parsePriority(line string) (int, string, error) {
+	if line[0] != '<' {
+		return defaultPriority, line, nil
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", ParseError{"malformed priority"}
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", ParseError{"malformed priority"}
+	}
+	return pri, line[end+1:], nil
+}
+
+// Parses RFC 5424 header, structured data and message after the version.
+func (m *Message) parse5424(rest string) error {
+	var header [5]string
+	for i := range header {
+		end := strings.IndexByte(rest, ' ')
+		if end <= 0 {
+			return ParseError{"incomplete header"}
+		}
+		header[i], rest = nilValue(rest[:end]), rest[end+1:]
+	}
+
+	if header[0] != "" {
+		ts, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return ParseError{"malformed timestamp: " + header[0]}
+		}
+		m.Timestamp = ts
+	}
+	m.Hostname, m.AppName, m.ProcId, m.MsgId = header[1], header[2], header[3], header[4]
+
+	rest, err := m.parseStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	if rest != "" {
+		if rest[0] != ' ' {
+			return ParseError{"malformed structured data"}
+		}
+		m.Message = strings.TrimPrefix(rest[1:], "\ufeff")
+	}
+	return nil
+}
+
+// Parses structured data elements, returns the rest of the line.
+func (m *Message) parseStructuredData(rest string) (string, error) {
+	if strings.HasPrefix(rest, "-") {
+		return rest[1:], nil
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return "", ParseError{"malformed structured data"}
+	}
+
+	m.StructuredData = make(map[string]map[string]string)
+	for strings.HasPrefix(rest, "[") {
+		end := strings.IndexAny(rest, " ]")
+		if end < 2 {
+			return "", ParseError{"malformed structured data element"}
+		}
+		params := make(map[string]string)
+		m.StructuredData[rest[1:end]] = params
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, " ") {
+			eq := strings.Index(rest, "=\"")
+			if eq < 2 {
+				return "", ParseError{"malformed structured data parameter"}
+			}
+			name := rest[1:eq]
+			value, n, ok := unescapeParam(rest[eq+2:])
+			if !ok {
+				return "", ParseError{"unterminated structured data parameter " + name}
+			}
+			params[name] = value
+			rest = rest[eq+2+n:]
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return "", ParseError{"unterminated structured data element"}
+		}
+		rest = rest[1:]
+	}
+	return rest, nil
+}
+
+// Reads a parameter value up to the closing quote, unescaping \", \\ and \].
+// Returns the value and the length including the quote.
+func unescapeParam(s string) (string, int, bool) {
+	var value strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), i + 1, true
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+				i++
+			}
+		}
+		value.WriteByte(s[i])
+	}
+	return "", 0, false
+}
+
+// Parses RFC 3164 `Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG`.
+// Parts which don't conform are left in the message.
+func (m *Message) parse3164(rest string, now time.Time) {
+	if len(rest) >= len(time.Stamp)+1 && rest[len(time.Stamp)] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], now.Location()); err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.AddDate(0, 0, 1)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = ts
+			rest = rest[len(time.Stamp)+1:]
+
+			// devices may omit the hostname before the tag
+			if end := strings.IndexByte(rest, ' '); end > 0 && !isTag(rest[:end]) {
+				m.Hostname, rest = rest[:end], rest[end+1:]
+			}
+		}
+	}
+
+	if end := strings.IndexByte(rest, ' '); end > 0 && isTag(rest[:end]) {
+		tag := rest[:end-1]
+		if open := strings.IndexByte(tag, '['); open > 0 {
+			m.ProcId = strings.TrimSuffix(tag[open+1:], "]")
+			tag = tag[:open]
+		}
+		m.AppName, rest = tag, rest[end+1:]
+	}
+	m.Message = rest
+}
+
+// Tells whether the token is a tag `app:` or `app[pid]:`.
+func isTag(token string) bool {
+	if len(token) < 2 || token[len(token)-1] != ':' {
+		return false
+	}
+	tag := token[:len(token)-1]
+	if open := strings.IndexByte(tag, '['); open >= 0 {
+		return open > 0 && strings.HasSuffix(tag, "]")
+	}
+	return !strings.ContainsAny(tag, "[]")
+}
+
+// Returns empty string for the RFC 5424 NILVALUE.
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
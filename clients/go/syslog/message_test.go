@@ -0,0 +1,130 @@
+package syslog
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Reads captured sample messages, one per line.
+func readSamples(t *testing.T) [][]byte {
+	f, err := os.Open("testdata/samples.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var samples [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		samples = append(samples, append([]byte(nil), scanner.Bytes()...))
+	}
+	return samples
+}
+
+func parseTime(value string) time.Time {
+	ts, _ := time.Parse(time.RFC3339Nano, value)
+	return ts
+}
+
+func TestParse_Samples(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	var tests = []Message{
+		{Facility: 4, Severity: 2, Timestamp: parseTime("2003-10-11T22:14:15.003Z"), Hostname: "mymachine.example.com",
+			AppName: "su", MsgId: "ID47", Message: "su root failed for lonvick on /dev/pts/8"},
+		{Facility: 20, Severity: 5, Timestamp: parseTime("2003-08-24T05:14:15.000003-07:00"), Hostname: "192.0.2.1",
+			AppName: "myproc", ProcId: "8710", Message: "%% It's time to make the do-nuts."},
+		{Facility: 20, Severity: 5, Timestamp: parseTime("2003-10-11T22:14:15.003Z"), Hostname: "mymachine.example.com",
+			AppName: "evntslog", MsgId: "ID47", Message: "An application event log entry...",
+			StructuredData: map[string]map[string]string{
+				"exampleSDID@32473":     {"iut": "3", "eventSource": "Application", "eventID": "1011"},
+				"examplePriority@32473": {"class": "high"},
+			}},
+		{Facility: 20, Severity: 5, Timestamp: parseTime("2003-10-11T22:14:15.003Z"), Hostname: "mymachine.example.com",
+			AppName: "evntslog", MsgId: "ID47",
+			StructuredData: map[string]map[string]string{"exampleSDID@32473": {"path": `C:\Windows\"x"]`}}},
+		{Facility: 4, Severity: 2, Timestamp: time.Date(2024, 10, 11, 22, 14, 15, 0, time.Local).AddDate(-1, 0, 0),
+			Hostname: "mymachine", AppName: "su", Message: "'su root' failed for lonvick on /dev/pts/8"},
+		{Facility: 1, Severity: 5, Timestamp: time.Date(2024, 2, 5, 17, 32, 18, 0, time.Local).AddDate(-1, 0, 0),
+			Hostname: "10.0.0.99", AppName: "sshd", ProcId: "4123", Message: "Accepted publickey for deploy from 10.0.0.7 port 52144"},
+		{Facility: 3, Severity: 6, Timestamp: time.Date(2023, 12, 31, 23, 59, 59, 0, time.Local),
+			AppName: "ntpd", ProcId: "812", Message: "time reset +0.215 s"},
+		{Facility: 1, Severity: 5, Message: "Use the BFG!"},
+		{Facility: 23, Severity: 7, Timestamp: time.Date(2024, 1, 1, 0, 0, 1, 0, time.Local),
+			Hostname: "fw01", AppName: "kernel", Message: "[UFW BLOCK] IN=eth0 OUT="},
+	}
+
+	samples := readSamples(t)
+	if len(samples) != len(tests) {
+		t.Fatalf("Want %d samples, Got %d", len(tests), len(samples))
+	}
+	for i, want := range tests {
+		got, err := parse(samples[i], now)
+		if err != nil {
+			t.Errorf("Set #%d. Unexpected error: %v", i, err)
+			continue
+		}
+		if !got.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("Set #%d. Want timestamp %v, Got %v", i, want.Timestamp, got.Timestamp)
+		}
+		got.Timestamp, want.Timestamp = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("Set #%d.\nWant: %+v\nGot: %+v", i, want, *got)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	var tests = []string{
+		"",
+		"\r\n",
+		"<>1 - - - - - -",
+		"<192>Oct 11 22:14:15 host app: message",
+		"<1a>Oct 11 22:14:15 host app: message",
+		"<13>1 2003-10-11T22:14:15.003Z host app",
+		"<13>1 yesterday host app - - - message",
+		"<13>1 - host app - - message",
+		"<13>1 - host app - - [id a=\"1\"",
+		"<13>1 - host app - - [id a=\"1]",
+		"<13>1 - host app - - [id a]",
+		"<13>1 - host app - - [id]message",
+	}
+
+	for i, input := range tests {
+		if m, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Set #%d. Parsing %q should fail. Got %+v", i, input, m)
+		}
+	}
+}
+
+func TestParse_NilValues(t *testing.T) {
+	m, err := Parse([]byte("<13>1 - - - - - -"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Message{Facility: 1, Severity: 5}); !reflect.DeepEqual(*m, want) {
+		t.Errorf("Want: %+v\nGot: %+v", want, *m)
+	}
+}
+
+func TestSeverityAndFacilityNames(t *testing.T) {
+	var tests = []struct {
+		name string
+		want string
+	}{
+		{SeverityName(0), "emerg"},
+		{SeverityName(7), "debug"},
+		{SeverityName(8), "8"},
+		{FacilityName(0), "kern"},
+		{FacilityName(23), "local7"},
+		{FacilityName(24), "24"},
+	}
+
+	for i, test := range tests {
+		if test.name != test.want {
+			t.Errorf("Set #%d. Want %s, Got %s", i, test.want, test.name)
+		}
+	}
+}
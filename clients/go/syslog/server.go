@@ -0,0 +1,226 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Max size of a message.
+const maxMessageSize = 64 * 1024
+
+// Bounds of the backoff after temporary errors reading UDP datagrams.
+const (
+	minReadBackoff = 5 * time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+// Server receives syslog messages over UDP and TCP
+// and hands them over to the handler as events.
+type Server struct {
+	malformed uint64 // first for 64-bit alignment of atomic counters
+
+	mapper    Mapper
+	handler   func(client.Event)
+	packets   []net.PacketConn
+	listeners []net.Listener
+	conns     map[net.Conn]bool
+	closed    bool
+	stop      chan struct{}
+	running   sync.WaitGroup
+	sync.Mutex
+}
+
+// NewServer creates a server converting messages by the mapper, e.g.
+//
+//	syslog.NewServer(syslog.Mapper{}, func(e client.Event) { myClient.RecordEvent(e) })
+func NewServer(mapper Mapper, handler func(client.Event)) *Server {
+	return &Server{mapper: mapper, handler: handler, conns: make(map[net.Conn]bool), stop: make(chan struct{})}
+}
+
+// ListenUDP starts receiving messages, one per datagram, at the address.
+func (s *Server) ListenUDP(addr string) (net.Addr, error) {
+	packets, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.Lock()
+	s.packets = append(s.packets, packets)
+	s.Unlock()
+
+	s.running.Add(1)
+	go s.servePackets(packets)
+	return packets.LocalAddr(), nil
+}
+
+// ListenTCP starts accepting connections at the address. Messages are
+// framed by octet counting or delimited by newlines (RFC 6587).
+func (s *Server) ListenTCP(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.Lock()
+			if s.closed {
+				s.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns[conn] = true
+			s.Unlock()
+
+			s.running.Add(1)
+			go s.serveConn(conn)
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+// Close stops receiving messages and waits until received ones are handled.
+func (s *Server) Close() error {
+	s.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	for _, packets := range s.packets {
+		packets.Close()
+	}
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.Unlock()
+
+	s.running.Wait()
+	return nil
+}
+
+// Malformed returns number of messages which couldn't be parsed.
+func (s *Server) Malformed() uint64 {
+	return atomic.LoadUint64(&s.malformed)
+}
+
+// Reads messages from UDP datagrams. Temporary read errors are retried
+// with an exponential backoff, others stop reading.
+func (s *Server) servePackets(packets net.PacketConn) {
+	defer s.running.Done()
+	buf := make([]byte, maxMessageSize)
+	var backoff time.Duration
+	for {
+		n, sender, err := packets.ReadFrom(buf)
+		if err == nil {
+			backoff = 0
+			s.receive(buf[:n], sender)
+			continue
+		}
+		if !temporary(err) {
+			return
+		}
+
+		if backoff *= 2; backoff == 0 {
+			backoff = minReadBackoff
+		} else if backoff > maxReadBackoff {
+			backoff = maxReadBackoff
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Tells whether the error is temporary, e.g. a timeout.
+func temporary(err error) bool {
+	t, ok := err.(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
+
+// Reads messages from the connection until it is closed.
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.running.Done()
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(r)
+		if err != nil && err != io.EOF {
+			break
+		}
+		if len(bytes.TrimSpace(frame)) > 0 {
+			s.receive(frame, conn.RemoteAddr())
+		}
+		if err != nil {
+			break
+		}
+	}
+	conn.Close()
+
+	s.Lock()
+	delete(s.conns, conn)
+	s.Unlock()
+}
+
+// Parses the message and hands it over as an event.
+func (s *Server) receive(data []byte, sender net.Addr) {
+	msg, err := Parse(data)
+	if err != nil {
+		atomic.AddUint64(&s.malformed, 1)
+		return
+	}
+	host := ""
+	if sender != nil {
+		host, _, _ = net.SplitHostPort(sender.String())
+	}
+	if event, ok := s.mapper.Event(msg, host); ok {
+		s.handler(event)
+	}
+}
+
+// Reads a message framed by octet counting `<length> <PRI>...`,
+// or delimited by a newline.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	head, err := r.Peek(len(strconv.Itoa(maxMessageSize)) + 2)
+	if len(head) == 0 {
+		return nil, err
+	}
+	space := bytes.IndexByte(head, ' ')
+	length, err := strconv.Atoi(string(head[:max(space, 0)]))
+	if space < 1 || err != nil || head[0] == '0' || space+1 >= len(head) || head[space+1] != '<' {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, ParseError{"message too long"}
+		}
+		return line, err
+	}
+
+	if length > maxMessageSize {
+		return nil, ParseError{"message too long"}
+	}
+	r.Discard(space + 1)
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return frame, nil
+}
@@ -0,0 +1,209 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/samsara/samsara/clients/go"
+)
+
+// Collects names of events handed over by the server.
+type collector struct {
+	events []client.Event
+	sync.Mutex
+}
+
+func (c *collector) handle(event client.Event) {
+	c.Lock()
+	c.events = append(c.events, event)
+	c.Unlock()
+}
+
+// Waits until n events are collected and returns their sorted names.
+func (c *collector) names(n int) []string {
+	for i := 0; i < 100; i++ {
+		c.Lock()
+		count := len(c.events)
+		c.Unlock()
+		if count >= n {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	var names []string
+	for _, event := range c.events {
+		names = append(names, event["eventName"].(string))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestServer_UDP(t *testing.T) {
+	c := &collector{}
+	s := NewServer(Mapper{Rules: []Rule{{AppName: "CRON", Drop: true}}}, c.handle)
+	addr, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, sample := range readSamples(t) {
+		conn.Write(sample)
+	}
+	conn.Write([]byte("<13>Oct 11 22:14:15 host CRON[1]: dropped"))
+	conn.Write([]byte("<999>malformed"))
+
+	want := "evntslog.ID47,evntslog.ID47,kernel,myproc,ntpd,sshd,su,su.ID47,syslog"
+	if got := c.names(9); strings.Join(got, ",") != want {
+		t.Errorf("Want: %v\nGot: %v", want, got)
+	}
+	for i := 0; i < 100 && s.Malformed() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.Malformed() != 1 {
+		t.Errorf("Malformed message should be counted. Got %d", s.Malformed())
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, event := range c.events {
+		if event["eventName"] == "syslog" && event["sourceId"] != "127.0.0.1" {
+			t.Errorf("Sender should be the sourceId of messages without hostname. Got %v", event["sourceId"])
+		}
+	}
+}
+
+// Packet connection failing reads with the given errors, then permanently.
+type failingPackets struct {
+	net.PacketConn
+	errs  []error
+	reads int
+}
+
+func (p *failingPackets) ReadFrom(buf []byte) (int, net.Addr, error) {
+	p.reads++
+	if p.reads <= len(p.errs) {
+		return 0, nil, p.errs[p.reads-1]
+	}
+	return 0, nil, net.ErrClosed
+}
+
+// Temporary network error.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func TestServer_ServePackets_StopsOnPermanentErrors(t *testing.T) {
+	packets := &failingPackets{errs: []error{temporaryError{}, temporaryError{}}}
+	s := NewServer(Mapper{}, func(client.Event) {})
+	s.running.Add(1)
+
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		s.servePackets(packets)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Reading should stop on a permanent error")
+	}
+
+	if packets.reads != 3 {
+		t.Errorf("Temporary errors should be retried. Got %d reads", packets.reads)
+	}
+	if elapsed := time.Since(start); elapsed < 3*minReadBackoff {
+		t.Errorf("Temporary errors should be retried with a backoff. Got %v", elapsed)
+	}
+}
+
+func TestServer_TCP(t *testing.T) {
+	c := &collector{}
+	s := NewServer(Mapper{}, c.handle)
+	addr, err := s.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// octet counting and newline delimited framing mixed in one stream
+	conn.Write([]byte("36 <13>1 - h a - - - first\nwith newline"))
+	conn.Write([]byte("<13>1 - h b - - - second\n\n<13>1 - h c - - - third\n"))
+	conn.Write([]byte("<13>1 - h d - - - last without newline"))
+	conn.Close()
+
+	if got := c.names(4); strings.Join(got, ",") != "a,b,c,d" {
+		t.Errorf("Want: a,b,c,d\nGot: %v", got)
+	}
+	c.Lock()
+	if c.events[0]["message"] != "first\nwith newline" {
+		t.Errorf("Octet counted frame should contain newlines. Got %q", c.events[0]["message"])
+	}
+	c.Unlock()
+
+	// open connections are closed with the server
+	open, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+	open.Write([]byte("<13>1 - h e - - - partial"))
+	s.Close()
+	open.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := open.Read(make([]byte, 1)); err == nil || err.(net.Error).Timeout() {
+		t.Errorf("Open connection should be closed. Got %v", err)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	var tests = []struct {
+		input  string
+		frames []string
+	}{
+		{"a\nb\n", []string{"a\n", "b\n"}},
+		{"a", []string{"a"}},
+		{"4 <1>a5 <2>bc", []string{"<1>a", "<2>bc"}},
+		{"4 <1>a<13>b\n", []string{"<1>a", "<13>b\n"}},
+		{"2024 is not a length\n", []string{"2024 is not a length\n"}},
+		{"05 abcde\n", []string{"05 abcde\n"}},
+		{"3 abc\n", []string{"3 abc\n"}},
+		{"5 <1>a", nil},
+		{"99999999 <1>a", []string{"99999999 <1>a"}},
+		{"70000 <1>a", nil},
+	}
+
+	for i, test := range tests {
+		r := bufio.NewReaderSize(strings.NewReader(test.input), maxMessageSize)
+		var frames []string
+		for {
+			frame, err := readFrame(r)
+			if len(frame) > 0 {
+				frames = append(frames, string(frame))
+			}
+			if err != nil {
+				break
+			}
+		}
+		if strings.Join(frames, "|") != strings.Join(test.frames, "|") {
+			t.Errorf("Set #%d. Want %q, Got %q", i, test.frames, frames)
+		}
+	}
+}
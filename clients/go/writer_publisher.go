@@ -0,0 +1,37 @@
+package client
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterPublisher writes batches of events as NDJSON to a writer,
+// e.g. to stdout during local development.
+type WriterPublisher struct {
+	w io.Writer
+	sync.Mutex
+}
+
+// NewWriterPublisher creates a publisher writing events to w.
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewStdoutPublisher creates a publisher writing events to stdout.
+func NewStdoutPublisher() *WriterPublisher {
+	return NewWriterPublisher(os.Stdout)
+}
+
+// Post writes events one per line.
+func (p *WriterPublisher) Post(data []Event) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	buffered := bufio.NewWriter(p.w)
+	if err := EncodeEvents(buffered, FormatNDJSON, data); err != nil {
+		return false
+	}
+	return buffered.Flush() == nil
+}
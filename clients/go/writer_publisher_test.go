@@ -0,0 +1,47 @@
+package client
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWriterPublisher_Post(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	if !publisher.Post([]Event{{"eventName": "a"}, {"eventName": "b"}}) {
+		t.Error("Events should be written")
+	}
+	if !publisher.Post([]Event{}) {
+		t.Error("Empty batch should be written")
+	}
+	if publisher.Post([]Event{{"eventName": "c", "value": math.NaN()}}) {
+		t.Error("Post should fail on encoding error")
+	}
+
+	want := "{\"eventName\":\"a\"}\n{\"eventName\":\"b\"}\n"
+	if buf.String() != want {
+		t.Errorf("Incorrect output.\nWant: %q\nGot: %q", want, buf.String())
+	}
+}
+
+func TestWriterPublisher_Post_WriteError(t *testing.T) {
+	publisher := NewWriterPublisher(failingWriter{})
+	if publisher.Post([]Event{{"eventName": "a"}}) {
+		t.Error("Post should fail on write error")
+	}
+}
+
+func TestClient_StdoutTransport_RequiresNoUrl(t *testing.T) {
+	config := NewConfig()
+	config.Transport = "stdout"
+	config.StartPublishingThread = false
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("Client should be created without Url. Got %v", err)
+	}
+	if _, ok := client.publisher.(*WriterPublisher); !ok {
+		t.Errorf("Stdout publisher should be used. Got %T", client.publisher)
+	}
+}